@@ -0,0 +1,92 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Field is a key/value pair attached to a log entry.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// F creates a new Field
+func F(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// IStructuredLogger is implemented by the loggers that support key/value fields. See NewSlog and the adapters in the
+// zaplog and logruslog packages.
+type IStructuredLogger interface {
+	ILogger
+
+	// With returns a logger that includes the provided fields in every entry.
+	With(fields ...Field) IStructuredLogger
+}
+
+// With returns the current logger with the provided fields attached. If the current logger does not support fields,
+// they are appended to the message in 'key=value' format.
+//
+//   Example:
+//
+//      log.With(log.F("collection", "users"), log.F("duration", d)).Warn("Slow operation")
+//
+func With(fields ...Field) IStructuredLogger {
+	if l, ok := Get().(IStructuredLogger); ok {
+		return l.With(fields...)
+	}
+	return &fieldLogger{logger: Get(), fields: fields}
+}
+
+// fieldLogger adds fields to the loggers that do not support them, by appending them to the message.
+type fieldLogger struct {
+	logger ILogger
+	fields []Field
+}
+
+func (l *fieldLogger) Debug(args ...interface{}) {
+	l.logger.Debug(l.format(args))
+}
+
+func (l *fieldLogger) Info(args ...interface{}) {
+	l.logger.Info(l.format(args))
+}
+
+func (l *fieldLogger) Warn(args ...interface{}) {
+	l.logger.Warn(l.format(args))
+}
+
+func (l *fieldLogger) Error(args ...interface{}) {
+	l.logger.Error(l.format(args))
+}
+
+func (l *fieldLogger) With(fields ...Field) IStructuredLogger {
+	return &fieldLogger{logger: l.logger, fields: append(append([]Field{}, l.fields...), fields...)}
+}
+
+func (l *fieldLogger) format(args []interface{}) string {
+	entry := []string{fmt.Sprint(args...)}
+	for _, f := range l.fields {
+		entry = append(entry, f.Key+"="+formatValue(f.Value))
+	}
+	return strings.Join(entry, " ")
+}
+
+func formatValue(value interface{}) string {
+	if value == nil {
+		return "null"
+	}
+	switch reflect.Indirect(reflect.ValueOf(value)).Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct:
+		if data, err := json.Marshal(value); err == nil {
+			return string(data)
+		}
+	}
+	if s, ok := value.(string); ok && strings.ContainsAny(s, " \"=") {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprint(value)
+}
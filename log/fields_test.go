@@ -0,0 +1,51 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type recorderLogger struct {
+	entries []string
+}
+
+func (l *recorderLogger) Debug(args ...interface{}) {
+	l.entries = append(l.entries, fmt.Sprint(args...))
+}
+func (l *recorderLogger) Info(args ...interface{}) {
+	l.entries = append(l.entries, fmt.Sprint(args...))
+}
+func (l *recorderLogger) Warn(args ...interface{}) {
+	l.entries = append(l.entries, fmt.Sprint(args...))
+}
+func (l *recorderLogger) Error(args ...interface{}) {
+	l.entries = append(l.entries, fmt.Sprint(args...))
+}
+
+func TestWith_Unstructured(t *testing.T) {
+	logger, previous := &recorderLogger{}, Get()
+	Set(logger)
+	defer Set(previous)
+
+	With(F("collection", "users"), F("filter", bson.M{"age": 30})).With(F("error", "some error")).Warn("Slow operation")
+	assert.Equal(t, []string{`Slow operation collection=users filter={"age":30} error="some error"`}, logger.entries)
+}
+
+func TestWith_Slog(t *testing.T) {
+	buf, previous := &bytes.Buffer{}, Get()
+	Set(NewSlog(slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+		if a.Key == slog.TimeKey {
+			return slog.Attr{}
+		}
+		return a
+	}}))))
+	defer Set(previous)
+
+	With(F("collection", "users"), F("attempt", 2)).Warn("Retrying")
+	assert.Equal(t, "level=WARN msg=Retrying collection=users attempt=2\n", buf.String())
+}
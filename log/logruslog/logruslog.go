@@ -0,0 +1,44 @@
+package logruslog
+
+import (
+	"github.com/jucardi/go-mongodb-lib/log"
+	"github.com/sirupsen/logrus"
+)
+
+// New creates a log.IStructuredLogger that writes the entries to the provided logrus logger or entry.
+//
+//   Example:
+//
+//      log.Set(logruslog.New(logrus.StandardLogger()))
+//
+func New(logger logrus.FieldLogger) log.IStructuredLogger {
+	return &logrusLogger{logger: logger}
+}
+
+type logrusLogger struct {
+	logger logrus.FieldLogger
+}
+
+func (l *logrusLogger) Debug(args ...interface{}) {
+	l.logger.Debug(args...)
+}
+
+func (l *logrusLogger) Info(args ...interface{}) {
+	l.logger.Info(args...)
+}
+
+func (l *logrusLogger) Warn(args ...interface{}) {
+	l.logger.Warn(args...)
+}
+
+func (l *logrusLogger) Error(args ...interface{}) {
+	l.logger.Error(args...)
+}
+
+func (l *logrusLogger) With(fields ...log.Field) log.IStructuredLogger {
+	logrusFields := make(logrus.Fields, len(fields))
+	for _, f := range fields {
+		logrusFields[f.Key] = f.Value
+	}
+	return &logrusLogger{logger: l.logger.WithFields(logrusFields)}
+}
@@ -0,0 +1,22 @@
+package logruslog
+
+import (
+	"testing"
+
+	"github.com/jucardi/go-mongodb-lib/log"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	l, hook := test.NewNullLogger()
+	logger := New(l)
+
+	logger.With(log.F("collection", "users"), log.F("attempt", 2)).Warn("Retrying ", "now")
+
+	assert.Len(t, hook.Entries, 1)
+	assert.Equal(t, logrus.WarnLevel, hook.LastEntry().Level)
+	assert.Equal(t, "Retrying now", hook.LastEntry().Message)
+	assert.Equal(t, logrus.Fields{"collection": "users", "attempt": 2}, hook.LastEntry().Data)
+}
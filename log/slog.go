@@ -0,0 +1,44 @@
+package log
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// NewSlog creates an IStructuredLogger that writes the entries to the provided slog.Logger.
+//
+//   Example:
+//
+//      log.Set(log.NewSlog(slog.Default()))
+//
+func NewSlog(logger *slog.Logger) IStructuredLogger {
+	return &slogLogger{logger: logger}
+}
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+func (l *slogLogger) Debug(args ...interface{}) {
+	l.logger.Debug(fmt.Sprint(args...))
+}
+
+func (l *slogLogger) Info(args ...interface{}) {
+	l.logger.Info(fmt.Sprint(args...))
+}
+
+func (l *slogLogger) Warn(args ...interface{}) {
+	l.logger.Warn(fmt.Sprint(args...))
+}
+
+func (l *slogLogger) Error(args ...interface{}) {
+	l.logger.Error(fmt.Sprint(args...))
+}
+
+func (l *slogLogger) With(fields ...Field) IStructuredLogger {
+	attrs := make([]interface{}, len(fields))
+	for i, f := range fields {
+		attrs[i] = slog.Any(f.Key, f.Value)
+	}
+	return &slogLogger{logger: l.logger.With(attrs...)}
+}
@@ -0,0 +1,46 @@
+package zaplog
+
+import (
+	"fmt"
+
+	"github.com/jucardi/go-mongodb-lib/log"
+	"go.uber.org/zap"
+)
+
+// New creates a log.IStructuredLogger that writes the entries to the provided zap logger.
+//
+//   Example:
+//
+//      log.Set(zaplog.New(zap.L()))
+//
+func New(logger *zap.Logger) log.IStructuredLogger {
+	return &zapLogger{logger: logger}
+}
+
+type zapLogger struct {
+	logger *zap.Logger
+}
+
+func (l *zapLogger) Debug(args ...interface{}) {
+	l.logger.Debug(fmt.Sprint(args...))
+}
+
+func (l *zapLogger) Info(args ...interface{}) {
+	l.logger.Info(fmt.Sprint(args...))
+}
+
+func (l *zapLogger) Warn(args ...interface{}) {
+	l.logger.Warn(fmt.Sprint(args...))
+}
+
+func (l *zapLogger) Error(args ...interface{}) {
+	l.logger.Error(fmt.Sprint(args...))
+}
+
+func (l *zapLogger) With(fields ...log.Field) log.IStructuredLogger {
+	zapFields := make([]zap.Field, len(fields))
+	for i, f := range fields {
+		zapFields[i] = zap.Any(f.Key, f.Value)
+	}
+	return &zapLogger{logger: l.logger.With(zapFields...)}
+}
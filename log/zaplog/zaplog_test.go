@@ -0,0 +1,24 @@
+package zaplog
+
+import (
+	"testing"
+
+	"github.com/jucardi/go-mongodb-lib/log"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestNew(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := New(zap.New(core))
+
+	logger.With(log.F("collection", "users"), log.F("attempt", 2)).Warn("Retrying ", "now")
+
+	entries := logs.All()
+	assert.Len(t, entries, 1)
+	assert.Equal(t, zapcore.WarnLevel, entries[0].Level)
+	assert.Equal(t, "Retrying now", entries[0].Message)
+	assert.Equal(t, map[string]interface{}{"collection": "users", "attempt": int64(2)}, entries[0].ContextMap())
+}
@@ -0,0 +1,236 @@
+package metrics
+
+import (
+	"github.com/jucardi/go-mongodb-lib/mgo"
+	"github.com/prometheus/client_golang/prometheus"
+	mgov2 "gopkg.in/mgo.v2"
+)
+
+// DefaultNamespace is the namespace used for the metric names if none is provided.
+const DefaultNamespace = "mongodb"
+
+// Error classes used as the 'class' label of the errors counter.
+const (
+	ErrorClassNotFound     = "not_found"
+	ErrorClassDuplicateKey = "duplicate_key"
+	ErrorClassTimeout      = "timeout"
+	ErrorClassNetwork      = "network"
+	ErrorClassNotPrimary   = "not_primary"
+	ErrorClassWriteConcern = "write_concern"
+	ErrorClassAuth         = "auth"
+	ErrorClassValidation   = "validation"
+	ErrorClassCursor       = "cursor_not_found"
+	ErrorClassOther        = "other"
+)
+
+var errorClasses = map[error]string{
+	mgo.ErrDuplicateKey:   ErrorClassDuplicateKey,
+	mgo.ErrTimeout:        ErrorClassTimeout,
+	mgo.ErrNetwork:        ErrorClassNetwork,
+	mgo.ErrNotPrimary:     ErrorClassNotPrimary,
+	mgo.ErrWriteConcern:   ErrorClassWriteConcern,
+	mgo.ErrAuth:           ErrorClassAuth,
+	mgo.ErrValidation:     ErrorClassValidation,
+	mgo.ErrCursorNotFound: ErrorClassCursor,
+}
+
+var (
+	// DefaultBuckets are the buckets, in seconds, used for the operation latency histogram.
+	DefaultBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+	// DefaultBulkBuckets are the buckets used for the bulk size histogram.
+	DefaultBulkBuckets = []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000}
+)
+
+// Options defines how the metrics are created.
+type Options struct {
+	// Namespace is the prefix of the metric names. Defaults to DefaultNamespace.
+	Namespace string
+	// ConstLabels are labels added to all metrics, eg: the name of the application or the cluster.
+	ConstLabels prometheus.Labels
+	// Buckets for the operation latency histogram, in seconds. Defaults to DefaultBuckets.
+	Buckets []float64
+	// BulkBuckets for the bulk size histogram. Defaults to DefaultBulkBuckets.
+	BulkBuckets []float64
+	// PoolStats enables the connection pool gauges. Since the mgo driver keeps the pool statistics globally, enabling
+	// them turns on the driver statistics (mgo.SetStats) for the whole process, and they are not turned off by Close
+	// since other collectors may depend on them. The gauges of every collector report the statistics of all the
+	// sessions of the process, not only the ones intercepted by the collector.
+	PoolStats bool
+}
+
+// Collector records the metrics of the operations that run through its interceptor and of the dial retries performed
+// by Dial, DialWithInfo and DialWithTls. It implements prometheus.Collector, so it may be registered in any registry:
+//
+//     collector := metrics.NewCollector()
+//     defer collector.Close()
+//     prometheus.MustRegister(collector)
+//     session = collector.Session(session)
+//
+type Collector struct {
+	duration    *prometheus.HistogramVec
+	errors      *prometheus.CounterVec
+	returned    *prometheus.CounterVec
+	bulkSize    *prometheus.HistogramVec
+	dialRetries prometheus.Counter
+	pool        []poolGauge
+	removeDial  func()
+}
+
+type poolGauge struct {
+	desc  *prometheus.Desc
+	value func(stats *mgov2.Stats) int
+}
+
+// NewCollector creates a new metrics collector. The collector starts counting dial retries as soon as it is created,
+// until it is closed. See Close.
+func NewCollector(opts ...Options) *Collector {
+	cfg := Options{}
+	if len(opts) > 0 {
+		cfg = opts[0]
+	}
+	if cfg.Namespace == "" {
+		cfg.Namespace = DefaultNamespace
+	}
+	if len(cfg.Buckets) == 0 {
+		cfg.Buckets = DefaultBuckets
+	}
+	if len(cfg.BulkBuckets) == 0 {
+		cfg.BulkBuckets = DefaultBulkBuckets
+	}
+
+	c := &Collector{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   cfg.Namespace,
+			Name:        "operation_duration_seconds",
+			Help:        "Duration of the database operations in seconds.",
+			ConstLabels: cfg.ConstLabels,
+			Buckets:     cfg.Buckets,
+		}, []string{"collection", "operation"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   cfg.Namespace,
+			Name:        "operation_errors_total",
+			Help:        "Amount of database operations that returned an error, by error class.",
+			ConstLabels: cfg.ConstLabels,
+		}, []string{"collection", "operation", "class"}),
+		returned: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   cfg.Namespace,
+			Name:        "documents_returned_total",
+			Help:        "Amount of documents returned by the read operations.",
+			ConstLabels: cfg.ConstLabels,
+		}, []string{"collection", "operation"}),
+		bulkSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   cfg.Namespace,
+			Name:        "bulk_size",
+			Help:        "Amount of operations sent in bulk operations.",
+			ConstLabels: cfg.ConstLabels,
+			Buckets:     cfg.BulkBuckets,
+		}, []string{"collection", "operation"}),
+		dialRetries: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   cfg.Namespace,
+			Name:        "dial_retries_total",
+			Help:        "Amount of dial attempts retried after a failed connection.",
+			ConstLabels: cfg.ConstLabels,
+		}),
+	}
+
+	if cfg.PoolStats {
+		mgov2.SetStats(true)
+		c.pool = []poolGauge{
+			c.poolGauge(cfg, "pool_clusters", "Amount of clusters the driver is connected to.", func(s *mgov2.Stats) int { return s.Clusters }),
+			c.poolGauge(cfg, "pool_master_connections", "Amount of connections to master servers.", func(s *mgov2.Stats) int { return s.MasterConns }),
+			c.poolGauge(cfg, "pool_slave_connections", "Amount of connections to slave servers.", func(s *mgov2.Stats) int { return s.SlaveConns }),
+			c.poolGauge(cfg, "pool_sockets_alive", "Amount of sockets alive.", func(s *mgov2.Stats) int { return s.SocketsAlive }),
+			c.poolGauge(cfg, "pool_sockets_in_use", "Amount of sockets in use.", func(s *mgov2.Stats) int { return s.SocketsInUse }),
+		}
+	}
+
+	c.removeDial = mgo.AddDialRetryHandler(func([]string, int, error) {
+		c.dialRetries.Inc()
+	})
+
+	return c
+}
+
+// Close stops counting the dial retries, removing the process wide handler registered by NewCollector. The operations
+// that run through the interceptor of the collector are still recorded.
+func (c *Collector) Close() {
+	c.removeDial()
+}
+
+func (c *Collector) poolGauge(cfg Options, name, help string, value func(*mgov2.Stats) int) poolGauge {
+	return poolGauge{
+		desc:  prometheus.NewDesc(prometheus.BuildFQName(cfg.Namespace, "", name), help, nil, cfg.ConstLabels),
+		value: value,
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.duration.Describe(ch)
+	c.errors.Describe(ch)
+	c.returned.Describe(ch)
+	c.bulkSize.Describe(ch)
+	c.dialRetries.Describe(ch)
+	for _, g := range c.pool {
+		ch <- g.desc
+	}
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.duration.Collect(ch)
+	c.errors.Collect(ch)
+	c.returned.Collect(ch)
+	c.bulkSize.Collect(ch)
+	c.dialRetries.Collect(ch)
+
+	if len(c.pool) > 0 {
+		stats := mgov2.GetStats()
+		for _, g := range c.pool {
+			ch <- prometheus.MustNewConstMetric(g.desc, prometheus.GaugeValue, float64(g.value(&stats)))
+		}
+	}
+}
+
+// Session decorates the provided session so the operations performed through it are recorded by the collector.
+func (c *Collector) Session(s mgo.ISession) mgo.ISession {
+	return mgo.InterceptSession(s, c.Interceptor())
+}
+
+// Collection decorates the provided collection so the operations performed through it are recorded by the collector.
+func (c *Collector) Collection(col mgo.ICollection) mgo.ICollection {
+	return mgo.InterceptCollection(col, c.Interceptor())
+}
+
+// Interceptor returns an mgo.Interceptor that records the metrics of every operation.
+func (c *Collector) Interceptor() mgo.Interceptor {
+	return func(op *mgo.Operation, next mgo.Invoker) error {
+		err := next(op)
+
+		c.duration.WithLabelValues(op.Collection, op.Name).Observe(op.Duration.Seconds())
+
+		if err != nil {
+			c.errors.WithLabelValues(op.Collection, op.Name, ErrorClass(err)).Inc()
+		}
+		if op.Returned > 0 {
+			c.returned.WithLabelValues(op.Collection, op.Name).Add(float64(op.Returned))
+		}
+		if op.BulkSize > 0 {
+			c.bulkSize.WithLabelValues(op.Collection, op.Name).Observe(float64(op.BulkSize))
+		}
+		return err
+	}
+}
+
+// ErrorClass returns the class of the provided error used as the 'class' label of the errors counter. See
+// mgo.Classify.
+func ErrorClass(err error) string {
+	if err == mgo.ErrNotFound {
+		return ErrorClassNotFound
+	}
+	if class, ok := errorClasses[mgo.ErrorCategory(err)]; ok {
+		return class
+	}
+	return ErrorClassOther
+}
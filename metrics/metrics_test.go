@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/jucardi/go-mongodb-lib/mgo"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	mgov2 "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func setup() (*Collector, *mgo.CollectionMock, mgo.ICollection) {
+	collector := NewCollector()
+	mock := mgo.MockCollection(mgo.NewCollection(&mgov2.Collection{Name: "users", FullName: "test.users"}))
+	return collector, mock, collector.Collection(mock)
+}
+
+func TestCollector_Operations(t *testing.T) {
+	collector, mock, col := setup()
+	defer collector.Close()
+
+	q := mgo.MockQuery()
+	q.When("All", func(args ...interface{}) []interface{} {
+		*args[0].(*[]bson.M) = []bson.M{{"a": 1}, {"a": 2}, {"a": 3}}
+		return []interface{}{nil}
+	})
+	mock.WhenFind(nil, q)
+	mock.WhenInsert(nil, &mgov2.LastError{Code: 11000})
+
+	var result []bson.M
+	assert.NoError(t, col.Find(nil).All(&result))
+	assert.Error(t, col.Insert(bson.M{"a": 1}))
+
+	assert.Equal(t, 2, testutil.CollectAndCount(collector.duration))
+	assert.Equal(t, float64(3), testutil.ToFloat64(collector.returned.WithLabelValues("users", "Query.All")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(collector.errors.WithLabelValues("users", "Insert", ErrorClassDuplicateKey)))
+	assert.Equal(t, 1, testutil.CollectAndCount(collector.errors))
+}
+
+func TestCollector_Register(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	collector := NewCollector(Options{Namespace: "app", PoolStats: true})
+	defer collector.Close()
+	assert.NoError(t, registry.Register(collector))
+
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+
+	var names []string
+	for _, f := range families {
+		names = append(names, f.GetName())
+	}
+	assert.Equal(t, "app_dial_retries_total,app_pool_clusters,app_pool_master_connections,app_pool_slave_connections,app_pool_sockets_alive,app_pool_sockets_in_use", strings.Join(names, ","))
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestErrorClass(t *testing.T) {
+	assert.Equal(t, ErrorClassNotFound, ErrorClass(mgo.ErrNotFound))
+	assert.Equal(t, ErrorClassDuplicateKey, ErrorClass(&mgov2.LastError{Code: 11000}))
+	assert.Equal(t, ErrorClassTimeout, ErrorClass(net.Error(timeoutError{})))
+	assert.Equal(t, ErrorClassTimeout, ErrorClass(&mgov2.QueryError{Code: 50}))
+	assert.Equal(t, ErrorClassNetwork, ErrorClass(&net.OpError{Op: "dial", Err: errors.New("connection refused")}))
+	assert.Equal(t, ErrorClassNetwork, ErrorClass(errors.New("no reachable servers")))
+	assert.Equal(t, ErrorClassNotPrimary, ErrorClass(&mgov2.QueryError{Code: 10107}))
+	assert.Equal(t, ErrorClassOther, ErrorClass(errors.New("boom")))
+}
@@ -0,0 +1,54 @@
+package mgo
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Backoff returns the time to wait before the provided retry, starting at 1.
+type Backoff func(retry int) time.Duration
+
+// ConstantBackoff returns a Backoff that always waits the provided duration.
+func ConstantBackoff(d time.Duration) Backoff {
+	return func(int) time.Duration {
+		return d
+	}
+}
+
+// ExponentialBackoff returns a Backoff that waits 'initial' before the first retry and multiplies the wait by
+// 'multiplier' on every following retry, up to 'max'. A 'max' of zero means no maximum, in which case the wait is capped
+// to the largest time.Duration once it overflows.
+func ExponentialBackoff(initial, max time.Duration, multiplier float64) Backoff {
+	return func(retry int) time.Duration {
+		backoff := float64(initial) * math.Pow(multiplier, float64(retry-1))
+		if max > 0 {
+			backoff = math.Min(backoff, float64(max))
+		}
+		return toDuration(backoff)
+	}
+}
+
+// WithJitter returns a Backoff that randomly adds or subtracts up to the provided fraction, between 0 and 1, of the
+// wait returned by the provided Backoff, to spread the retries of multiple clients.
+func WithJitter(backoff Backoff, jitter float64) Backoff {
+	if jitter <= 0 {
+		return backoff
+	}
+	return func(retry int) time.Duration {
+		d := float64(backoff(retry))
+		return toDuration(d + d*jitter*(2*rand.Float64()-1))
+	}
+}
+
+// toDuration converts the provided amount of nanoseconds into a time.Duration, clamped to the range of time.Duration
+// since the conversion of the values out of the range is undefined.
+func toDuration(ns float64) time.Duration {
+	switch {
+	case math.IsNaN(ns) || ns <= 0:
+		return 0
+	case ns >= math.MaxInt64:
+		return math.MaxInt64
+	}
+	return time.Duration(ns)
+}
@@ -1,6 +1,7 @@
 package mgo
 
 import (
+	"errors"
 	"math"
 	"reflect"
 
@@ -137,13 +138,30 @@ func (b *bulk) add(f string, items []interface{}) IBulk {
 
 	for i := 0; i+1 <= int(math.Ceil(l/lim)); i++ {
 		blk := b.col.Bulk()
-		top := int(math.Max(l, float64((i+1)*mgoLim)))
+		top := int(math.Min(l, float64((i+1)*mgoLim)))
 
 		val := reflect.ValueOf(blk)
 		fn := val.MethodByName(f)
 		arg := items[i*mgoLim : top]
-		fn.Call([]reflect.Value{reflect.ValueOf(arg)})
+		fn.CallSlice([]reflect.Value{reflect.ValueOf(arg)})
 		b.bulks = append(b.bulks, blk)
 	}
 	return b
 }
+
+// prepareBulkPairs applies prepare to the selector and the update document of every pair of a bulk update, used by the
+// decorators that modify the queued operations.
+func prepareBulkPairs(pairs []interface{}, prepare func(selector, update interface{}) (interface{}, interface{}, error)) ([]interface{}, error) {
+	if len(pairs)%2 != 0 {
+		return nil, errors.New("bulk update requires an even number of parameters")
+	}
+	ret := make([]interface{}, 0, len(pairs))
+	for i := 0; i < len(pairs); i += 2 {
+		sel, u, err := prepare(pairs[i], pairs[i+1])
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, sel, u)
+	}
+	return ret, nil
+}
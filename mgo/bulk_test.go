@@ -0,0 +1,23 @@
+package mgo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/mgo.v2"
+)
+
+func TestBulk_Chunks(t *testing.T) {
+	docs := make([]interface{}, 2*mgoLim+1)
+	for i := range docs {
+		docs[i] = i
+	}
+
+	b := &bulk{col: &mgo.Collection{}}
+	assert.NotPanics(t, func() { b.Insert(docs...) })
+	assert.Len(t, b.bulks, 3)
+
+	b = &bulk{col: &mgo.Collection{}}
+	b.Insert(docs[:mgoLim]...)
+	assert.Len(t, b.bulks, 1)
+}
@@ -0,0 +1,262 @@
+package mgo
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jucardi/go-mongodb-lib/log"
+)
+
+const (
+	// CircuitClosed is the state in which the operations are allowed and their failures are counted.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen is the state in which the operations fail fast with a *CircuitOpenError.
+	CircuitOpen
+	// CircuitHalfOpen is the state in which the breaker probes the database to decide whether to close or re-open.
+	CircuitHalfOpen
+)
+
+const (
+	// DefaultCircuitFailureRate is the default failure rate above which the circuit opens.
+	DefaultCircuitFailureRate = 0.5
+	// DefaultCircuitMinRequests is the default minimum amount of operations in a window before the failure rate is
+	// evaluated.
+	DefaultCircuitMinRequests = 20
+	// DefaultCircuitWindow is the default duration of the windows in which the failures are counted.
+	DefaultCircuitWindow = 10 * time.Second
+	// DefaultCircuitOpenTimeout is the default time the circuit stays open before probing the database.
+	DefaultCircuitOpenTimeout = 5 * time.Second
+)
+
+// ErrCircuitOpen is matched by the errors returned when the circuit breaker is open, use errors.Is(err, ErrCircuitOpen).
+var ErrCircuitOpen = errors.New("the circuit breaker is open")
+
+// CircuitState is the state of a circuit breaker.
+type CircuitState int
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("CircuitState(%d)", int(s))
+}
+
+// CircuitOpenError is the error returned by the operations rejected while the circuit breaker is open.
+type CircuitOpenError struct {
+	// RetryAfter is the remaining time until the breaker probes the database again.
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%s, retry after %v", ErrCircuitOpen.Error(), e.RetryAfter)
+}
+
+// Is allows matching the error with errors.Is(err, ErrCircuitOpen)
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// CircuitBreakerOptions defines when the circuit breaker opens and closes.
+type CircuitBreakerOptions struct {
+	// FailureRate is the rate of failed operations, between 0 and 1, above which the circuit opens. Defaults to
+	// DefaultCircuitFailureRate.
+	FailureRate float64
+	// MinRequests is the minimum amount of operations in a window before the failure rate is evaluated. Defaults to
+	// DefaultCircuitMinRequests.
+	MinRequests int
+	// Window is the duration of the consecutive windows in which the operations and failures are counted. Defaults to
+	// DefaultCircuitWindow.
+	Window time.Duration
+	// OpenTimeout is the time the circuit stays open before half-opening to probe the database. Defaults to
+	// DefaultCircuitOpenTimeout.
+	OpenTimeout time.Duration
+	// IsFailure indicates whether an error counts as a failure. Defaults to IsRetryable, so errors such as not found or
+	// duplicate keys, which do not indicate a degraded database, are not counted.
+	IsFailure func(err error) bool
+	// OnStateChange is invoked every time the state of the circuit changes.
+	OnStateChange func(from, to CircuitState)
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// CircuitBreaker fails fast the operations while the database is degraded, instead of letting them wait for socket
+// timeouts. The circuit opens when the rate of failed operations in a window exceeds the configured rate, and after
+// the open timeout it half-opens and probes the database with Ping, closing if the probe succeeds or re-opening
+// otherwise. When the session of the operation is unknown, the first operation after the timeout is used as the probe.
+//
+//   Example:
+//
+//      breaker := mgo.NewCircuitBreaker(mgo.CircuitBreakerOptions{
+//          OnStateChange: func(from, to mgo.CircuitState) { alert(to) },
+//      })
+//      session = mgo.InterceptSession(session, breaker.Interceptor())
+//
+type CircuitBreaker struct {
+	opts        CircuitBreakerOptions
+	mux         sync.Mutex
+	state       CircuitState
+	openedAt    time.Time
+	windowStart time.Time
+	requests    int
+	failures    int
+}
+
+// NewCircuitBreaker creates a new closed circuit breaker.
+func NewCircuitBreaker(opts ...CircuitBreakerOptions) *CircuitBreaker {
+	cfg := CircuitBreakerOptions{}
+	if len(opts) > 0 {
+		cfg = opts[0]
+	}
+	if cfg.FailureRate <= 0 {
+		cfg.FailureRate = DefaultCircuitFailureRate
+	}
+	if cfg.MinRequests <= 0 {
+		cfg.MinRequests = DefaultCircuitMinRequests
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = DefaultCircuitWindow
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = DefaultCircuitOpenTimeout
+	}
+	if cfg.IsFailure == nil {
+		cfg.IsFailure = IsRetryable
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return &CircuitBreaker{opts: cfg, windowStart: cfg.Now()}
+}
+
+// State returns the current state of the circuit.
+func (b *CircuitBreaker) State() CircuitState {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	return b.state
+}
+
+// Interceptor returns the Interceptor that applies the circuit breaker to the operations.
+func (b *CircuitBreaker) Interceptor() Interceptor {
+	return func(op *Operation, next Invoker) error {
+		probe, err := b.allow(op)
+		if err != nil {
+			return err
+		}
+		err = next(op)
+		b.record(err, probe)
+		return err
+	}
+}
+
+// allow returns a *CircuitOpenError if the operation must be rejected. When the open timeout has elapsed, it
+// half-opens the circuit and probes the database with the session of the operation, or returns 'probe' if the
+// operation itself must be used as the probe.
+func (b *CircuitBreaker) allow(op *Operation) (probe bool, err error) {
+	b.mux.Lock()
+	switch b.state {
+	case CircuitClosed:
+		b.mux.Unlock()
+		return false, nil
+	case CircuitHalfOpen:
+		b.mux.Unlock()
+		return false, &CircuitOpenError{}
+	}
+
+	if retryAfter := b.openedAt.Add(b.opts.OpenTimeout).Sub(b.opts.Now()); retryAfter > 0 {
+		b.mux.Unlock()
+		return false, &CircuitOpenError{RetryAfter: retryAfter}
+	}
+
+	notify := b.setState(CircuitHalfOpen)
+	b.mux.Unlock()
+	notify()
+
+	s := op.Session()
+	if s == nil {
+		// The operation is the probe, record decides whether the circuit closes.
+		return true, nil
+	}
+
+	s.Refresh()
+	if err := s.Ping(); err != nil {
+		b.transition(CircuitOpen)
+		return false, &CircuitOpenError{RetryAfter: b.opts.OpenTimeout}
+	}
+	b.transition(CircuitClosed)
+	return false, nil
+}
+
+// record counts the result of an operation, opening the circuit if the failure rate is exceeded, or closes or
+// re-opens the circuit if the operation was the probe.
+func (b *CircuitBreaker) record(err error, probe bool) {
+	failed := err != nil && b.opts.IsFailure(err)
+
+	b.mux.Lock()
+	notify := func() {}
+
+	switch b.state {
+	case CircuitHalfOpen:
+		if !probe {
+			break
+		}
+		if failed {
+			notify = b.setState(CircuitOpen)
+		} else {
+			notify = b.setState(CircuitClosed)
+		}
+	case CircuitClosed:
+		now := b.opts.Now()
+		if now.Sub(b.windowStart) >= b.opts.Window {
+			b.windowStart, b.requests, b.failures = now, 0, 0
+		}
+		b.requests++
+		if failed {
+			b.failures++
+		}
+		if b.requests >= b.opts.MinRequests && float64(b.failures)/float64(b.requests) >= b.opts.FailureRate {
+			notify = b.setState(CircuitOpen)
+		}
+	}
+
+	b.mux.Unlock()
+	notify()
+}
+
+func (b *CircuitBreaker) transition(to CircuitState) {
+	b.mux.Lock()
+	notify := b.setState(to)
+	b.mux.Unlock()
+	notify()
+}
+
+// setState changes the state, must be called holding the lock. Returns the function that notifies the change, to be
+// invoked once the lock is released.
+func (b *CircuitBreaker) setState(to CircuitState) func() {
+	from := b.state
+	if from == to {
+		return func() {}
+	}
+
+	b.state = to
+	now := b.opts.Now()
+	switch to {
+	case CircuitOpen:
+		b.openedAt = now
+	case CircuitClosed:
+		b.windowStart, b.requests, b.failures = now, 0, 0
+	}
+
+	return func() {
+		log.With(log.F("from", from.String()), log.F("to", to.String())).Warn("Circuit breaker state changed")
+		if b.opts.OnStateChange != nil {
+			b.opts.OnStateChange(from, to)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package mgo
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type pingSession struct {
+	ISession
+	err   error
+	pings int
+}
+
+func (s *pingSession) Refresh() {}
+
+func (s *pingSession) Ping() error {
+	s.pings++
+	return s.err
+}
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func newTestBreaker(c *clock, changes *[]string) Interceptor {
+	return NewCircuitBreaker(CircuitBreakerOptions{
+		MinRequests: 4,
+		OpenTimeout: time.Minute,
+		Now:         c.Now,
+		OnStateChange: func(from, to CircuitState) {
+			*changes = append(*changes, from.String()+" -> "+to.String())
+		},
+	}).Interceptor()
+}
+
+func invoke(interceptor Interceptor, op *Operation, err error) (invoked bool, ret error) {
+	ret = interceptor(op, func(*Operation) error {
+		invoked = true
+		return err
+	})
+	return
+}
+
+func TestCircuitBreaker_Probe(t *testing.T) {
+	c, changes := &clock{now: time.Now()}, []string{}
+	breaker := newTestBreaker(c, &changes)
+	s := &pingSession{err: io.EOF}
+	op := &Operation{session: func() ISession { return s }}
+
+	for _, err := range []error{nil, ErrNotFound, io.EOF, io.EOF} {
+		invoke(breaker, op, err)
+	}
+	assert.Equal(t, []string{"closed -> open"}, changes)
+
+	invoked, err := invoke(breaker, op, nil)
+	assert.False(t, invoked)
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+	assert.Equal(t, time.Minute, err.(*CircuitOpenError).RetryAfter)
+
+	c.now = c.now.Add(time.Minute)
+	invoked, err = invoke(breaker, op, nil)
+	assert.False(t, invoked)
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+	assert.Equal(t, 1, s.pings)
+	assert.Equal(t, []string{"closed -> open", "open -> half-open", "half-open -> open"}, changes)
+
+	c.now = c.now.Add(time.Minute)
+	s.err = nil
+	invoked, err = invoke(breaker, op, nil)
+	assert.True(t, invoked)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, s.pings)
+	assert.Equal(t, []string{"closed -> open", "open -> half-open", "half-open -> open", "open -> half-open", "half-open -> closed"}, changes)
+}
+
+func TestCircuitBreaker_OperationProbe(t *testing.T) {
+	c, changes := &clock{now: time.Now()}, []string{}
+	breaker := newTestBreaker(c, &changes)
+	op := &Operation{}
+
+	for i := 0; i < 4; i++ {
+		invoke(breaker, op, io.EOF)
+	}
+
+	c.now = c.now.Add(time.Minute)
+	invoked, err := invoke(breaker, op, io.EOF)
+	assert.True(t, invoked)
+	assert.Equal(t, io.EOF, err)
+
+	c.now = c.now.Add(time.Minute)
+	invoked, _ = invoke(breaker, op, nil)
+	assert.True(t, invoked)
+	assert.Equal(t, []string{"closed -> open", "open -> half-open", "half-open -> open", "open -> half-open", "half-open -> closed"}, changes)
+}
+
+func TestCircuitBreaker_Window(t *testing.T) {
+	c, changes := &clock{now: time.Now()}, []string{}
+	breaker := newTestBreaker(c, &changes)
+	op := &Operation{}
+
+	for i := 0; i < 3; i++ {
+		invoke(breaker, op, io.EOF)
+	}
+	c.now = c.now.Add(DefaultCircuitWindow)
+	invoke(breaker, op, io.EOF)
+
+	assert.Empty(t, changes)
+}
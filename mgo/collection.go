@@ -122,7 +122,8 @@ func (c *collection) C() *mgo.Collection {
 	return c.Collection
 }
 func (c *collection) With(s ISession) ICollection {
-	return c.update(c.C().With(s.S()))
+	// Returns a new instance, like mgo.Collection.With, so the receiver keeps using its session.
+	return fromCollection(c.C().With(s.S()))
 }
 
 func (c *collection) Find(query interface{}) IQuery {
@@ -197,11 +198,6 @@ func (c *collection) FullName() string {
 	return c.C().FullName
 }
 
-func (c *collection) update(col *mgo.Collection) ICollection {
-	c.Collection = col
-	return c
-}
-
 func fromCollection(col *mgo.Collection) ICollection {
 	if col == nil {
 		return nil
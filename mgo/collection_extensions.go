@@ -22,6 +22,31 @@ type ICollectionExtensions interface {
 	//
 	// Enhanced to use bulk operations in the length of documents is more than the allowed 1000.
 	BulkUpsert(pairs ...interface{}) (*BulkResult, error)
+
+	// UpdateIfVersion finds a single document matching the provided selector and the expected version, modifies it
+	// according to the update document and increments its version atomically. The version is stored in the
+	// DefaultVersionField field.
+	//
+	// Returns ErrConcurrentModification if the document exists with a different version, or ErrNotFound if no document
+	// matches the selector. Update operators modifying the version field are rejected with an error.
+	//
+	//   {selector}  - The selector of the document
+	//   {version}   - The expected current version of the document
+	//   {update}    - The update document. A replacement document is handled as in ReplaceIfVersion
+	//
+	UpdateIfVersion(selector interface{}, version int64, update interface{}) error
+
+	// ReplaceIfVersion finds a single document matching the provided selector and the expected version, and replaces it
+	// with the provided document, which version is set to the expected version + 1.
+	//
+	// Returns ErrConcurrentModification if the document exists with a different version, or ErrNotFound if no document
+	// matches the selector.
+	//
+	//   {selector}  - The selector of the document
+	//   {version}   - The expected current version of the document
+	//   {doc}       - The replacement document
+	//
+	ReplaceIfVersion(selector interface{}, version int64, doc interface{}) error
 }
 
 // MustEnsureIndex ensures an index with the given key exists, creating it with
@@ -31,12 +56,7 @@ type ICollectionExtensions interface {
 //   {index} - the index in Bson form
 //
 func (c *collection) MustEnsureIndex(index Index) {
-	if err := c.EnsureIndex(index); err != nil {
-		log.Get().Error(err)
-		panic(err)
-	} else {
-		log.Get().Info(fmt.Sprintf("collection [%s] index is up to date", c.Name()))
-	}
+	mustEnsureIndex(c, index)
 }
 
 // Insert **Override of mgo.collection.Insert** inserts one or more documents in the respective collection.
@@ -57,3 +77,20 @@ func (c *collection) Insert(docs ...interface{}) error {
 func (c *collection) BulkUpsert(pairs ...interface{}) (*BulkResult, error) {
 	return NewBulk(c).Upsert(pairs...).Run()
 }
+
+func (c *collection) UpdateIfVersion(selector interface{}, version int64, update interface{}) error {
+	return updateIfVersion(c, DefaultVersionField, selector, version, update)
+}
+
+func (c *collection) ReplaceIfVersion(selector interface{}, version int64, doc interface{}) error {
+	return replaceIfVersion(c, DefaultVersionField, selector, version, doc, version+1)
+}
+
+func mustEnsureIndex(c ICollection, index Index) {
+	if err := c.EnsureIndex(index); err != nil {
+		log.Get().Error(err)
+		panic(err)
+	} else {
+		log.Get().Info(fmt.Sprintf("collection [%s] index is up to date", c.Name()))
+	}
+}
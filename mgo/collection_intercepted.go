@@ -0,0 +1,209 @@
+package mgo
+
+import (
+	"gopkg.in/mgo.v2/bson"
+)
+
+// interceptedCollection is the ICollection decorator created by InterceptCollection
+type interceptedCollection struct {
+	ICollection
+	chain interceptorChain
+	db    string
+	name  string
+}
+
+func newInterceptedCollection(col ICollection, chain interceptorChain, db, name string) ICollection {
+	return &interceptedCollection{ICollection: col, chain: chain, db: db, name: name}
+}
+
+func (c *interceptedCollection) With(s ISession) ICollection {
+	return newInterceptedCollection(c.ICollection.With(s), c.chain, c.db, c.name)
+}
+
+func (c *interceptedCollection) Database() IDatabase {
+	return &interceptedDatabase{IDatabase: c.ICollection.Database(), chain: c.chain}
+}
+
+func (c *interceptedCollection) Find(query interface{}) IQuery {
+	return newInterceptedQuery(c.ICollection.Find(query), c.chain, Operation{Database: c.db, Collection: c.name, Filter: query, session: c.session})
+}
+
+func (c *interceptedCollection) FindId(id interface{}) IQuery {
+	return newInterceptedQuery(c.ICollection.FindId(id), c.chain, Operation{Database: c.db, Collection: c.name, Filter: bson.M{"_id": id}, session: c.session})
+}
+
+func (c *interceptedCollection) Pipe(pipeline interface{}) IPipe {
+	return &interceptedPipe{
+		IPipe: c.ICollection.Pipe(pipeline),
+		chain: c.chain,
+		base:  Operation{Database: c.db, Collection: c.name, Pipeline: pipeline, session: c.session},
+	}
+}
+
+func (c *interceptedCollection) Bulk() IBulk {
+	return &interceptedBulk{
+		IBulk: c.ICollection.Bulk(),
+		chain: c.chain,
+		base:  Operation{Database: c.db, Collection: c.name, session: c.session},
+	}
+}
+
+func (c *interceptedCollection) Count() (n int, err error) {
+	err = c.chain.run(c.op("Count", OperationRead), func(*Operation) error {
+		n, err = c.ICollection.Count()
+		return err
+	})
+	return
+}
+
+func (c *interceptedCollection) Insert(docs ...interface{}) error {
+	op := c.op("Insert", OperationWrite)
+	op.Documents = docs
+	return c.chain.run(op, func(op *Operation) error {
+		return c.ICollection.Insert(op.Documents...)
+	})
+}
+
+func (c *interceptedCollection) Update(selector interface{}, update interface{}) error {
+	return c.chain.run(c.writeOp("Update", selector, update), func(op *Operation) error {
+		return c.ICollection.Update(op.Filter, op.Update)
+	})
+}
+
+func (c *interceptedCollection) UpdateId(id interface{}, update interface{}) error {
+	return c.chain.run(c.writeOp("UpdateId", bson.M{"_id": id}, update), func(op *Operation) error {
+		return c.ICollection.Update(op.Filter, op.Update)
+	})
+}
+
+func (c *interceptedCollection) UpdateAll(selector interface{}, update interface{}) (info *ChangeInfo, err error) {
+	err = c.chain.run(c.writeOp("UpdateAll", selector, update), func(op *Operation) error {
+		info, err = c.ICollection.UpdateAll(op.Filter, op.Update)
+		return err
+	})
+	return
+}
+
+func (c *interceptedCollection) Upsert(selector interface{}, update interface{}) (info *ChangeInfo, err error) {
+	err = c.chain.run(c.writeOp("Upsert", selector, update), func(op *Operation) error {
+		info, err = c.ICollection.Upsert(op.Filter, op.Update)
+		return err
+	})
+	return
+}
+
+func (c *interceptedCollection) UpsertId(id interface{}, update interface{}) (info *ChangeInfo, err error) {
+	err = c.chain.run(c.writeOp("UpsertId", bson.M{"_id": id}, update), func(op *Operation) error {
+		info, err = c.ICollection.Upsert(op.Filter, op.Update)
+		return err
+	})
+	return
+}
+
+func (c *interceptedCollection) Remove(selector interface{}) error {
+	return c.chain.run(c.writeOp("Remove", selector, nil), func(op *Operation) error {
+		return c.ICollection.Remove(op.Filter)
+	})
+}
+
+func (c *interceptedCollection) RemoveId(id interface{}) error {
+	return c.chain.run(c.writeOp("RemoveId", bson.M{"_id": id}, nil), func(op *Operation) error {
+		return c.ICollection.Remove(op.Filter)
+	})
+}
+
+func (c *interceptedCollection) RemoveAll(selector interface{}) (info *ChangeInfo, err error) {
+	err = c.chain.run(c.writeOp("RemoveAll", selector, nil), func(op *Operation) error {
+		info, err = c.ICollection.RemoveAll(op.Filter)
+		return err
+	})
+	return
+}
+
+func (c *interceptedCollection) BulkUpsert(pairs ...interface{}) (result *BulkResult, err error) {
+	op := c.op("BulkUpsert", OperationWrite)
+	op.BulkSize = len(pairs) / 2
+	err = c.chain.run(op, func(*Operation) error {
+		result, err = c.ICollection.BulkUpsert(pairs...)
+		return err
+	})
+	return
+}
+
+func (c *interceptedCollection) UpdateIfVersion(selector interface{}, version int64, update interface{}) error {
+	return c.chain.run(c.writeOp("UpdateIfVersion", selector, update), func(op *Operation) error {
+		return c.ICollection.UpdateIfVersion(op.Filter, version, op.Update)
+	})
+}
+
+func (c *interceptedCollection) ReplaceIfVersion(selector interface{}, version int64, doc interface{}) error {
+	return c.chain.run(c.writeOp("ReplaceIfVersion", selector, doc), func(op *Operation) error {
+		return c.ICollection.ReplaceIfVersion(op.Filter, version, op.Update)
+	})
+}
+
+func (c *interceptedCollection) EnsureIndexKey(key ...string) error {
+	return c.chain.run(c.op("EnsureIndexKey", OperationCommand), func(*Operation) error {
+		return c.ICollection.EnsureIndexKey(key...)
+	})
+}
+
+func (c *interceptedCollection) EnsureIndex(index Index) error {
+	return c.chain.run(c.op("EnsureIndex", OperationCommand), func(*Operation) error {
+		return c.ICollection.EnsureIndex(index)
+	})
+}
+
+func (c *interceptedCollection) MustEnsureIndex(index Index) {
+	mustEnsureIndex(c, index)
+}
+
+func (c *interceptedCollection) DropIndex(key ...string) error {
+	return c.chain.run(c.op("DropIndex", OperationCommand), func(*Operation) error {
+		return c.ICollection.DropIndex(key...)
+	})
+}
+
+func (c *interceptedCollection) DropIndexName(name string) error {
+	return c.chain.run(c.op("DropIndexName", OperationCommand), func(*Operation) error {
+		return c.ICollection.DropIndexName(name)
+	})
+}
+
+func (c *interceptedCollection) Indexes() (indexes []Index, err error) {
+	err = c.chain.run(c.op("Indexes", OperationCommand), func(*Operation) error {
+		indexes, err = c.ICollection.Indexes()
+		return err
+	})
+	return
+}
+
+func (c *interceptedCollection) DropCollection() error {
+	return c.chain.run(c.op("DropCollection", OperationCommand), func(*Operation) error {
+		return c.ICollection.DropCollection()
+	})
+}
+
+func (c *interceptedCollection) Create(info *CollectionInfo) error {
+	return c.chain.run(c.op("Create", OperationCommand), func(*Operation) error {
+		return c.ICollection.Create(info)
+	})
+}
+
+func (c *interceptedCollection) op(name string, kind OperationKind) *Operation {
+	return &Operation{Name: name, Kind: kind, Database: c.db, Collection: c.name, session: c.session}
+}
+
+func (c *interceptedCollection) session() ISession {
+	if db := c.ICollection.Database(); db != nil {
+		return db.Session()
+	}
+	return nil
+}
+
+func (c *interceptedCollection) writeOp(name string, selector, update interface{}) *Operation {
+	op := c.op(name, OperationWrite)
+	op.Filter = selector
+	op.Update = update
+	return op
+}
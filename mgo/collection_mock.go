@@ -1,25 +1,24 @@
 package mgo
 
-// TODO: Improve functionality like QueryMock
-
-// CollectionMock: Is the mock struct use for ICollection mocking.
+// CollectionMock: Is the mock struct use for ICollection mocking. The behavior of its functions can be set with When
+// and WhenReturn, the handlers receive the arguments of the calls so the selectors and updates sent can be asserted.
+// The functions with no behavior set are relayed to the wrapped ICollection.
 type CollectionMock struct {
 	ICollection
+	*MockBase
 	findMocks   map[string]IQuery
 	insertMocks map[string]error
-	times       map[string]int
 }
 
 // MockCollection returns a new instance of ICollection for mocking purposes
 //
-//   {t}:    The instance of *testing.T used in the test
 //   {col}:  An optional instance of ICollection to handle real calls if wanted.
 //
 func MockCollection(col ...ICollection) *CollectionMock {
 	ret := &CollectionMock{
+		MockBase:    newMock(),
 		findMocks:   make(map[string]IQuery),
 		insertMocks: make(map[string]error),
-		times:       make(map[string]int),
 	}
 	if len(col) > 0 {
 		ret.ICollection = col[0]
@@ -35,10 +34,6 @@ func (m *CollectionMock) WhenInsert(docs interface{}, output error) {
 	m.insertMocks[getKey(docs)] = output
 }
 
-func (m *CollectionMock) Times(funcName string) int {
-	return m.times[funcName]
-}
-
 func (m *CollectionMock) Clear(funcName string) {
 	for k := range m.times {
 		delete(m.times, k)
@@ -52,6 +47,9 @@ func (m *CollectionMock) Clear(funcName string) {
 }
 
 func (m *CollectionMock) Find(query interface{}) IQuery {
+	if m.mocked("Find") {
+		return m.returnQuery("Find", query)
+	}
 	m.times["Find"]++
 	if _, ok := m.findMocks[getKey(query)]; ok {
 		return m.findMocks[getKey(query)]
@@ -62,6 +60,9 @@ func (m *CollectionMock) Find(query interface{}) IQuery {
 }
 
 func (m *CollectionMock) Insert(docs ...interface{}) error {
+	if m.mocked("Insert") {
+		return m.returnError("Insert", docs...)
+	}
 	m.times["Insert"]++
 	if _, ok := m.insertMocks[getKey(docs)]; ok {
 		return m.insertMocks[getKey(docs)]
@@ -71,3 +72,59 @@ func (m *CollectionMock) Insert(docs ...interface{}) error {
 
 	return nil
 }
+
+func (m *CollectionMock) FindId(id interface{}) IQuery {
+	if m.mocked("FindId") {
+		return m.returnQuery("FindId", id)
+	}
+	return m.ICollection.FindId(id)
+}
+
+func (m *CollectionMock) EnsureIndex(index Index) error {
+	if m.mocked("EnsureIndex") {
+		return m.returnError("EnsureIndex", index)
+	}
+	return m.ICollection.EnsureIndex(index)
+}
+
+func (m *CollectionMock) Update(selector interface{}, update interface{}) error {
+	if m.mocked("Update") {
+		return m.returnError("Update", selector, update)
+	}
+	return m.ICollection.Update(selector, update)
+}
+
+func (m *CollectionMock) UpdateId(id interface{}, update interface{}) error {
+	if m.mocked("UpdateId") {
+		return m.returnError("UpdateId", id, update)
+	}
+	return m.ICollection.UpdateId(id, update)
+}
+
+func (m *CollectionMock) UpdateAll(selector interface{}, update interface{}) (*ChangeInfo, error) {
+	if m.mocked("UpdateAll") {
+		return m.returnChangeInfo("UpdateAll", selector, update)
+	}
+	return m.ICollection.UpdateAll(selector, update)
+}
+
+func (m *CollectionMock) UpsertId(id interface{}, update interface{}) (*ChangeInfo, error) {
+	if m.mocked("UpsertId") {
+		return m.returnChangeInfo("UpsertId", id, update)
+	}
+	return m.ICollection.UpsertId(id, update)
+}
+
+func (m *CollectionMock) Remove(selector interface{}) error {
+	if m.mocked("Remove") {
+		return m.returnError("Remove", selector)
+	}
+	return m.ICollection.Remove(selector)
+}
+
+func (m *CollectionMock) RemoveAll(selector interface{}) (*ChangeInfo, error) {
+	if m.mocked("RemoveAll") {
+		return m.returnChangeInfo("RemoveAll", selector)
+	}
+	return m.ICollection.RemoveAll(selector)
+}
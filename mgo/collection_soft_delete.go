@@ -0,0 +1,305 @@
+package mgo
+
+import (
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// DefaultDeletedAtField is the default document field that holds the time a document was soft deleted.
+const DefaultDeletedAtField = "deleted_at"
+
+const (
+	softDeleteExclude softDeleteMode = iota
+	softDeleteInclude
+	softDeleteOnly
+)
+
+type softDeleteMode int
+
+// SoftDeleteOptions defines the behavior of a soft delete collection. See NewSoftDeleteCollection.
+type SoftDeleteOptions struct {
+	// DeletedAtField is the field set with the time a document was deleted. Defaults to DefaultDeletedAtField.
+	DeletedAtField string
+	// Now returns the time to use when deleting documents. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// ISoftDeleteCollection is an ICollection where documents are soft deleted. See NewSoftDeleteCollection.
+type ISoftDeleteCollection interface {
+	ICollection
+
+	// WithDeleted returns a view of the collection where Find, FindId, Count and Pipe also include soft deleted documents.
+	WithDeleted() ISoftDeleteCollection
+
+	// OnlyDeleted returns a view of the collection where Find, FindId, Count and Pipe only include soft deleted documents.
+	OnlyDeleted() ISoftDeleteCollection
+
+	// Restore finds a single soft deleted document matching the provided selector and restores it.
+	Restore(selector interface{}) error
+
+	// RestoreId is a convenience helper equivalent to:
+	//
+	//     Restore(bson.M{"_id": id})
+	//
+	RestoreId(id interface{}) error
+
+	// RestoreAll finds all soft deleted documents matching the provided selector and restores them.
+	RestoreAll(selector interface{}) (*ChangeInfo, error)
+
+	// Purge finds a single document matching the provided selector in the current view and permanently removes it from
+	// the database. Use OnlyDeleted() or WithDeleted() to purge soft deleted documents.
+	Purge(selector interface{}) error
+
+	// PurgeId is a convenience helper equivalent to:
+	//
+	//     Purge(bson.M{"_id": id})
+	//
+	PurgeId(id interface{}) error
+
+	// PurgeAll finds all documents matching the provided selector in the current view and permanently removes them from
+	// the database. Use OnlyDeleted() or WithDeleted() to purge soft deleted documents.
+	PurgeAll(selector interface{}) (*ChangeInfo, error)
+}
+
+// NewSoftDeleteCollection decorates the provided collection so documents are soft deleted:
+//
+//    - Remove, RemoveId and RemoveAll set the deletion time in the documents instead of removing them, as well as the
+//      removals queued in Bulk.
+//    - Find, FindId, Count and Pipe exclude the soft deleted documents.
+//
+// Other operations, such as the update operations, are not affected. See ISoftDeleteCollection for the functions that
+// allow to include soft deleted documents, restore them or remove them permanently.
+//
+//   {col}   - The collection to decorate
+//   {opts}  - (Optional) The field and clock to use
+//
+func NewSoftDeleteCollection(col ICollection, opts ...SoftDeleteOptions) ISoftDeleteCollection {
+	cfg := SoftDeleteOptions{}
+	if len(opts) > 0 {
+		cfg = opts[0]
+	}
+	if cfg.DeletedAtField == "" {
+		cfg.DeletedAtField = DefaultDeletedAtField
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return &softDeleteCollection{ICollection: col, opts: cfg}
+}
+
+type softDeleteCollection struct {
+	ICollection
+	opts SoftDeleteOptions
+	mode softDeleteMode
+}
+
+func (c *softDeleteCollection) WithDeleted() ISoftDeleteCollection {
+	return c.view(softDeleteInclude)
+}
+
+func (c *softDeleteCollection) OnlyDeleted() ISoftDeleteCollection {
+	return c.view(softDeleteOnly)
+}
+
+func (c *softDeleteCollection) With(s ISession) ICollection {
+	return &softDeleteCollection{ICollection: c.ICollection.With(s), opts: c.opts, mode: c.mode}
+}
+
+func (c *softDeleteCollection) Find(query interface{}) IQuery {
+	filter := c.filter()
+	if filter == nil {
+		return c.ICollection.Find(query)
+	}
+	sel, err := mergeFilter(query, filter)
+	if err != nil {
+		// Never run the query unfiltered, let the server report the error.
+		return c.ICollection.Find(bson.M{"$and": []interface{}{query, filter}})
+	}
+	return c.ICollection.Find(sel)
+}
+
+func (c *softDeleteCollection) FindId(id interface{}) IQuery {
+	if c.filter() == nil {
+		return c.ICollection.FindId(id)
+	}
+	return c.Find(bson.M{"_id": id})
+}
+
+func (c *softDeleteCollection) Count() (int, error) {
+	if c.filter() == nil {
+		return c.ICollection.Count()
+	}
+	return c.Find(nil).Count()
+}
+
+func (c *softDeleteCollection) Pipe(pipeline interface{}) IPipe {
+	filter := c.filter()
+	if filter == nil {
+		return c.ICollection.Pipe(pipeline)
+	}
+
+	stages := append([]interface{}{bson.M{"$match": filter}}, pipelineStages(pipeline)...)
+	return c.ICollection.Pipe(stages)
+}
+
+func (c *softDeleteCollection) Remove(selector interface{}) error {
+	sel, err := mergeFilter(selector, c.notDeleted())
+	if err != nil {
+		return err
+	}
+	return c.ICollection.Update(sel, c.deleteUpdate())
+}
+
+func (c *softDeleteCollection) RemoveId(id interface{}) error {
+	return c.Remove(bson.M{"_id": id})
+}
+
+func (c *softDeleteCollection) RemoveAll(selector interface{}) (*ChangeInfo, error) {
+	sel, err := mergeFilter(selector, c.notDeleted())
+	if err != nil {
+		return nil, err
+	}
+	info, err := c.ICollection.UpdateAll(sel, c.deleteUpdate())
+	if info != nil {
+		info.Removed = info.Updated
+	}
+	return info, err
+}
+
+func (c *softDeleteCollection) Bulk() IBulk {
+	return &softDeleteBulk{IBulk: c.ICollection.Bulk(), col: c}
+}
+
+func (c *softDeleteCollection) BulkUpsert(pairs ...interface{}) (*BulkResult, error) {
+	return c.Bulk().Upsert(pairs...).Run()
+}
+
+func (c *softDeleteCollection) Restore(selector interface{}) error {
+	sel, err := mergeFilter(selector, c.deleted())
+	if err != nil {
+		return err
+	}
+	return c.ICollection.Update(sel, c.restoreUpdate())
+}
+
+func (c *softDeleteCollection) RestoreId(id interface{}) error {
+	return c.Restore(bson.M{"_id": id})
+}
+
+func (c *softDeleteCollection) RestoreAll(selector interface{}) (*ChangeInfo, error) {
+	sel, err := mergeFilter(selector, c.deleted())
+	if err != nil {
+		return nil, err
+	}
+	return c.ICollection.UpdateAll(sel, c.restoreUpdate())
+}
+
+func (c *softDeleteCollection) Purge(selector interface{}) error {
+	sel, err := c.purgeSelector(selector)
+	if err != nil {
+		return err
+	}
+	return c.ICollection.Remove(sel)
+}
+
+func (c *softDeleteCollection) PurgeId(id interface{}) error {
+	return c.Purge(bson.M{"_id": id})
+}
+
+func (c *softDeleteCollection) PurgeAll(selector interface{}) (*ChangeInfo, error) {
+	sel, err := c.purgeSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	return c.ICollection.RemoveAll(sel)
+}
+
+func (c *softDeleteCollection) view(mode softDeleteMode) ISoftDeleteCollection {
+	return &softDeleteCollection{ICollection: c.ICollection, opts: c.opts, mode: mode}
+}
+
+// filter returns the filter to apply to the read operations depending on the mode of the collection.
+func (c *softDeleteCollection) filter() bson.M {
+	switch c.mode {
+	case softDeleteExclude:
+		return c.notDeleted()
+	case softDeleteOnly:
+		return c.deleted()
+	}
+	return nil
+}
+
+func (c *softDeleteCollection) notDeleted() bson.M {
+	return bson.M{c.opts.DeletedAtField: nil}
+}
+
+func (c *softDeleteCollection) deleted() bson.M {
+	return bson.M{c.opts.DeletedAtField: bson.M{"$ne": nil}}
+}
+
+func (c *softDeleteCollection) deleteUpdate() bson.M {
+	return bson.M{"$set": bson.M{c.opts.DeletedAtField: c.opts.Now()}}
+}
+
+func (c *softDeleteCollection) restoreUpdate() bson.M {
+	return bson.M{"$unset": bson.M{c.opts.DeletedAtField: ""}}
+}
+
+func (c *softDeleteCollection) purgeSelector(selector interface{}) (interface{}, error) {
+	filter := c.filter()
+	if filter == nil {
+		return selector, nil
+	}
+	return mergeFilter(selector, filter)
+}
+
+// softDeleteBulk is the IBulk of a soft delete collection, the removals are queued as updates that set the deletion
+// time. The preparation errors are returned by Run.
+type softDeleteBulk struct {
+	IBulk
+	col *softDeleteCollection
+	err error
+}
+
+func (b *softDeleteBulk) Unordered() IBulk {
+	b.IBulk = b.IBulk.Unordered()
+	return b
+}
+
+func (b *softDeleteBulk) Remove(selectors ...interface{}) IBulk {
+	if pairs, ok := b.deletes(selectors); ok {
+		b.IBulk = b.IBulk.Update(pairs...)
+	}
+	return b
+}
+
+func (b *softDeleteBulk) RemoveAll(selectors ...interface{}) IBulk {
+	if pairs, ok := b.deletes(selectors); ok {
+		b.IBulk = b.IBulk.UpdateAll(pairs...)
+	}
+	return b
+}
+
+func (b *softDeleteBulk) Run() (*BulkResult, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	return b.IBulk.Run()
+}
+
+// deletes returns the update pairs that soft delete the documents matched by the selectors.
+func (b *softDeleteBulk) deletes(selectors []interface{}) ([]interface{}, bool) {
+	pairs := make([]interface{}, 0, len(selectors)*2)
+	for _, s := range selectors {
+		sel, err := mergeFilter(s, b.col.notDeleted())
+		if err != nil {
+			if b.err == nil {
+				b.err = err
+			}
+			return nil, false
+		}
+		pairs = append(pairs, sel, b.col.deleteUpdate())
+	}
+	return pairs, true
+}
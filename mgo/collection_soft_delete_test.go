@@ -0,0 +1,92 @@
+package mgo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func newTestSoftDelete() (*recorderCollection, ISoftDeleteCollection, time.Time) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	rec := &recorderCollection{}
+	return rec, NewSoftDeleteCollection(rec, SoftDeleteOptions{Now: func() time.Time { return now }}), now
+}
+
+func TestSoftDeleteCollection_Remove(t *testing.T) {
+	rec, col, now := newTestSoftDelete()
+
+	assert.NoError(t, col.RemoveId("a"))
+	assert.Equal(t, "Update", rec.Names()[0])
+	assert.Equal(t, []interface{}{
+		bson.M{"_id": "a", "deleted_at": nil},
+		bson.M{"$set": bson.M{"deleted_at": now}},
+	}, rec.Last())
+
+	info, err := col.RemoveAll(bson.M{"status": "old"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, info.Removed)
+	assert.Equal(t, "UpdateAll", rec.Names()[1])
+	assert.Equal(t, bson.M{"status": "old", "deleted_at": nil}, rec.Last()[0])
+}
+
+func TestSoftDeleteCollection_Read(t *testing.T) {
+	rec, col, _ := newTestSoftDelete()
+
+	col.Find(bson.M{"name": "x"})
+	assert.Equal(t, bson.M{"name": "x", "deleted_at": nil}, rec.Last()[0])
+
+	col.FindId("a")
+	assert.Equal(t, bson.M{"_id": "a", "deleted_at": nil}, rec.Last()[0])
+
+	col.Find(bson.M{"deleted_at": bson.M{"$lt": 1}})
+	assert.Equal(t, bson.M{"$and": []interface{}{bson.M{"deleted_at": bson.M{"$lt": 1}}, bson.M{"deleted_at": nil}}}, rec.Last()[0])
+
+	col.Pipe([]bson.M{{"$group": bson.M{"_id": "$name"}}})
+	assert.Equal(t, []interface{}{
+		bson.M{"$match": bson.M{"deleted_at": nil}},
+		bson.M{"$group": bson.M{"_id": "$name"}},
+	}, rec.Last()[0])
+
+	// Queries that cannot be merged are still filtered
+	col.Find("name")
+	assert.Equal(t, bson.M{"$and": []interface{}{"name", bson.M{"deleted_at": nil}}}, rec.Last()[0])
+
+	col.OnlyDeleted().Find(nil)
+	assert.Equal(t, bson.M{"deleted_at": bson.M{"$ne": nil}}, rec.Last()[0])
+
+	col.WithDeleted().Find(bson.M{"name": "x"})
+	assert.Equal(t, bson.M{"name": "x"}, rec.Last()[0])
+}
+
+func TestSoftDeleteCollection_RestoreAndPurge(t *testing.T) {
+	rec, col, _ := newTestSoftDelete()
+
+	assert.NoError(t, col.RestoreId("a"))
+	assert.Equal(t, []interface{}{
+		bson.M{"_id": "a", "deleted_at": bson.M{"$ne": nil}},
+		bson.M{"$unset": bson.M{"deleted_at": ""}},
+	}, rec.Last())
+
+	_, err := col.OnlyDeleted().PurgeAll(bson.M{})
+	assert.NoError(t, err)
+	assert.Equal(t, [][]interface{}{{bson.M{"deleted_at": bson.M{"$ne": nil}}}}, rec.ArgsOf("RemoveAll"))
+
+	assert.NoError(t, col.WithDeleted().PurgeId("a"))
+	assert.Equal(t, [][]interface{}{{bson.M{"_id": "a"}}}, rec.ArgsOf("Remove"))
+}
+
+func TestSoftDeleteCollection_Bulk(t *testing.T) {
+	rec, col, now := newTestSoftDelete()
+
+	_, err := col.Bulk().Remove(bson.M{"_id": "a"}).RemoveAll(bson.M{"status": "old"}).Run()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Bulk.Update", "Bulk.UpdateAll"}, rec.Names())
+	assert.Equal(t, []interface{}{bson.M{"_id": "a", "deleted_at": nil}, bson.M{"$set": bson.M{"deleted_at": now}}}, rec.Args()[0])
+	assert.Equal(t, []interface{}{bson.M{"status": "old", "deleted_at": nil}, bson.M{"$set": bson.M{"deleted_at": now}}}, rec.Args()[1])
+
+	_, err = col.BulkUpsert(bson.M{"_id": "b"}, bson.M{"$set": bson.M{"name": "y"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "Bulk.Upsert", rec.Names()[2])
+}
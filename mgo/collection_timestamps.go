@@ -0,0 +1,386 @@
+package mgo
+
+import (
+	"reflect"
+	"time"
+)
+
+const (
+	// DefaultCreatedAtField is the default document field that holds the creation time of a document.
+	DefaultCreatedAtField = "created_at"
+
+	// DefaultUpdatedAtField is the default document field that holds the last modification time of a document.
+	DefaultUpdatedAtField = "updated_at"
+
+	// DefaultVersionField is the default document field that holds the version of a document.
+	DefaultVersionField = "version"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// TimestampOptions defines the fields maintained by a timestamped collection. See NewTimestampedCollection.
+type TimestampOptions struct {
+	// CreatedAtField is the field set with the creation time of a document. Defaults to DefaultCreatedAtField.
+	CreatedAtField string
+	// UpdatedAtField is the field set with the last modification time of a document. Defaults to DefaultUpdatedAtField.
+	UpdatedAtField string
+	// VersionField is the field incremented every time the document is modified. Defaults to DefaultVersionField.
+	VersionField string
+	// DisableVersion disables the versioning of documents.
+	DisableVersion bool
+	// Now returns the time to use for the timestamps. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// NewTimestampedCollection decorates the provided collection so the documents written through it are timestamped and
+// versioned automatically:
+//
+//    - Insert sets the creation and modification times and initializes the version to 1. Values already present in
+//      the documents are preserved. Pointers to structs are updated in place.
+//    - Update, UpdateId, UpdateAll, Upsert, UpsertId and Apply (on queries created with Find or FindId) set the
+//      modification time using '$set' and increment the version using '$inc'. Upserts also set the creation time using
+//      '$setOnInsert'. Fields already present in the update operators are not overridden.
+//    - The operations queued in Bulk, and BulkUpsert, are prepared the same way.
+//
+// When a replacement document is used instead of update operators, it is considered the full new state of the
+// document: the modification time is set, the version is incremented from the value contained in the document, and
+// the creation time is only set for upserts if the document does not provide one.
+//
+//   {col}   - The collection to decorate
+//   {opts}  - (Optional) The fields and clock to use
+//
+func NewTimestampedCollection(col ICollection, opts ...TimestampOptions) ICollection {
+	cfg := TimestampOptions{}
+	if len(opts) > 0 {
+		cfg = opts[0]
+	}
+	if cfg.CreatedAtField == "" {
+		cfg.CreatedAtField = DefaultCreatedAtField
+	}
+	if cfg.UpdatedAtField == "" {
+		cfg.UpdatedAtField = DefaultUpdatedAtField
+	}
+	if cfg.VersionField == "" {
+		cfg.VersionField = DefaultVersionField
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return &timestampedCollection{ICollection: col, opts: cfg}
+}
+
+type timestampedCollection struct {
+	ICollection
+	opts TimestampOptions
+}
+
+type timestampedQuery struct {
+	*queryDecorator
+	col *timestampedCollection
+}
+
+func (c *timestampedCollection) With(s ISession) ICollection {
+	return &timestampedCollection{ICollection: c.ICollection.With(s), opts: c.opts}
+}
+
+func (c *timestampedCollection) Find(query interface{}) IQuery {
+	return c.newQuery(c.ICollection.Find(query))
+}
+
+func (c *timestampedCollection) FindId(id interface{}) IQuery {
+	return c.newQuery(c.ICollection.FindId(id))
+}
+
+func (c *timestampedCollection) Insert(docs ...interface{}) error {
+	prepared := make([]interface{}, len(docs))
+	for i, doc := range docs {
+		d, err := c.prepareInsert(doc)
+		if err != nil {
+			return err
+		}
+		prepared[i] = d
+	}
+	return c.ICollection.Insert(prepared...)
+}
+
+func (c *timestampedCollection) Update(selector interface{}, update interface{}) error {
+	u, err := c.prepareUpdate(update, false)
+	if err != nil {
+		return err
+	}
+	return c.ICollection.Update(selector, u)
+}
+
+func (c *timestampedCollection) UpdateId(id interface{}, update interface{}) error {
+	u, err := c.prepareUpdate(update, false)
+	if err != nil {
+		return err
+	}
+	return c.ICollection.UpdateId(id, u)
+}
+
+func (c *timestampedCollection) UpdateAll(selector interface{}, update interface{}) (*ChangeInfo, error) {
+	u, err := c.prepareUpdate(update, false)
+	if err != nil {
+		return nil, err
+	}
+	return c.ICollection.UpdateAll(selector, u)
+}
+
+func (c *timestampedCollection) Upsert(selector interface{}, update interface{}) (*ChangeInfo, error) {
+	u, err := c.prepareUpdate(update, true)
+	if err != nil {
+		return nil, err
+	}
+	return c.ICollection.Upsert(selector, u)
+}
+
+func (c *timestampedCollection) UpsertId(id interface{}, update interface{}) (*ChangeInfo, error) {
+	u, err := c.prepareUpdate(update, true)
+	if err != nil {
+		return nil, err
+	}
+	return c.ICollection.UpsertId(id, u)
+}
+
+func (c *timestampedCollection) Bulk() IBulk {
+	return &timestampedBulk{IBulk: c.ICollection.Bulk(), col: c}
+}
+
+func (c *timestampedCollection) BulkUpsert(pairs ...interface{}) (*BulkResult, error) {
+	return c.Bulk().Upsert(pairs...).Run()
+}
+
+func (c *timestampedCollection) UpdateIfVersion(selector interface{}, version int64, update interface{}) error {
+	return updateIfVersion(c, c.opts.VersionField, selector, version, update)
+}
+
+// ReplaceIfVersion stores the expected version in the replacement document since Update increments the version of
+// replacement documents.
+func (c *timestampedCollection) ReplaceIfVersion(selector interface{}, version int64, doc interface{}) error {
+	next := version
+	if c.opts.DisableVersion {
+		next++
+	}
+	return replaceIfVersion(c, c.opts.VersionField, selector, version, doc, next)
+}
+
+func (c *timestampedCollection) newQuery(q IQuery) IQuery {
+	ret := &timestampedQuery{queryDecorator: &queryDecorator{IQuery: q}, col: c}
+	ret.self = ret
+	return ret
+}
+
+// prepareInsert updates pointers to structs in place only if the struct holds every field maintained by the
+// collection, otherwise the document is converted to a map so the struct is never left partially updated.
+func (c *timestampedCollection) prepareInsert(doc interface{}) (interface{}, error) {
+	now := c.opts.Now()
+	created := structField(doc, c.opts.CreatedAtField)
+	updated := structField(doc, c.opts.UpdatedAtField)
+	version := structField(doc, c.opts.VersionField)
+
+	if isTime(created) && isTime(updated) && (c.opts.DisableVersion || isInt(version)) {
+		setTime(created, now, false)
+		setTime(updated, now, true)
+		if !c.opts.DisableVersion && version.Int() == 0 {
+			version.SetInt(1)
+		}
+		return doc, nil
+	}
+
+	m, err := toDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+	if isEmptyValue(m[c.opts.CreatedAtField]) {
+		m[c.opts.CreatedAtField] = now
+	}
+	m[c.opts.UpdatedAtField] = now
+	if !c.opts.DisableVersion && isEmptyValue(m[c.opts.VersionField]) {
+		m[c.opts.VersionField] = 1
+	}
+	return m, nil
+}
+
+func (c *timestampedCollection) prepareUpdate(update interface{}, upsert bool) (interface{}, error) {
+	now := c.opts.Now()
+
+	m, err := toDocument(update)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isOperatorDocument(m) {
+		m[c.opts.UpdatedAtField] = now
+		if upsert && isEmptyValue(m[c.opts.CreatedAtField]) {
+			m[c.opts.CreatedAtField] = now
+		}
+		if !c.opts.DisableVersion {
+			m[c.opts.VersionField] = incrementVersion(m[c.opts.VersionField])
+		}
+		return m, nil
+	}
+
+	if err := setOperatorField(m, "$set", c.opts.UpdatedAtField, now); err != nil {
+		return nil, err
+	}
+	if !c.opts.DisableVersion {
+		if err := setOperatorField(m, "$inc", c.opts.VersionField, 1); err != nil {
+			return nil, err
+		}
+	}
+	if upsert {
+		if err := setOperatorField(m, "$setOnInsert", c.opts.CreatedAtField, now); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func (q *timestampedQuery) Apply(change Change, result interface{}) (*ChangeInfo, error) {
+	if !change.Remove {
+		u, err := q.col.prepareUpdate(change.Update, change.Upsert)
+		if err != nil {
+			return nil, err
+		}
+		change.Update = u
+	}
+	return q.IQuery.Apply(change, result)
+}
+
+// setTime assigns the provided time to a field of type time.Time or *time.Time. If 'override' is false the value is
+// only assigned if the field is empty.
+func setTime(f reflect.Value, t time.Time, override bool) {
+	if f.Type() == timeType {
+		if override || f.Interface().(time.Time).IsZero() {
+			f.Set(reflect.ValueOf(t))
+		}
+		return
+	}
+	if override || f.IsNil() || f.Elem().Interface().(time.Time).IsZero() {
+		f.Set(reflect.ValueOf(&t))
+	}
+}
+
+// isTime indicates whether the field is a settable time.Time or *time.Time.
+func isTime(f reflect.Value) bool {
+	if !f.IsValid() {
+		return false
+	}
+	return f.Type() == timeType || f.Kind() == reflect.Ptr && f.Type().Elem() == timeType
+}
+
+func isInt(f reflect.Value) bool {
+	if !f.IsValid() {
+		return false
+	}
+	switch f.Kind() {
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+func isEmptyValue(v interface{}) bool {
+	switch val := v.(type) {
+	case nil:
+		return true
+	case time.Time:
+		return val.IsZero()
+	case int:
+		return val == 0
+	case int32:
+		return val == 0
+	case int64:
+		return val == 0
+	case float64:
+		return val == 0
+	}
+	return false
+}
+
+func incrementVersion(v interface{}) interface{} {
+	switch val := v.(type) {
+	case int:
+		return val + 1
+	case int32:
+		return val + 1
+	case int64:
+		return val + 1
+	case float64:
+		return val + 1
+	}
+	return 1
+}
+
+// timestampedBulk is the IBulk of a timestamped collection, the preparation errors are returned by Run.
+type timestampedBulk struct {
+	IBulk
+	col *timestampedCollection
+	err error
+}
+
+func (b *timestampedBulk) Unordered() IBulk {
+	b.IBulk = b.IBulk.Unordered()
+	return b
+}
+
+func (b *timestampedBulk) Insert(docs ...interface{}) IBulk {
+	prepared := make([]interface{}, 0, len(docs))
+	for _, doc := range docs {
+		d, err := b.col.prepareInsert(doc)
+		if err != nil {
+			b.setErr(err)
+			return b
+		}
+		prepared = append(prepared, d)
+	}
+	b.IBulk = b.IBulk.Insert(prepared...)
+	return b
+}
+
+func (b *timestampedBulk) Update(pairs ...interface{}) IBulk {
+	if p, ok := b.pairs(pairs, false); ok {
+		b.IBulk = b.IBulk.Update(p...)
+	}
+	return b
+}
+
+func (b *timestampedBulk) UpdateAll(pairs ...interface{}) IBulk {
+	if p, ok := b.pairs(pairs, false); ok {
+		b.IBulk = b.IBulk.UpdateAll(p...)
+	}
+	return b
+}
+
+func (b *timestampedBulk) Upsert(pairs ...interface{}) IBulk {
+	if p, ok := b.pairs(pairs, true); ok {
+		b.IBulk = b.IBulk.Upsert(p...)
+	}
+	return b
+}
+
+func (b *timestampedBulk) Run() (*BulkResult, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	return b.IBulk.Run()
+}
+
+func (b *timestampedBulk) pairs(pairs []interface{}, upsert bool) ([]interface{}, bool) {
+	ret, err := prepareBulkPairs(pairs, func(selector, update interface{}) (interface{}, interface{}, error) {
+		u, err := b.col.prepareUpdate(update, upsert)
+		return selector, u, err
+	})
+	if err != nil {
+		b.setErr(err)
+		return nil, false
+	}
+	return ret, true
+}
+
+func (b *timestampedBulk) setErr(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
@@ -0,0 +1,245 @@
+package mgo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jucardi/go-mongodb-lib/testutils"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// recorderCollection records the writes received, the bulk operations and the Find, Apply and Pipe calls included.
+type recorderCollection struct {
+	ICollection
+	testutils.Recorder
+	queries    []*QueryMock
+	err        error
+	count      int
+	allHandler func(args ...interface{}) []interface{}
+}
+
+func (c *recorderCollection) Name() string {
+	return "records"
+}
+
+func (c *recorderCollection) FullName() string {
+	return "test.records"
+}
+
+func (c *recorderCollection) Database() IDatabase {
+	return &namedDatabase{name: "test"}
+}
+
+func (c *recorderCollection) DropCollection() error {
+	c.Record("DropCollection")
+	return c.err
+}
+
+func (c *recorderCollection) Bulk() IBulk {
+	return &recorderBulk{col: c}
+}
+
+// recorderBulk records the queued operations in its collection, prefixed with 'Bulk.'.
+type recorderBulk struct {
+	IBulk
+	col *recorderCollection
+}
+
+func (b *recorderBulk) Unordered() IBulk {
+	return b
+}
+
+func (b *recorderBulk) Insert(docs ...interface{}) IBulk {
+	b.col.Record("Bulk.Insert", docs...)
+	return b
+}
+
+func (b *recorderBulk) Update(pairs ...interface{}) IBulk {
+	b.col.Record("Bulk.Update", pairs...)
+	return b
+}
+
+func (b *recorderBulk) UpdateAll(pairs ...interface{}) IBulk {
+	b.col.Record("Bulk.UpdateAll", pairs...)
+	return b
+}
+
+func (b *recorderBulk) Upsert(pairs ...interface{}) IBulk {
+	b.col.Record("Bulk.Upsert", pairs...)
+	return b
+}
+
+func (b *recorderBulk) Remove(selectors ...interface{}) IBulk {
+	b.col.Record("Bulk.Remove", selectors...)
+	return b
+}
+
+func (b *recorderBulk) RemoveAll(selectors ...interface{}) IBulk {
+	b.col.Record("Bulk.RemoveAll", selectors...)
+	return b
+}
+
+func (b *recorderBulk) Run() (*BulkResult, error) {
+	return &BulkResult{}, b.col.err
+}
+
+func (c *recorderCollection) Insert(docs ...interface{}) error {
+	c.Record("Insert", docs...)
+	return nil
+}
+
+func (c *recorderCollection) Update(selector interface{}, update interface{}) error {
+	c.Record("Update", selector, update)
+	return c.err
+}
+
+func (c *recorderCollection) UpdateAll(selector interface{}, update interface{}) (*ChangeInfo, error) {
+	c.Record("UpdateAll", selector, update)
+	return &ChangeInfo{Updated: 2}, nil
+}
+
+func (c *recorderCollection) Upsert(selector interface{}, update interface{}) (*ChangeInfo, error) {
+	c.Record("Upsert", selector, update)
+	return &ChangeInfo{}, nil
+}
+
+func (c *recorderCollection) Remove(selector interface{}) error {
+	c.Record("Remove", selector)
+	return c.err
+}
+
+func (c *recorderCollection) RemoveAll(selector interface{}) (*ChangeInfo, error) {
+	c.Record("RemoveAll", selector)
+	return &ChangeInfo{Removed: 2}, nil
+}
+
+func (c *recorderCollection) Pipe(pipeline interface{}) IPipe {
+	c.Record("Pipe", pipeline)
+	return nil
+}
+
+func (c *recorderCollection) Find(query interface{}) IQuery {
+	c.Record("Find", query)
+	q := MockQuery()
+	q.When("Apply", func(args ...interface{}) []interface{} {
+		c.Record("Apply", args...)
+		return []interface{}{&ChangeInfo{}, nil}
+	})
+	q.WhenReturn("Count", c.count, nil)
+	if c.allHandler != nil {
+		q.When("All", c.allHandler)
+	}
+	c.queries = append(c.queries, q)
+	return q
+}
+
+type timestampedModel struct {
+	Id        string    `bson:"_id"`
+	CreatedAt time.Time `bson:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at"`
+	Version   int       `bson:"version"`
+}
+
+func newTestTimestamped() (*recorderCollection, ICollection, time.Time) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	rec := &recorderCollection{}
+	return rec, NewTimestampedCollection(rec, TimestampOptions{Now: func() time.Time { return now }}), now
+}
+
+func TestTimestampedCollection_Insert(t *testing.T) {
+	rec, col, now := newTestTimestamped()
+	created := now.Add(-time.Hour)
+
+	model := &timestampedModel{Id: "a"}
+	existing := &timestampedModel{Id: "b", CreatedAt: created, Version: 3}
+	assert.NoError(t, col.Insert(model, existing, bson.M{"_id": "c"}))
+
+	assert.Equal(t, &timestampedModel{Id: "a", CreatedAt: now, UpdatedAt: now, Version: 1}, model)
+	assert.Equal(t, &timestampedModel{Id: "b", CreatedAt: created, UpdatedAt: now, Version: 3}, existing)
+	assert.Equal(t, bson.M{"_id": "c", "created_at": now, "updated_at": now, "version": 1}, rec.Last()[2])
+
+	// The struct has no version field, it is inserted as a map and left untouched.
+	partial := &struct {
+		Id        string    `bson:"_id"`
+		CreatedAt time.Time `bson:"created_at"`
+	}{Id: "d"}
+	assert.NoError(t, col.Insert(partial))
+	assert.True(t, partial.CreatedAt.IsZero())
+	assert.Equal(t, bson.M{"_id": "d", "created_at": now, "updated_at": now, "version": 1}, rec.Last()[0])
+}
+
+func TestTimestampedCollection_Update(t *testing.T) {
+	rec, col, now := newTestTimestamped()
+
+	assert.NoError(t, col.Update(bson.M{"_id": "a"}, bson.M{"$set": bson.M{"name": "x"}}))
+	assert.Equal(t, bson.M{
+		"$set": bson.M{"name": "x", "updated_at": now},
+		"$inc": bson.M{"version": 1},
+	}, rec.Last()[1])
+
+	_, err := col.UpdateAll(bson.M{}, bson.M{"$inc": bson.M{"version": 2}})
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{
+		"$set": bson.M{"updated_at": now},
+		"$inc": bson.M{"version": 2},
+	}, rec.Last()[1])
+
+	assert.NoError(t, col.Update(bson.M{"_id": "a"}, &timestampedModel{Id: "a", Version: 4}))
+	assert.Equal(t, 5, rec.Last()[1].(bson.M)["version"])
+	assert.Equal(t, now, rec.Last()[1].(bson.M)["updated_at"])
+}
+
+func TestTimestampedCollection_Upsert(t *testing.T) {
+	rec, col, now := newTestTimestamped()
+
+	_, err := col.Upsert(bson.M{"_id": "a"}, bson.M{"$set": bson.M{"name": "x"}})
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{
+		"$set":         bson.M{"name": "x", "updated_at": now},
+		"$inc":         bson.M{"version": 1},
+		"$setOnInsert": bson.M{"created_at": now},
+	}, rec.Last()[1])
+}
+
+func TestTimestampedCollection_Apply(t *testing.T) {
+	rec, col, now := newTestTimestamped()
+
+	_, err := col.Find(bson.M{"_id": "a"}).Sort("name").Apply(Change{Update: bson.M{"$set": bson.M{"name": "x"}}, ReturnNew: true}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Find", "Apply"}, rec.Names())
+	assert.Equal(t, bson.M{
+		"$set": bson.M{"name": "x", "updated_at": now},
+		"$inc": bson.M{"version": 1},
+	}, rec.Last()[0].(Change).Update)
+	assert.Equal(t, 1, rec.queries[0].Times("Sort"))
+
+	_, err = col.Find(bson.M{"_id": "a"}).Apply(Change{Remove: true}, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, rec.Last()[0].(Change).Update)
+}
+
+func TestTimestampedCollection_Bulk(t *testing.T) {
+	rec, col, now := newTestTimestamped()
+
+	_, err := col.Bulk().Unordered().
+		Insert(bson.M{"_id": "a"}).
+		Update(bson.M{"_id": "a"}, bson.M{"$set": bson.M{"name": "x"}}).
+		Run()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Bulk.Insert", "Bulk.Update"}, rec.Names())
+	assert.Equal(t, bson.M{"_id": "a", "created_at": now, "updated_at": now, "version": 1}, rec.Args()[0][0])
+	assert.Equal(t, bson.M{"$set": bson.M{"name": "x", "updated_at": now}, "$inc": bson.M{"version": 1}}, rec.Args()[1][1])
+
+	_, err = col.BulkUpsert(bson.M{"_id": "b"}, bson.M{"$set": bson.M{"name": "y"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "Bulk.Upsert", rec.Names()[2])
+	assert.Equal(t, bson.M{
+		"$set":         bson.M{"name": "y", "updated_at": now},
+		"$inc":         bson.M{"version": 1},
+		"$setOnInsert": bson.M{"created_at": now},
+	}, rec.Last()[1])
+
+	_, err = col.Bulk().UpdateAll(bson.M{"_id": "a"}).Run()
+	assert.EqualError(t, err, "bulk update requires an even number of parameters")
+}
@@ -0,0 +1,64 @@
+package mgo
+
+import (
+	"fmt"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// updateIfVersion handles UpdateIfVersion for any ICollection, using the provided field to hold the version. Replacement
+// documents are handled by the ReplaceIfVersion of the collection, which knows how its Update sets the version. Updates
+// modifying the version field are rejected, since the version is incremented by the update.
+func updateIfVersion(c ICollection, field string, selector interface{}, version int64, update interface{}) error {
+	u, err := toDocument(update)
+	if err != nil {
+		return err
+	}
+	if !isOperatorDocument(u) {
+		return c.ReplaceIfVersion(selector, version, update)
+	}
+
+	if ok, err := hasOperatorField(u, field); err != nil {
+		return err
+	} else if ok {
+		return fmt.Errorf("the update of a versioned document cannot modify the version field '%s'", field)
+	}
+
+	sel, err := mergeFilter(selector, bson.M{field: version})
+	if err != nil {
+		return err
+	}
+	if err := setOperatorField(u, "$inc", field, 1); err != nil {
+		return err
+	}
+
+	return versionError(c, selector, c.Update(sel, u))
+}
+
+// replaceIfVersion handles ReplaceIfVersion for any ICollection. The replacement document is stored with the provided
+// 'next' version.
+func replaceIfVersion(c ICollection, field string, selector interface{}, version int64, doc interface{}, next int64) error {
+	d, err := toDocument(doc)
+	if err != nil {
+		return err
+	}
+	sel, err := mergeFilter(selector, bson.M{field: version})
+	if err != nil {
+		return err
+	}
+	d[field] = next
+
+	return versionError(c, selector, c.Update(sel, d))
+}
+
+// versionError translates the ErrNotFound returned by a versioned update into ErrConcurrentModification if a document
+// matching the original selector exists.
+func versionError(c ICollection, selector interface{}, err error) error {
+	if err != ErrNotFound {
+		return err
+	}
+	if n, countErr := c.Find(selector).Count(); countErr == nil && n > 0 {
+		return ErrConcurrentModification
+	}
+	return err
+}
@@ -0,0 +1,100 @@
+package mgo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestUpdateIfVersion(t *testing.T) {
+	rec := &recorderCollection{}
+
+	assert.NoError(t, updateIfVersion(rec, DefaultVersionField, bson.M{"_id": "a"}, 3, bson.M{"$set": bson.M{"name": "x"}}))
+	assert.Equal(t, []interface{}{
+		bson.M{"_id": "a", "version": int64(3)},
+		bson.M{"$set": bson.M{"name": "x"}, "$inc": bson.M{"version": 1}},
+	}, rec.Last())
+}
+
+func TestUpdateIfVersion_Errors(t *testing.T) {
+	rec := &recorderCollection{err: ErrNotFound}
+	update := bson.M{"$set": bson.M{"name": "x"}}
+
+	assert.Equal(t, ErrNotFound, updateIfVersion(rec, DefaultVersionField, bson.M{"_id": "a"}, 3, update))
+
+	rec.count = 1
+	assert.Equal(t, ErrConcurrentModification, updateIfVersion(rec, DefaultVersionField, bson.M{"_id": "a"}, 3, update))
+	assert.Equal(t, bson.M{"_id": "a"}, rec.Last()[0])
+
+	calls := len(rec.Names())
+	err := updateIfVersion(rec, DefaultVersionField, bson.M{"_id": "a"}, 3, bson.M{"$set": bson.M{"name": "x", "version": 7}})
+	assert.EqualError(t, err, "the update of a versioned document cannot modify the version field 'version'")
+	err = updateIfVersion(rec, DefaultVersionField, bson.M{"_id": "a"}, 3, bson.M{"$inc": bson.M{"version": 2}})
+	assert.Error(t, err)
+	assert.Len(t, rec.Names(), calls)
+}
+
+func TestReplaceIfVersion(t *testing.T) {
+	rec := &recorderCollection{}
+
+	assert.NoError(t, replaceIfVersion(rec, DefaultVersionField, bson.M{"_id": "a", "version": bson.M{"$gt": 0}}, 3, bson.M{"name": "x"}, 4))
+	assert.Equal(t, []interface{}{
+		bson.M{"$and": []interface{}{bson.M{"_id": "a", "version": bson.M{"$gt": 0}}, bson.M{"version": int64(3)}}},
+		bson.M{"name": "x", "version": int64(4)},
+	}, rec.Last())
+}
+
+func TestTimestampedCollection_ReplaceIfVersion(t *testing.T) {
+	rec, col, now := newTestTimestamped()
+
+	assert.NoError(t, col.ReplaceIfVersion(bson.M{"_id": "a"}, 3, bson.M{"name": "x"}))
+	assert.Equal(t, bson.M{"name": "x", "version": int64(4), "updated_at": now}, rec.Last()[1])
+
+	assert.NoError(t, col.UpdateIfVersion(bson.M{"_id": "a"}, 3, bson.M{"$set": bson.M{"name": "x"}}))
+	assert.Equal(t, bson.M{
+		"$set": bson.M{"name": "x", "updated_at": now},
+		"$inc": bson.M{"version": 1},
+	}, rec.Last()[1])
+}
+
+func TestTimestampedCollection_UpdateIfVersionReplacement(t *testing.T) {
+	rec, col, now := newTestTimestamped()
+
+	assert.NoError(t, col.UpdateIfVersion(bson.M{"_id": "a"}, 3, bson.M{"name": "x"}))
+	assert.Equal(t, []interface{}{
+		bson.M{"_id": "a", "version": int64(3)},
+		bson.M{"name": "x", "version": int64(4), "updated_at": now},
+	}, rec.Last())
+
+	col = NewTimestampedCollection(rec, TimestampOptions{VersionField: "rev", Now: func() time.Time { return now }})
+	assert.NoError(t, col.UpdateIfVersion(bson.M{"_id": "a"}, 3, bson.M{"name": "x"}))
+	assert.Equal(t, []interface{}{
+		bson.M{"_id": "a", "rev": int64(3)},
+		bson.M{"name": "x", "rev": int64(4), "updated_at": now},
+	}, rec.Last())
+}
+
+func TestUpdateIfVersion_Decorators(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	noop := func(op *Operation, next Invoker) error { return next(op) }
+
+	for name, decorate := range map[string]func(ICollection) ICollection{
+		"intercepted": func(c ICollection) ICollection { return InterceptCollection(c, noop) },
+		"soft delete": func(c ICollection) ICollection { return NewSoftDeleteCollection(c) },
+		"tenant":      func(c ICollection) ICollection { return NewTenantCollection(c, "acme") },
+	} {
+		rec := &recorderCollection{}
+		col := decorate(NewTimestampedCollection(rec, TimestampOptions{VersionField: "rev", Now: func() time.Time { return now }}))
+
+		assert.NoError(t, col.UpdateIfVersion(bson.M{"_id": "a"}, 3, bson.M{"$set": bson.M{"name": "x"}}), name)
+		assert.Equal(t, int64(3), rec.Last()[0].(bson.M)["rev"], name)
+		assert.Equal(t, bson.M{"rev": 1}, rec.Last()[1].(bson.M)["$inc"], name)
+
+		assert.NoError(t, col.ReplaceIfVersion(bson.M{"_id": "a"}, 3, bson.M{"name": "x"}), name)
+		assert.Equal(t, int64(3), rec.Last()[0].(bson.M)["rev"], name)
+		assert.Equal(t, int64(4), rec.Last()[1].(bson.M)["rev"], name)
+		assert.NotContains(t, rec.Last()[1], "version", name)
+	}
+}
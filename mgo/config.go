@@ -6,10 +6,11 @@ import (
 
 var (
 	// DialMaxRetries defines the maximum amount of retries to attempt when dialing to a
-	// connection to a mongodb instance
+	// connection to a mongodb instance. Used when DialOptions.MaxRetries is not set.
 	DialMaxRetries = 3
 
-	// DialRetrySleep defines the sleep time between retries when dialing for a connection to a mongodb instance.
+	// DialRetrySleep defines the sleep time between retries when dialing for a connection to a mongodb instance. Used
+	// when DialOptions.Backoff is not set.
 	DialRetrySleep = 10 * time.Second
 
 	// DialTimeout indicates the max time to wait before aborting a dialing attempt.
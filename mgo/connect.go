@@ -0,0 +1,487 @@
+package mgo
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultEnvPrefix is the prefix of the environment variables read by ConfigFromEnv if none is provided.
+const DefaultEnvPrefix = "MONGODB_"
+
+var (
+	readModes = map[string]mgo.Mode{
+		"primary":            mgo.Primary,
+		"primarypreferred":   mgo.PrimaryPreferred,
+		"secondary":          mgo.Secondary,
+		"secondarypreferred": mgo.SecondaryPreferred,
+		"nearest":            mgo.Nearest,
+		"eventual":           mgo.Eventual,
+		"monotonic":          mgo.Monotonic,
+		"strong":             mgo.Strong,
+	}
+
+	tlsVersions = map[string]uint16{
+		"1.0": tls.VersionTLS10,
+		"1.1": tls.VersionTLS11,
+		"1.2": tls.VersionTLS12,
+		"1.3": tls.VersionTLS13,
+	}
+)
+
+// Config contains the configuration of a connection, see Connect. It can be loaded from environment variables
+// (ConfigFromEnv), JSON or YAML files (ConfigFromFile) or a connection string (ConfigFromURL). The fields set in the
+// configuration override the values contained in the URL.
+//
+//   Example YAML file:
+//
+//      url: mongodb://mongo-0,mongo-1,mongo-2/app?replicaSet=rs0
+//      username: app
+//      password: secret
+//      read_mode: secondaryPreferred
+//      pool_limit: 100
+//      timeout: 10s
+//      write_concern:
+//        w: majority
+//        timeout: 5s
+//      tls:
+//        enabled: true
+//        ca_file: /etc/ssl/mongo-ca.pem
+//
+type Config struct {
+	// URL is the connection string, either the URL or the hosts are required.
+	URL string `json:"url" yaml:"url" env:"URL"`
+	// Hosts are the addresses of the seed servers, eg: "mongo-0:27017".
+	Hosts []string `json:"hosts" yaml:"hosts" env:"HOSTS"`
+	// Database is the default database of the session and the database used to authenticate if AuthSource is not set.
+	Database string `json:"database" yaml:"database" env:"DATABASE"`
+	// Username and Password are the credentials used to authenticate.
+	Username string `json:"username" yaml:"username" env:"USERNAME"`
+	Password string `json:"password" yaml:"password" env:"PASSWORD"`
+	// AuthSource is the database used to authenticate.
+	AuthSource string `json:"auth_source" yaml:"auth_source" env:"AUTH_SOURCE"`
+	// AuthMechanism is the authentication mechanism, eg: "SCRAM-SHA-1", "MONGODB-X509".
+	AuthMechanism string `json:"auth_mechanism" yaml:"auth_mechanism" env:"AUTH_MECHANISM"`
+	// ReplicaSet is the name of the replica set, if set the servers that do not belong to it are ignored.
+	ReplicaSet string `json:"replica_set" yaml:"replica_set" env:"REPLICA_SET"`
+	// Direct forces the connection to the seed servers only, instead of discovering the cluster.
+	Direct bool `json:"direct" yaml:"direct" env:"DIRECT"`
+	// PoolLimit is the maximum amount of sockets per server.
+	PoolLimit int `json:"pool_limit" yaml:"pool_limit" env:"POOL_LIMIT"`
+	// Timeout is the time to wait for a server to respond when connecting.
+	Timeout Duration `json:"timeout" yaml:"timeout" env:"TIMEOUT"`
+	// SocketTimeout is the time to wait for a non-responding socket before it is closed.
+	SocketTimeout Duration `json:"socket_timeout" yaml:"socket_timeout" env:"SOCKET_TIMEOUT"`
+	// SyncTimeout is the time an operation waits for a usable server.
+	SyncTimeout Duration `json:"sync_timeout" yaml:"sync_timeout" env:"SYNC_TIMEOUT"`
+	// ReadMode is the consistency mode of the session: primary, primaryPreferred, secondary, secondaryPreferred,
+	// nearest, eventual, monotonic or strong.
+	ReadMode string `json:"read_mode" yaml:"read_mode" env:"READ_MODE"`
+	// WriteConcern is the safety mode of the session.
+	WriteConcern *WriteConcernConfig `json:"write_concern" yaml:"write_concern" env:"WRITE_CONCERN_"`
+	// Tls is the TLS configuration of the connection.
+	Tls *TlsFileConfig `json:"tls" yaml:"tls" env:"TLS_"`
+	// IndependentHosts and ShuffleHosts, see ExtraConfig.
+	IndependentHosts bool `json:"independent_hosts" yaml:"independent_hosts" env:"INDEPENDENT_HOSTS"`
+	ShuffleHosts     bool `json:"shuffle_hosts" yaml:"shuffle_hosts" env:"SHUFFLE_HOSTS"`
+	// MaxRetries is the maximum amount of dial retries, see DialOptions.
+	MaxRetries int `json:"max_retries" yaml:"max_retries" env:"MAX_RETRIES"`
+	// RetrySleep is the time to wait between dial retries, see DialOptions.
+	RetrySleep Duration `json:"retry_sleep" yaml:"retry_sleep" env:"RETRY_SLEEP"`
+}
+
+// WriteConcernConfig is the configuration of the safety mode of a session. See the Safe documentation in
+// `gopkg.in/mgo.v2` for more information.
+type WriteConcernConfig struct {
+	// Preset is the name of a write concern preset used as the base, see WriteConcernPreset.
+	Preset string `json:"preset" yaml:"preset" env:"PRESET"`
+	// Disabled makes the writes unacknowledged (fire and forget).
+	Disabled bool `json:"disabled" yaml:"disabled" env:"DISABLED"`
+	// W is the amount of servers that must acknowledge the writes, or a tag set name such as "majority".
+	W string `json:"w" yaml:"w" env:"W"`
+	// Timeout is the time to wait for the acknowledgements.
+	Timeout Duration `json:"timeout" yaml:"timeout" env:"TIMEOUT"`
+	// Journal waits for the writes to be committed to the journal.
+	Journal bool `json:"journal" yaml:"journal" env:"JOURNAL"`
+	// FSync waits for the writes to be flushed to disk.
+	FSync bool `json:"fsync" yaml:"fsync" env:"FSYNC"`
+}
+
+// TlsFileConfig is the file based TLS configuration of a connection, see TlsConfig.
+type TlsFileConfig struct {
+	Enabled               bool   `json:"enabled" yaml:"enabled" env:"ENABLED"`
+	CAFile                string `json:"ca_file" yaml:"ca_file" env:"CA_FILE"`
+	CertFile              string `json:"cert_file" yaml:"cert_file" env:"CERT_FILE"`
+	KeyFile               string `json:"key_file" yaml:"key_file" env:"KEY_FILE"`
+	ServerName            string `json:"server_name" yaml:"server_name" env:"SERVER_NAME"`
+	MinVersion            string `json:"min_version" yaml:"min_version" env:"MIN_VERSION"`
+	AllowInvalidHostnames bool   `json:"allow_invalid_hostnames" yaml:"allow_invalid_hostnames" env:"ALLOW_INVALID_HOSTNAMES"`
+	InsecureSkipVerify    bool   `json:"insecure_skip_verify" yaml:"insecure_skip_verify" env:"INSECURE_SKIP_VERIFY"`
+}
+
+// ConfigError is returned when a configuration is not valid, it contains all the problems found.
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return "invalid mongo configuration: " + strings.Join(e.Problems, "; ")
+}
+
+// Duration is a time.Duration that can be read from JSON, YAML and environment variables, either as a string parsed
+// by time.ParseDuration, eg: "10s", or as a number of milliseconds.
+type Duration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case float64:
+		*d = Duration(time.Duration(v * float64(time.Millisecond)))
+		return nil
+	case string:
+		return d.parse(v)
+	}
+	return fmt.Errorf("invalid duration %s", string(data))
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	return d.parse(node.Value)
+}
+
+func (d *Duration) parse(value string) error {
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		*d = Duration(time.Duration(ms) * time.Millisecond)
+		return nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid duration '%s'", value)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// ConfigFromEnv loads the configuration from environment variables. The variable names are the prefix followed by the
+// 'env' tag of the Config fields, eg: MONGODB_URL, MONGODB_HOSTS (comma separated), MONGODB_READ_MODE,
+// MONGODB_WRITE_CONCERN_W, MONGODB_TLS_CA_FILE. Defaults to DefaultEnvPrefix.
+func ConfigFromEnv(prefix ...string) (*Config, error) {
+	p := DefaultEnvPrefix
+	if len(prefix) > 0 {
+		p = prefix[0]
+	}
+	cfg := &Config{}
+	if _, err := loadEnv(reflect.ValueOf(cfg).Elem(), p); err != nil {
+		return nil, err
+	}
+	return cfg, cfg.Validate()
+}
+
+// ConfigFromFile loads the configuration from a JSON or YAML file, depending on its extension.
+func ConfigFromFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, cfg)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	default:
+		return nil, fmt.Errorf("unsupported config file extension '%s', expected .json, .yaml or .yml", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse '%s': %v", path, err)
+	}
+	return cfg, cfg.Validate()
+}
+
+// ConfigFromURL creates the configuration of the provided connection string.
+func ConfigFromURL(url string) (*Config, error) {
+	cfg := &Config{URL: url}
+	return cfg, cfg.Validate()
+}
+
+// Validate returns a *ConfigError if the configuration is not valid.
+func (c *Config) Validate() error {
+	var problems []string
+
+	if c.URL == "" && len(c.Hosts) == 0 {
+		problems = append(problems, "either the url or the hosts are required")
+	}
+	username := c.Username
+	if isSRVURL(c.URL) {
+		if srv, err := parseSRVURL(c.URL); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid url: %v", err))
+		} else if username == "" {
+			username = srv.username()
+		}
+	} else if c.URL != "" {
+		if info, err := mgo.ParseURL(stripTlsOptions(c.URL)); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid url: %v", err))
+		} else if username == "" {
+			username = info.Username
+		}
+	}
+	if c.Password != "" && username == "" {
+		problems = append(problems, "a password was provided without a username")
+	}
+	if _, ok := readModes[strings.ToLower(c.ReadMode)]; c.ReadMode != "" && !ok {
+		problems = append(problems, fmt.Sprintf("invalid read_mode '%s'", c.ReadMode))
+	}
+	if c.WriteConcern != nil && c.WriteConcern.Preset != "" {
+		if _, err := WriteConcernPreset(c.WriteConcern.Preset); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if c.PoolLimit < 0 {
+		problems = append(problems, "pool_limit must not be negative")
+	}
+	for i, d := range []Duration{c.Timeout, c.SocketTimeout, c.SyncTimeout, c.RetrySleep} {
+		if d < 0 {
+			problems = append(problems, []string{"timeout", "socket_timeout", "sync_timeout", "retry_sleep"}[i]+" must not be negative")
+		}
+	}
+	if c.Tls != nil && c.Tls.Enabled {
+		if _, ok := tlsVersions[c.Tls.MinVersion]; c.Tls.MinVersion != "" && !ok {
+			problems = append(problems, fmt.Sprintf("invalid tls.min_version '%s', expected 1.0, 1.1, 1.2 or 1.3", c.Tls.MinVersion))
+		}
+		if c.Tls.KeyFile != "" && c.Tls.CertFile == "" {
+			problems = append(problems, "tls.key_file was provided without tls.cert_file")
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ConfigError{Problems: problems}
+	}
+	return nil
+}
+
+// DialInfo returns the DialInfo of the configuration.
+func (c *Config) DialInfo() (*mgo.DialInfo, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	info := &mgo.DialInfo{}
+	if c.URL != "" {
+		parsed, err := ParseURL(c.URL)
+		if err != nil {
+			return nil, err
+		}
+		info = parsed
+	}
+
+	if len(c.Hosts) > 0 {
+		info.Addrs = c.Hosts
+	}
+	setString(&info.Database, c.Database)
+	setString(&info.Username, c.Username)
+	setString(&info.Password, c.Password)
+	setString(&info.Source, c.AuthSource)
+	setString(&info.Mechanism, c.AuthMechanism)
+	setString(&info.ReplicaSetName, c.ReplicaSet)
+	info.Direct = info.Direct || c.Direct
+	if c.PoolLimit > 0 {
+		info.PoolLimit = c.PoolLimit
+	}
+	if c.Timeout > 0 {
+		info.Timeout = time.Duration(c.Timeout)
+	}
+	if info.Timeout == 0 {
+		info.Timeout = DialTimeout
+	}
+
+	if c.Tls != nil && c.Tls.Enabled {
+		tlsCfg := &TlsConfig{
+			CAFile:                c.Tls.CAFile,
+			CertFile:              c.Tls.CertFile,
+			KeyFile:               c.Tls.KeyFile,
+			ServerName:            c.Tls.ServerName,
+			MinVersion:            tlsVersions[c.Tls.MinVersion],
+			AllowInvalidHostnames: c.Tls.AllowInvalidHostnames,
+			InsecureSkipVerify:    c.Tls.InsecureSkipVerify,
+		}
+		if err := tlsCfg.Apply(info); err != nil {
+			return nil, err
+		}
+	}
+
+	return info, nil
+}
+
+// Safe returns the safety mode of the configuration, or nil if the writes are unacknowledged. Returns the default
+// safety mode if no write concern is configured.
+func (c *Config) Safe() *mgo.Safe {
+	if c.WriteConcern == nil {
+		return &mgo.Safe{}
+	}
+	return c.WriteConcern.WriteConcern().Safe()
+}
+
+// WriteConcern returns the write concern of the configuration, the preset (if any) overridden by the rest of the fields.
+// Presets that are not valid are ignored, see Config.Validate.
+func (c *WriteConcernConfig) WriteConcern() WriteConcern {
+	wc, _ := WriteConcernPreset(c.Preset)
+	wc.Unacknowledged = wc.Unacknowledged || c.Disabled
+	wc.J = wc.J || c.Journal
+	wc.FSync = wc.FSync || c.FSync
+	if c.Timeout > 0 {
+		wc.WTimeout = time.Duration(c.Timeout)
+	}
+	if w, err := strconv.Atoi(c.W); err == nil {
+		wc.W, wc.WMode = w, ""
+	} else if c.W != "" {
+		wc.WMode = c.W
+	}
+	return wc
+}
+
+// Connect validates the configuration and establishes a new session with it.
+//
+//   Example:
+//
+//      cfg, err := mgo.ConfigFromEnv()
+//      if err != nil {
+//          return err
+//      }
+//      session, err := mgo.Connect(cfg)
+//
+func Connect(cfg *Config) (ISession, error) {
+	info, err := cfg.DialInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := DefaultDialOptions()
+	opts.MaxRetries = cfg.MaxRetries
+	if cfg.RetrySleep > 0 {
+		opts.Backoff = ConstantBackoff(time.Duration(cfg.RetrySleep))
+	}
+
+	s, err := opts.DialWithInfo(info, ExtraConfig{IndependentHosts: cfg.IndependentHosts, ShuffleHosts: cfg.ShuffleHosts})
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.ReadMode != "" {
+		s.SetMode(readModes[strings.ToLower(cfg.ReadMode)], true)
+	}
+	if cfg.SocketTimeout > 0 {
+		s.SetSocketTimeout(time.Duration(cfg.SocketTimeout))
+	}
+	if cfg.SyncTimeout > 0 {
+		s.SetSyncTimeout(time.Duration(cfg.SyncTimeout))
+	}
+	if cfg.WriteConcern != nil {
+		s.SetSafe(cfg.Safe())
+	}
+	return s, nil
+}
+
+// stripTlsOptions removes the TLS options from the URL so it can be validated without loading the certificates.
+func stripTlsOptions(url string) string {
+	if stripped, _, err := parseTlsURL(url); err == nil {
+		return stripped
+	}
+	return url
+}
+
+func setString(target *string, value string) {
+	if value != "" {
+		*target = value
+	}
+}
+
+// loadEnv sets the fields of the provided struct from the environment variables named after their 'env' tags. Returns
+// whether any variable was found.
+func loadEnv(v reflect.Value, prefix string) (bool, error) {
+	found := false
+	for i := 0; i < v.NumField(); i++ {
+		tag := v.Type().Field(i).Tag.Get("env")
+		if tag == "" {
+			continue
+		}
+		name, field := prefix+tag, v.Field(i)
+
+		if field.Kind() == reflect.Ptr && field.Type().Elem().Kind() == reflect.Struct {
+			nested := reflect.New(field.Type().Elem())
+			ok, err := loadEnv(nested.Elem(), name)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				field.Set(nested)
+				found = true
+			}
+			continue
+		}
+
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		found = true
+		if err := setEnvField(field, value); err != nil {
+			return false, fmt.Errorf("invalid value for %s: %v", name, err)
+		}
+	}
+	return found, nil
+}
+
+func setEnvField(field reflect.Value, value string) error {
+	switch field.Interface().(type) {
+	case Duration:
+		var d Duration
+		if err := d.parse(value); err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(d))
+		return nil
+	case []string:
+		var values []string
+		for _, s := range strings.Split(value, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				values = append(values, s)
+			}
+		}
+		field.Set(reflect.ValueOf(values))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(n))
+	default:
+		return fmt.Errorf("unsupported field type %v", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package mgo
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/jucardi/go-mongodb-lib/log"
+	"gopkg.in/mgo.v2"
+)
+
+// DialOptions defines how the dial functions retry the failed connection attempts. The package level Dial,
+// DialWithInfo and DialWithTls functions use DefaultDialOptions.
+//
+//   Example:
+//
+//      opts := mgo.DialOptions{
+//          MaxRetries:     10,
+//          Backoff:        mgo.WithJitter(mgo.ExponentialBackoff(time.Second, 30*time.Second, 2), 0.2),
+//          MaxElapsedTime: 2 * time.Minute,
+//          Context:        ctx,
+//      }
+//      session, err := opts.Dial(url)
+//
+type DialOptions struct {
+	// MaxRetries is the maximum amount of retries after the first attempt. Zero uses DialMaxRetries, a negative value
+	// disables retries.
+	MaxRetries int
+	// Backoff returns the time to wait before every retry. Defaults to ConstantBackoff(DialRetrySleep).
+	Backoff Backoff
+	// MaxElapsedTime stops retrying once the time elapsed since the first attempt, plus the next wait, would exceed it.
+	// Zero means no limit.
+	MaxElapsedTime time.Duration
+	// Context cancels the retries when done. Since the driver does not support contexts, an attempt in progress is not
+	// interrupted, the context is checked before every attempt and while waiting between them.
+	Context context.Context
+	// OnAttempt is invoked after every attempt with the addresses dialed (with the passwords redacted), the number of
+	// the attempt starting at 1 and the error of the attempt, nil if it succeeded.
+	OnAttempt func(addrs []string, attempt int, err error)
+}
+
+// DefaultDialOptions returns the options used by the package level dial functions, built from DialMaxRetries and
+// DialRetrySleep.
+func DefaultDialOptions() DialOptions {
+	return DialOptions{}
+}
+
+// Dial works like the package level Dial, retrying with these options.
+func (o DialOptions) Dial(url ...string) (ISession, error) {
+	if len(url) == 0 {
+		return nil, errors.New("expected a 'url'")
+	}
+
+	var (
+		s     *mgo.Session
+		err   error
+		start = time.Now()
+	)
+
+	for _, u := range url {
+		var info *mgo.DialInfo
+		if info, err = ParseURL(u); err != nil {
+			continue
+		}
+		// Same timeouts used by mgo.Dial
+		info.Timeout = 10 * time.Second
+
+		s, err = o.retry(start, []string{log.RedactURL(u)}, func() (*mgo.Session, error) {
+			return mgo.DialWithInfo(info)
+		})
+		if err == nil {
+			s.SetSyncTimeout(time.Minute)
+			s.SetSocketTimeout(time.Minute)
+		}
+
+		if err == nil || o.Context != nil && o.Context.Err() != nil {
+			break
+		}
+	}
+
+	return fromSession(s), err
+}
+
+// DialWithInfo works like the package level DialWithInfo, retrying with these options.
+func (o DialOptions) DialWithInfo(info *mgo.DialInfo, extraCfg ...ExtraConfig) (ISession, error) {
+	cfg := ExtraConfig{}
+
+	if len(extraCfg) > 0 {
+		cfg = extraCfg[0]
+	}
+
+	var (
+		s     *mgo.Session
+		err   error
+		start = time.Now()
+	)
+
+	if cfg.ShuffleHosts && len(info.Addrs) > 1 {
+		for i := len(info.Addrs) - 1; i > 0; i-- { // Fisher–Yates shuffle
+			j := rand.Intn(i + 1)
+			info.Addrs[i], info.Addrs[j] = info.Addrs[j], info.Addrs[i]
+		}
+	}
+
+	addrs := info.Addrs
+
+	for _, u := range addrs {
+		if cfg.IndependentHosts {
+			info.Addrs = []string{u}
+		}
+
+		s, err = o.retry(start, info.Addrs, func() (*mgo.Session, error) {
+			return mgo.DialWithInfo(info)
+		})
+
+		if err == nil || !cfg.IndependentHosts || o.Context != nil && o.Context.Err() != nil {
+			break
+		}
+	}
+
+	return fromSession(s), err
+}
+
+// DialWithTls works like the package level DialWithTls, retrying with these options.
+func (o DialOptions) DialWithTls(url string, cert []byte, insecureSkipVerify ...bool) (ISession, error) {
+	return o.DialWithTlsConfig(url, TlsConfig{
+		CA:                 cert,
+		InsecureSkipVerify: len(insecureSkipVerify) > 0 && insecureSkipVerify[0],
+	})
+}
+
+// DialWithTlsConfig works like the package level DialWithTlsConfig, retrying with these options.
+func (o DialOptions) DialWithTlsConfig(url string, cfg TlsConfig) (ISession, error) {
+	url, err := ResolveURL(url)
+	if err != nil {
+		return nil, err
+	}
+	url, _, err = parseTlsURL(url)
+	if err != nil {
+		return nil, err
+	}
+	info, err := mgo.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	info.Timeout = DialTimeout
+	if err := cfg.Apply(info); err != nil {
+		return nil, err
+	}
+
+	var (
+		lastErr    error
+		mux        sync.Mutex
+		dialServer = info.DialServer
+	)
+	info.DialServer = func(addr *mgo.ServerAddr) (net.Conn, error) {
+		conn, err := dialServer(addr)
+		if err != nil {
+			mux.Lock()
+			lastErr = err
+			mux.Unlock()
+		}
+		return conn, err
+	}
+
+	s, err := o.retry(time.Now(), []string{log.RedactURL(url)}, func() (*mgo.Session, error) {
+		s, err := mgo.DialWithInfo(info)
+		mux.Lock()
+		defer mux.Unlock()
+		if err != nil && lastErr != nil {
+			// Reports the TLS error instead of the generic 'no reachable servers'
+			err = lastErr
+		}
+		lastErr = nil
+		return s, err
+	})
+
+	return fromSession(s), err
+}
+
+// retry invokes dial until it succeeds or the retries are exhausted, returning the error of the last attempt, or the
+// context error if the context is done.
+func (o DialOptions) retry(start time.Time, addrs []string, dial func() (*mgo.Session, error)) (*mgo.Session, error) {
+	ctx := o.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	maxRetries := o.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = DialMaxRetries
+	}
+	backoff := o.Backoff
+	if backoff == nil {
+		backoff = ConstantBackoff(DialRetrySleep)
+	}
+
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		s, err := dial()
+		if o.OnAttempt != nil {
+			o.OnAttempt(addrs, attempt, err)
+		}
+		if err == nil || attempt > maxRetries {
+			return s, err
+		}
+
+		wait := backoff(attempt)
+		if o.MaxElapsedTime > 0 && time.Since(start)+wait > o.MaxElapsedTime {
+			return nil, err
+		}
+
+		log.With(log.F("addrs", addrs), log.F("error", err.Error()), log.F("retry_in", wait.String())).Error("Can't connect to mongo")
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		log.With(log.F("attempt", attempt), log.F("max_retries", maxRetries)).Warn("Retrying to connect to mongo")
+		notifyDialRetry(addrs, attempt, err)
+	}
+}
@@ -0,0 +1,117 @@
+package mgo
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/mgo.v2"
+)
+
+// failingDial returns a dial function that fails the provided amount of times before succeeding.
+func failingDial(calls *int, failures int) func() (*mgo.Session, error) {
+	return func() (*mgo.Session, error) {
+		*calls++
+		if *calls <= failures {
+			return nil, errors.New("no reachable servers")
+		}
+		return &mgo.Session{}, nil
+	}
+}
+
+func TestDialOptions_Retry(t *testing.T) {
+	var attempts []int
+	var retried []int
+	remove := AddDialRetryHandler(func(addrs []string, attempt int, err error) { retried = append(retried, attempt) })
+	defer remove()
+
+	opts := DialOptions{
+		MaxRetries: 5,
+		Backoff:    ExponentialBackoff(time.Millisecond, 0, 2),
+		OnAttempt: func(addrs []string, attempt int, err error) {
+			assert.Equal(t, []string{"localhost"}, addrs)
+			attempts = append(attempts, attempt)
+		},
+	}
+
+	calls := 0
+	s, err := opts.retry(time.Now(), []string{"localhost"}, failingDial(&calls, 2))
+	assert.NoError(t, err)
+	assert.NotNil(t, s)
+	assert.Equal(t, []int{1, 2, 3}, attempts)
+	assert.Equal(t, []int{1, 2}, retried)
+}
+
+func TestAddDialRetryHandler_Remove(t *testing.T) {
+	var a, b int
+	removeA := AddDialRetryHandler(func([]string, int, error) { a++ })
+	removeB := AddDialRetryHandler(func([]string, int, error) { b++ })
+	defer removeB()
+
+	notifyDialRetry(nil, 1, nil)
+	removeA()
+	removeA()
+	notifyDialRetry(nil, 2, nil)
+	assert.Equal(t, 1, a)
+	assert.Equal(t, 2, b)
+}
+
+func TestDialOptions_MaxRetries(t *testing.T) {
+	calls := 0
+	_, err := DialOptions{MaxRetries: 2, Backoff: ConstantBackoff(0)}.retry(time.Now(), nil, failingDial(&calls, 10))
+	assert.EqualError(t, err, "no reachable servers")
+	assert.Equal(t, 3, calls)
+
+	calls = 0
+	_, err = DialOptions{MaxRetries: -1}.retry(time.Now(), nil, failingDial(&calls, 10))
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestDialOptions_MaxElapsedTime(t *testing.T) {
+	calls := 0
+	_, err := DialOptions{MaxRetries: 10, Backoff: ConstantBackoff(time.Hour), MaxElapsedTime: time.Minute}.retry(time.Now(), nil, failingDial(&calls, 10))
+	assert.EqualError(t, err, "no reachable servers")
+	assert.Equal(t, 1, calls)
+}
+
+func TestDialOptions_Context(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	opts := DialOptions{
+		MaxRetries: 10,
+		Backoff:    ConstantBackoff(time.Hour),
+		Context:    ctx,
+		OnAttempt:  func([]string, int, error) { cancel() },
+	}
+
+	_, err := opts.retry(time.Now(), nil, failingDial(&calls, 10))
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 1, calls)
+
+	_, err = opts.Dial("localhost")
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestBackoff(t *testing.T) {
+	exp := ExponentialBackoff(100*time.Millisecond, time.Second, 2)
+	assert.Equal(t, 100*time.Millisecond, exp(1))
+	assert.Equal(t, 400*time.Millisecond, exp(3))
+	assert.Equal(t, time.Second, exp(5))
+	assert.Equal(t, 3*time.Second, ConstantBackoff(3*time.Second)(7))
+
+	// The waits without maximum are capped instead of overflowing
+	unbounded := ExponentialBackoff(time.Second, 0, 2)
+	assert.Equal(t, time.Duration(math.MaxInt64), unbounded(100))
+	assert.Equal(t, time.Duration(math.MaxInt64), unbounded(5000))
+	assert.True(t, WithJitter(unbounded, 0.5)(100) > 0)
+
+	jitter := WithJitter(ConstantBackoff(time.Second), 0.1)
+	for i := 1; i < 20; i++ {
+		d := jitter(i)
+		assert.True(t, d >= 900*time.Millisecond && d <= 1100*time.Millisecond, d.String())
+	}
+}
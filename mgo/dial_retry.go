@@ -0,0 +1,55 @@
+package mgo
+
+import (
+	"sync"
+)
+
+// DialRetryHandler is invoked every time a dial attempt fails and is about to be retried by Dial, DialWithInfo or
+// DialWithTls, including their DialOptions variants.
+//
+//   {addrs}   - The addresses being dialed, with the passwords redacted.
+//   {attempt} - The number of the retry about to be performed, starting at 1.
+//   {err}     - The error returned by the failed attempt.
+//
+type DialRetryHandler func(addrs []string, attempt int, err error)
+
+// dialRetryEntry is a registered handler, the pointer identifies the registration when it is removed.
+type dialRetryEntry struct {
+	handler DialRetryHandler
+}
+
+var (
+	dialRetryHandlers []*dialRetryEntry
+	dialRetryMux      sync.RWMutex
+)
+
+// AddDialRetryHandler registers a handler to be invoked every time a dial attempt is retried. The handlers are global
+// to the process, the returned function removes the handler and should be invoked once it is no longer needed.
+func AddDialRetryHandler(handler DialRetryHandler) (remove func()) {
+	entry := &dialRetryEntry{handler: handler}
+	dialRetryMux.Lock()
+	defer dialRetryMux.Unlock()
+	dialRetryHandlers = append(dialRetryHandlers, entry)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			dialRetryMux.Lock()
+			defer dialRetryMux.Unlock()
+			for i, e := range dialRetryHandlers {
+				if e == entry {
+					dialRetryHandlers = append(dialRetryHandlers[:i:i], dialRetryHandlers[i+1:]...)
+					return
+				}
+			}
+		})
+	}
+}
+
+func notifyDialRetry(addrs []string, attempt int, err error) {
+	dialRetryMux.RLock()
+	defer dialRetryMux.RUnlock()
+	for _, e := range dialRetryHandlers {
+		e.handler(addrs, attempt, err)
+	}
+}
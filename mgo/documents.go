@@ -0,0 +1,177 @@
+package mgo
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// MarshalRaw converts the provided value into a bson.Raw value, so it can be stored as a field of a document and decoded
+// later with Unmarshal, eg: the payloads of the queue jobs and the outbox events.
+func MarshalRaw(value interface{}) (bson.Raw, error) {
+	data, err := bson.Marshal(bson.M{"value": value})
+	if err != nil {
+		return bson.Raw{}, err
+	}
+	doc := struct {
+		Value bson.Raw `bson:"value"`
+	}{}
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		return bson.Raw{}, err
+	}
+	return doc.Value, nil
+}
+
+// toDocument converts the provided document into a bson.M. Maps are shallow copied so the caller's instance is not
+// modified, any other value is converted by marshalling it with bson.
+func toDocument(doc interface{}) (bson.M, error) {
+	switch d := doc.(type) {
+	case nil:
+		return bson.M{}, nil
+	case bson.M:
+		return copyDocument(d), nil
+	case map[string]interface{}:
+		return copyDocument(d), nil
+	case bson.D:
+		return d.Map(), nil
+	}
+
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("unable to convert %T into a document, %v", doc, err)
+	}
+	ret := bson.M{}
+	if err := bson.Unmarshal(data, &ret); err != nil {
+		return nil, fmt.Errorf("unable to convert %T into a document, %v", doc, err)
+	}
+	return ret, nil
+}
+
+func copyDocument(doc map[string]interface{}) bson.M {
+	ret := make(bson.M, len(doc))
+	for k, v := range doc {
+		ret[k] = v
+	}
+	return ret
+}
+
+// isOperatorDocument indicates whether the document is an update document made of update operators ($set, $inc, etc)
+// rather than a replacement document.
+func isOperatorDocument(doc bson.M) bool {
+	for k := range doc {
+		if strings.HasPrefix(k, "$") {
+			return true
+		}
+	}
+	return false
+}
+
+// setOperatorField adds 'field: value' to the given update operator of the document, unless the field is already
+// present in any of the operators of the update document.
+func setOperatorField(doc bson.M, operator, field string, value interface{}) error {
+	if ok, err := hasOperatorField(doc, field); ok || err != nil {
+		return err
+	}
+
+	fields, ok := doc[operator].(bson.M)
+	if !ok {
+		fields = bson.M{}
+	}
+	fields[field] = value
+	doc[operator] = fields
+	return nil
+}
+
+// hasOperatorField indicates whether the field is present in any of the operators of the update document. The operators
+// are converted to bson.M in the document.
+func hasOperatorField(doc bson.M, field string) (bool, error) {
+	for op, v := range doc {
+		if !strings.HasPrefix(op, "$") {
+			continue
+		}
+		fields, err := toDocument(v)
+		if err != nil {
+			return false, err
+		}
+		doc[op] = fields
+		if _, ok := fields[field]; ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// mergeFilter returns a selector that matches the documents matched by both the provided selector and the filter. If
+// the selector does not contain any of the filter keys they are added to it, otherwise both are combined with '$and'.
+func mergeFilter(selector interface{}, filter bson.M) (bson.M, error) {
+	doc, err := toDocument(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	for k := range filter {
+		if _, ok := doc[k]; ok {
+			return bson.M{"$and": []interface{}{doc, filter}}, nil
+		}
+	}
+
+	for k, v := range filter {
+		doc[k] = v
+	}
+	return doc, nil
+}
+
+// structField returns the settable field of the struct pointed by doc which bson key matches the given name. Returns
+// an invalid reflect.Value if doc is not a pointer to a struct or if the struct does not contain the field.
+func structField(doc interface{}, name string) reflect.Value {
+	v := reflect.ValueOf(doc)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}
+	}
+	return findStructField(v.Elem(), name)
+}
+
+func findStructField(v reflect.Value, name string) reflect.Value {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		key, inline := bsonName(f)
+		if inline {
+			fv := v.Field(i)
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				if ret := findStructField(fv, name); ret.IsValid() {
+					return ret
+				}
+			}
+			continue
+		}
+		if key == name && v.Field(i).CanSet() {
+			return v.Field(i)
+		}
+	}
+	return reflect.Value{}
+}
+
+// pipelineStages returns the stages of the provided pipeline, which may be a slice of stages or a single stage.
+func pipelineStages(pipeline interface{}) []interface{} {
+	var stages []interface{}
+	if v := reflect.ValueOf(pipeline); v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		for i := 0; i < v.Len(); i++ {
+			stages = append(stages, v.Index(i).Interface())
+		}
+	} else if pipeline != nil {
+		stages = append(stages, pipeline)
+	}
+	return stages
+}
@@ -0,0 +1,245 @@
+package mgo
+
+import (
+	"errors"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+
+	"gopkg.in/mgo.v2"
+)
+
+// Error categories of the errors returned by Classify, match them with errors.Is, eg:
+//
+//      if errors.Is(mgo.Classify(err), mgo.ErrDuplicateKey) { ... }
+//
+var (
+	// ErrDuplicateKey categorizes the errors caused by a primary key or unique index that already has an entry with the
+	// given value.
+	ErrDuplicateKey = errors.New("duplicate key")
+	// ErrWriteConcern categorizes the errors caused by a write concern that could not be satisfied.
+	ErrWriteConcern = errors.New("write concern error")
+	// ErrTimeout categorizes the errors caused by an operation or socket that timed out.
+	ErrTimeout = errors.New("timeout")
+	// ErrNetwork categorizes the errors caused by connectivity issues with the server.
+	ErrNetwork = errors.New("network error")
+	// ErrNotPrimary categorizes the errors caused by a server that is not, or stopped being, the primary, eg: during a
+	// step down or a shutdown.
+	ErrNotPrimary = errors.New("not primary")
+	// ErrAuth categorizes the authentication and authorization errors.
+	ErrAuth = errors.New("authentication error")
+	// ErrValidation categorizes the errors caused by a document that failed the collection validation.
+	ErrValidation = errors.New("document validation failure")
+	// ErrCursorNotFound categorizes the errors caused by a cursor that no longer exists in the server.
+	ErrCursorNotFound = errors.New("cursor not found")
+)
+
+var (
+	errorCodes = map[int]error{
+		6:     ErrNetwork,        // HostUnreachable
+		7:     ErrNetwork,        // HostNotFound
+		9001:  ErrNetwork,        // SocketException
+		50:    ErrTimeout,        // ExceededTimeLimit
+		89:    ErrTimeout,        // NetworkTimeout
+		262:   ErrTimeout,        // ExceededTimeLimit
+		64:    ErrWriteConcern,   // WriteConcernFailed
+		79:    ErrWriteConcern,   // UnknownReplWriteConcern
+		100:   ErrWriteConcern,   // UnsatisfiableWriteConcern
+		91:    ErrNotPrimary,     // ShutdownInProgress
+		189:   ErrNotPrimary,     // PrimarySteppedDown
+		10107: ErrNotPrimary,     // NotMaster
+		11600: ErrNotPrimary,     // InterruptedAtShutdown
+		11602: ErrNotPrimary,     // InterruptedDueToReplStateChange
+		13435: ErrNotPrimary,     // NotMasterNoSlaveOk
+		13436: ErrNotPrimary,     // NotMasterOrSecondary
+		13:    ErrAuth,           // Unauthorized
+		18:    ErrAuth,           // AuthenticationFailed
+		121:   ErrValidation,     // DocumentValidationFailure
+		43:    ErrCursorNotFound, // CursorNotFound
+	}
+
+	errorMessages = []struct {
+		text     string
+		category error
+	}{
+		{"i/o timeout", ErrTimeout},
+		{"timed out", ErrTimeout},
+		{"no reachable servers", ErrNetwork},
+		{"connection reset", ErrNetwork},
+		{"broken pipe", ErrNetwork},
+		{"not master", ErrNotPrimary},
+		{"node is recovering", ErrNotPrimary},
+		{"auth fail", ErrAuth},
+		{"authentication failed", ErrAuth},
+		{"not authorized", ErrAuth},
+		{"document failed validation", ErrValidation},
+		{"cursor not found", ErrCursorNotFound},
+	}
+
+	// Matches the index and key of the duplicate key messages of all server versions, eg:
+	//   E11000 duplicate key error collection: db.users index: email_1 dup key: { email: "someone@example.com" }
+	//   E11000 duplicate key error index: db.users.$email_1 dup key: { : "someone@example.com" }
+	dupKeyRegex = regexp.MustCompile(`index: (?:\S+\.\$)?(\S+)\s+dup key: (\{.*\})`)
+)
+
+// Error is a classified driver error.
+type Error struct {
+	// Category is one of the error categories, eg: ErrDuplicateKey, or nil if the error could not be classified.
+	Category error
+	// Code is the server error code, if any.
+	Code int
+	// Index is the name of the index that caused a duplicate key error.
+	Index string
+	// Key is the offending key of a duplicate key error, as reported by the server, eg: `{ email: "someone@example.com" }`
+	Key string
+	// Err is the original driver error.
+	Err error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the original driver error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is allows matching the error category with errors.Is.
+func (e *Error) Is(target error) bool {
+	return e.Category != nil && target == e.Category
+}
+
+// BulkError is the classified error of a bulk operation, which contains the error of every failed operation. Matching
+// it with errors.Is and errors.As matches any of the operation errors.
+type BulkError struct {
+	// Cases are the errors of the failed operations.
+	Cases []*BulkErrorCase
+	// Err is the original driver error.
+	Err error
+}
+
+// BulkErrorCase is the error of a single operation of a bulk operation.
+type BulkErrorCase struct {
+	// Err is the classified error of the operation.
+	Err *Error
+	// Index is the position of the operation that failed in the bulk, or -1 if unknown. See the BulkErrorCase
+	// documentation in `gopkg.in/mgo.v2` for the limitations in older MongoDB releases.
+	Index int
+}
+
+func (e *BulkError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the errors of the failed operations.
+func (e *BulkError) Unwrap() []error {
+	ret := make([]error, len(e.Cases))
+	for i, c := range e.Cases {
+		ret[i] = c.Err
+	}
+	return ret
+}
+
+// bulkErrorCases is implemented by *mgo.BulkError
+type bulkErrorCases interface {
+	error
+	Cases() []mgo.BulkErrorCase
+}
+
+// Classify returns the classified version of a driver error, a *BulkError for the errors of bulk operations or an
+// *Error otherwise, whose category can be matched with errors.Is. ErrNotFound and nil are returned as they are, as
+// well as the errors that are already classified.
+//
+//   Example:
+//
+//      err = mgo.Classify(col.Insert(user))
+//
+//      var mgoErr *mgo.Error
+//      if errors.As(err, &mgoErr) && errors.Is(err, mgo.ErrDuplicateKey) {
+//          return fmt.Errorf("the %s is already registered", mgoErr.Key)
+//      }
+//
+func Classify(err error) error {
+	if err == nil || err == ErrNotFound {
+		return err
+	}
+
+	switch e := err.(type) {
+	case *Error, *BulkError:
+		return err
+	case bulkErrorCases:
+		ret := &BulkError{Err: err}
+		for _, c := range e.Cases() {
+			ret.Cases = append(ret.Cases, &BulkErrorCase{Err: classify(c.Err), Index: c.Index})
+		}
+		return ret
+	}
+
+	return classify(err)
+}
+
+// ClassifyErrors returns an Interceptor that classifies the errors returned by the operations, see Classify.
+func ClassifyErrors() Interceptor {
+	return func(op *Operation, next Invoker) error {
+		return Classify(next(op))
+	}
+}
+
+// ErrorCategory returns the category of the provided error, or nil if it could not be classified.
+func ErrorCategory(err error) error {
+	for _, category := range []error{ErrDuplicateKey, ErrWriteConcern, ErrTimeout, ErrNetwork, ErrNotPrimary, ErrAuth, ErrValidation, ErrCursorNotFound} {
+		if errors.Is(Classify(err), category) {
+			return category
+		}
+	}
+	return nil
+}
+
+func classify(err error) *Error {
+	ret := &Error{Err: err}
+	msg := err.Error()
+
+	switch e := err.(type) {
+	case *mgo.LastError:
+		ret.Code = e.Code
+		if e.WTimeout {
+			ret.Category = ErrWriteConcern
+		}
+	case *mgo.QueryError:
+		ret.Code = e.Code
+	}
+
+	switch {
+	case ret.Category != nil:
+	case mgo.IsDup(err):
+		ret.Category = ErrDuplicateKey
+		if m := dupKeyRegex.FindStringSubmatch(msg); m != nil {
+			ret.Index, ret.Key = m[1], m[2]
+		}
+	case errorCodes[ret.Code] != nil:
+		ret.Category = errorCodes[ret.Code]
+	case err == ErrCursor:
+		ret.Category = ErrCursorNotFound
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		ret.Category = ErrNetwork
+	default:
+		if netErr, ok := err.(net.Error); ok {
+			ret.Category = ErrNetwork
+			if netErr.Timeout() {
+				ret.Category = ErrTimeout
+			}
+			break
+		}
+		msg = strings.ToLower(msg)
+		for _, m := range errorMessages {
+			if strings.Contains(msg, m.text) {
+				ret.Category = m.category
+				break
+			}
+		}
+	}
+
+	return ret
+}
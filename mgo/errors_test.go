@@ -0,0 +1,98 @@
+package mgo
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/mgo.v2"
+)
+
+type fakeBulkError struct {
+	cases []mgo.BulkErrorCase
+}
+
+func (e *fakeBulkError) Error() string {
+	return "multiple errors in bulk operation"
+}
+
+func (e *fakeBulkError) Cases() []mgo.BulkErrorCase {
+	return e.cases
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestClassify_DuplicateKey(t *testing.T) {
+	for _, msg := range []string{
+		`E11000 duplicate key error collection: test.users index: email_1 dup key: { email: "someone@example.com" }`,
+		`E11000 duplicate key error index: test.users.$email_1 dup key: { : "someone@example.com" }`,
+	} {
+		driverErr := &mgo.LastError{Code: 11000, Err: msg}
+		err := Classify(driverErr)
+
+		var mgoErr *Error
+		assert.True(t, errors.As(err, &mgoErr))
+		assert.True(t, errors.Is(err, ErrDuplicateKey))
+		assert.True(t, IsDup(err))
+		assert.Equal(t, "email_1", mgoErr.Index)
+		assert.Contains(t, mgoErr.Key, `"someone@example.com" }`)
+		assert.Equal(t, 11000, mgoErr.Code)
+		assert.Equal(t, driverErr, errors.Unwrap(err))
+		assert.Equal(t, msg, err.Error())
+	}
+}
+
+func TestClassify_Categories(t *testing.T) {
+	for expected, errs := range map[error][]error{
+		ErrWriteConcern:   {&mgo.LastError{WTimeout: true, Err: "timeout"}, &mgo.QueryError{Code: 100}},
+		ErrTimeout:        {&mgo.QueryError{Code: 50}, &net.OpError{Op: "read", Err: timeoutError{}}, errors.New("read tcp: i/o timeout")},
+		ErrNetwork:        {io.EOF, &net.OpError{Op: "dial", Err: errors.New("connection refused")}, errors.New("no reachable servers")},
+		ErrNotPrimary:     {&mgo.QueryError{Code: 10107}, &mgo.LastError{Code: 189}, errors.New("not master")},
+		ErrAuth:           {&mgo.QueryError{Code: 18}, errors.New("server returned error on SASL authentication step: Authentication failed.")},
+		ErrValidation:     {&mgo.LastError{Code: 121, Err: "Document failed validation"}},
+		ErrCursorNotFound: {ErrCursor, &mgo.QueryError{Code: 43}},
+	} {
+		for _, err := range errs {
+			assert.True(t, errors.Is(Classify(err), expected), "%v should be %v", err, expected)
+			assert.Equal(t, expected, ErrorCategory(err))
+		}
+	}
+
+	assert.Nil(t, ErrorCategory(errors.New("boom")))
+	assert.Nil(t, Classify(nil))
+	assert.Equal(t, ErrNotFound, Classify(ErrNotFound))
+	assert.True(t, errors.Is(Classify(ErrCursor), ErrCursor))
+}
+
+func TestClassify_Bulk(t *testing.T) {
+	err := Classify(&fakeBulkError{cases: []mgo.BulkErrorCase{
+		{Index: 1, Err: &mgo.LastError{Code: 11000, Err: `E11000 duplicate key error collection: test.users index: email_1 dup key: { email: "a" }`}},
+		{Index: 3, Err: &mgo.LastError{Code: 121, Err: "Document failed validation"}},
+	}})
+
+	var bulkErr *BulkError
+	assert.True(t, errors.As(err, &bulkErr))
+	assert.Len(t, bulkErr.Cases, 2)
+	assert.Equal(t, 1, bulkErr.Cases[0].Index)
+	assert.Equal(t, "email_1", bulkErr.Cases[0].Err.Index)
+	assert.Equal(t, 3, bulkErr.Cases[1].Index)
+	assert.Equal(t, ErrValidation, bulkErr.Cases[1].Err.Category)
+
+	assert.True(t, errors.Is(err, ErrDuplicateKey))
+	assert.True(t, errors.Is(err, ErrValidation))
+	assert.False(t, errors.Is(err, ErrNetwork))
+	assert.False(t, IsDup(err))
+	assert.Equal(t, err, Classify(err))
+}
+
+func TestClassifyErrors(t *testing.T) {
+	rec := &recorderCollection{err: &mgo.LastError{Code: 11000, Err: "E11000 duplicate key error"}}
+	err := InterceptCollection(rec, ClassifyErrors()).Update(nil, nil)
+	assert.True(t, errors.Is(err, ErrDuplicateKey))
+}
@@ -0,0 +1,300 @@
+package mgo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jucardi/go-mongodb-lib/log"
+)
+
+const (
+	// DefaultHealthInterval is the default time between the health checks.
+	DefaultHealthInterval = 10 * time.Second
+	// DefaultHealthFailureThreshold is the default amount of consecutive failed checks after which the session is
+	// considered unhealthy.
+	DefaultHealthFailureThreshold = 1
+)
+
+// ErrHealthCheckTimeout is matched by the errors of the checks which ping did not respond within the timeout, use
+// errors.Is(err, ErrHealthCheckTimeout).
+var ErrHealthCheckTimeout = errors.New("the health check ping timed out")
+
+// HealthStatus is the result of the health checks of a session.
+type HealthStatus struct {
+	// Healthy indicates whether the amount of consecutive failed checks is below the failure threshold.
+	Healthy bool `json:"healthy"`
+	// LastCheck is the time of the last check, zero if no check has been performed yet.
+	LastCheck time.Time `json:"last_check"`
+	// LastError is the error of the last check, nil if it succeeded.
+	LastError error `json:"-"`
+	// ConsecutiveFailures is the amount of consecutive failed checks.
+	ConsecutiveFailures int `json:"consecutive_failures"`
+	// LiveServers are the addresses of the servers known to be alive in the last check.
+	LiveServers []string `json:"live_servers"`
+}
+
+// MarshalJSON implements json.Marshaler, including the last error as a string.
+func (s HealthStatus) MarshalJSON() ([]byte, error) {
+	type status HealthStatus
+	ret := struct {
+		status
+		Error string `json:"error,omitempty"`
+	}{status: status(s)}
+	if s.LastError != nil {
+		ret.Error = s.LastError.Error()
+	}
+	return json.Marshal(ret)
+}
+
+// HealthMonitorOptions defines how the health monitor checks the session.
+type HealthMonitorOptions struct {
+	// Interval is the time between the checks. Defaults to DefaultHealthInterval.
+	Interval time.Duration
+	// Timeout is the maximum time a check waits for the ping, since the driver may block for the sync timeout of the
+	// session during an outage. Defaults to half the Interval, and must be shorter than the Interval.
+	Timeout time.Duration
+	// FailureThreshold is the amount of consecutive failed checks after which the session is considered unhealthy.
+	// Defaults to DefaultHealthFailureThreshold.
+	FailureThreshold int
+	// OnStatusChange is invoked every time the session becomes healthy or unhealthy.
+	OnStatusChange func(status HealthStatus)
+	// OnServersChange is invoked every time the live servers change, with the servers that became alive and the ones
+	// that are no longer alive.
+	OnServersChange func(added, removed []string)
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// HealthMonitor periodically pings a session in background, refreshing it after the failed pings so the next check
+// obtains a new socket, and tracks the changes in its live servers. The status is exposed by Healthy, Status and the
+// HTTP handlers, which can be used as liveness and readiness probes.
+//
+//   Example:
+//
+//      monitor := mgo.NewHealthMonitor(session, mgo.HealthMonitorOptions{Interval: 5 * time.Second})
+//      monitor.Start()
+//      defer monitor.Stop()
+//
+//      http.Handle("/healthz", monitor.LivenessHandler())
+//      http.Handle("/readyz", monitor.ReadinessHandler())
+//
+type HealthMonitor struct {
+	session ISession
+	opts    HealthMonitorOptions
+	mux     sync.RWMutex
+	status  HealthStatus
+	stop    chan struct{}
+	done    chan struct{}
+	pending *healthPing
+}
+
+// healthPing is a ping in progress, done is closed once err is set.
+type healthPing struct {
+	done chan struct{}
+	err  error
+}
+
+// NewHealthMonitor creates a new health monitor of the provided session. The session is considered unhealthy until
+// the first check is performed, see Start and Check.
+func NewHealthMonitor(session ISession, opts ...HealthMonitorOptions) *HealthMonitor {
+	cfg := HealthMonitorOptions{}
+	if len(opts) > 0 {
+		cfg = opts[0]
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultHealthInterval
+	}
+	if cfg.Timeout <= 0 || cfg.Timeout >= cfg.Interval {
+		cfg.Timeout = cfg.Interval / 2
+	}
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = DefaultHealthFailureThreshold
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return &HealthMonitor{session: session, opts: cfg}
+}
+
+// Start performs a first check and starts checking the session in background. Does nothing if already started.
+func (m *HealthMonitor) Start() {
+	m.mux.Lock()
+	if m.stop != nil {
+		m.mux.Unlock()
+		return
+	}
+	stop, done := make(chan struct{}), make(chan struct{})
+	m.stop, m.done = stop, done
+	m.mux.Unlock()
+
+	m.Check()
+	go m.run(stop, done)
+}
+
+// Stop stops the background checks and waits for the check in progress, if any.
+func (m *HealthMonitor) Stop() {
+	m.mux.Lock()
+	stop, done := m.stop, m.done
+	m.stop, m.done = nil, nil
+	m.mux.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+func (m *HealthMonitor) run(stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(m.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			m.Check()
+		}
+	}
+}
+
+// Check pings the session, updates the status and returns it. A ping that does not respond within the timeout fails
+// the check with ErrHealthCheckTimeout.
+func (m *HealthMonitor) Check() HealthStatus {
+	err := m.ping()
+	if err != nil {
+		log.Get().Warn("Health check failed, refreshing the session, ", err)
+		m.session.Refresh()
+	}
+	servers := append([]string{}, m.session.LiveServers()...)
+	sort.Strings(servers)
+
+	m.mux.Lock()
+	previous := m.status
+	status := HealthStatus{
+		LastCheck:   m.opts.Now(),
+		LastError:   err,
+		LiveServers: servers,
+	}
+	if err != nil {
+		status.ConsecutiveFailures = previous.ConsecutiveFailures + 1
+	}
+	status.Healthy = status.ConsecutiveFailures < m.opts.FailureThreshold
+	m.status = status
+	m.mux.Unlock()
+
+	if added, removed := diffServers(previous.LiveServers, servers); (len(added) > 0 || len(removed) > 0) && m.opts.OnServersChange != nil {
+		m.opts.OnServersChange(added, removed)
+	}
+	if status.Healthy != previous.Healthy || previous.LastCheck.IsZero() {
+		if status.Healthy {
+			log.Get().Info("The mongo session is healthy")
+		} else {
+			log.Get().Error("The mongo session is unhealthy, ", err)
+		}
+		if m.opts.OnStatusChange != nil {
+			m.opts.OnStatusChange(status)
+		}
+	}
+	return status
+}
+
+// ping pings the session, waiting at most the timeout. A ping that timed out keeps running in background and is awaited
+// by the next checks instead of starting a new one, so an unresponsive server does not pile up goroutines.
+func (m *HealthMonitor) ping() error {
+	m.mux.Lock()
+	p := m.pending
+	if p == nil {
+		p = &healthPing{done: make(chan struct{})}
+		m.pending = p
+		go func() {
+			p.err = m.session.Ping()
+			close(p.done)
+			m.mux.Lock()
+			if m.pending == p {
+				m.pending = nil
+			}
+			m.mux.Unlock()
+		}()
+	}
+	m.mux.Unlock()
+
+	timer := time.NewTimer(m.opts.Timeout)
+	defer timer.Stop()
+	select {
+	case <-p.done:
+		return p.err
+	case <-timer.C:
+		return fmt.Errorf("%w after %v", ErrHealthCheckTimeout, m.opts.Timeout)
+	}
+}
+
+// Healthy indicates whether the session is healthy.
+func (m *HealthMonitor) Healthy() bool {
+	return m.Status().Healthy
+}
+
+// Status returns the status of the last check.
+func (m *HealthMonitor) Status() HealthStatus {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	return m.status
+}
+
+// ReadinessHandler returns an HTTP handler that responds with the status as JSON, with a 200 status code if the session
+// is healthy or 503 otherwise.
+func (m *HealthMonitor) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := m.Status()
+		writeHealth(w, status, status.Healthy)
+	})
+}
+
+// LivenessHandler returns an HTTP handler that responds with the status as JSON, with a 200 status code while the
+// checks are being performed, regardless of the result, or 503 if the last check is older than three intervals, which
+// indicates the monitor is stuck. Database outages are not reported as failures, since restarting the process would
+// not fix them: the pings are bounded by the timeout, so the checks keep completing while the database is unreachable.
+func (m *HealthMonitor) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := m.Status()
+		writeHealth(w, status, !status.LastCheck.IsZero() && m.opts.Now().Sub(status.LastCheck) <= 3*m.opts.Interval)
+	})
+}
+
+func writeHealth(w http.ResponseWriter, status HealthStatus, ok bool) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	if ok {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(status)
+}
+
+// diffServers returns the servers in current not present in previous, and the ones in previous not present in current.
+func diffServers(previous, current []string) (added, removed []string) {
+	prev := map[string]bool{}
+	for _, s := range previous {
+		prev[s] = true
+	}
+	curr := map[string]bool{}
+	for _, s := range current {
+		curr[s] = true
+		if !prev[s] {
+			added = append(added, s)
+		}
+	}
+	for _, s := range previous {
+		if !curr[s] {
+			removed = append(removed, s)
+		}
+	}
+	return
+}
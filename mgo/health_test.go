@@ -0,0 +1,162 @@
+package mgo
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type healthSession struct {
+	ISession
+	mux       sync.Mutex
+	block     chan struct{}
+	err       error
+	servers   []string
+	pings     int
+	refreshes int
+}
+
+func (s *healthSession) Ping() error {
+	s.mux.Lock()
+	s.pings++
+	block := s.block
+	s.mux.Unlock()
+	if block != nil {
+		<-block
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.err
+}
+
+func (s *healthSession) Refresh() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.refreshes++
+}
+
+func (s *healthSession) LiveServers() []string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.servers
+}
+
+func TestHealthMonitor_Check(t *testing.T) {
+	s := &healthSession{servers: []string{"b:27017", "a:27017"}}
+	var statuses []bool
+	var changes [][]string
+	monitor := NewHealthMonitor(s, HealthMonitorOptions{
+		FailureThreshold: 2,
+		OnStatusChange:   func(status HealthStatus) { statuses = append(statuses, status.Healthy) },
+		OnServersChange:  func(added, removed []string) { changes = append(changes, append(added, removed...)) },
+	})
+	assert.False(t, monitor.Healthy())
+
+	status := monitor.Check()
+	assert.True(t, status.Healthy)
+	assert.Equal(t, []string{"a:27017", "b:27017"}, status.LiveServers)
+
+	s.err, s.servers = io.EOF, []string{"a:27017", "c:27017"}
+	status = monitor.Check()
+	assert.True(t, status.Healthy)
+	assert.Equal(t, 1, status.ConsecutiveFailures)
+	assert.Equal(t, io.EOF, status.LastError)
+
+	assert.False(t, monitor.Check().Healthy)
+	assert.Equal(t, 2, s.refreshes)
+
+	s.err = nil
+	assert.True(t, monitor.Check().Healthy)
+	assert.Equal(t, 0, monitor.Status().ConsecutiveFailures)
+
+	assert.Equal(t, []bool{true, false, true}, statuses)
+	assert.Equal(t, [][]string{{"a:27017", "b:27017"}, {"c:27017", "b:27017"}}, changes)
+}
+
+func TestHealthMonitor_Handlers(t *testing.T) {
+	c := &clock{now: time.Now()}
+	s := &healthSession{err: io.EOF, servers: []string{}}
+	monitor := NewHealthMonitor(s, HealthMonitorOptions{Interval: time.Second, Now: c.Now})
+
+	serve := func(h http.Handler) (int, map[string]interface{}) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		body := map[string]interface{}{}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		return rec.Code, body
+	}
+
+	code, _ := serve(monitor.LivenessHandler())
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+
+	monitor.Check()
+	code, _ = serve(monitor.LivenessHandler())
+	assert.Equal(t, http.StatusOK, code)
+	code, body := serve(monitor.ReadinessHandler())
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "EOF", body["error"])
+	assert.Equal(t, false, body["healthy"])
+
+	s.err = nil
+	monitor.Check()
+	code, body = serve(monitor.ReadinessHandler())
+	assert.Equal(t, http.StatusOK, code)
+	assert.Nil(t, body["error"])
+
+	c.now = c.now.Add(4 * time.Second)
+	code, _ = serve(monitor.LivenessHandler())
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+}
+
+func TestHealthMonitor_StartStop(t *testing.T) {
+	s := &healthSession{}
+	monitor := NewHealthMonitor(s, HealthMonitorOptions{Interval: time.Millisecond})
+	monitor.Start()
+	monitor.Start()
+	assert.True(t, monitor.Healthy())
+
+	assert.Eventually(t, func() bool {
+		s.mux.Lock()
+		defer s.mux.Unlock()
+		return s.pings >= 3
+	}, time.Second, time.Millisecond)
+
+	monitor.Stop()
+	s.mux.Lock()
+	pings := s.pings
+	s.mux.Unlock()
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(t, pings, s.pings)
+	monitor.Stop()
+}
+
+func TestHealthMonitor_PingTimeout(t *testing.T) {
+	s := &healthSession{block: make(chan struct{}), err: io.EOF}
+	monitor := NewHealthMonitor(s, HealthMonitorOptions{Interval: 100 * time.Millisecond, Timeout: 10 * time.Millisecond})
+
+	start := time.Now()
+	status := monitor.Check()
+	assert.Less(t, int64(time.Since(start)), int64(50*time.Millisecond))
+	assert.True(t, errors.Is(status.LastError, ErrHealthCheckTimeout))
+	assert.False(t, status.LastCheck.IsZero())
+
+	// The stuck ping is awaited instead of starting a new one.
+	status = monitor.Check()
+	assert.True(t, errors.Is(status.LastError, ErrHealthCheckTimeout))
+	assert.Equal(t, 2, status.ConsecutiveFailures)
+	s.mux.Lock()
+	assert.Equal(t, 1, s.pings)
+	block := s.block
+	s.block, s.err = nil, nil
+	s.mux.Unlock()
+
+	close(block)
+	assert.Eventually(t, func() bool { return monitor.Check().Healthy }, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,333 @@
+package mgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/mgo.v2"
+)
+
+// Error code returned by MongoDB when the collection being inspected does not exist.
+const errCodeNamespaceNotFound = 26
+
+// IndexConflict represents a declared index which key already exists in the collection with different options.
+type IndexConflict struct {
+	Desired     Index
+	Existing    Index
+	Differences []string
+}
+
+// IndexDiff contains the result of comparing a list of desired indexes with the indexes existing in a collection.
+type IndexDiff struct {
+	Collection  string
+	Missing     []Index
+	Extra       []Index
+	Conflicting []*IndexConflict
+	Matching    []Index
+}
+
+// IndexPlan contains the index changes required for a set of collections to match their desired indexes.
+type IndexPlan struct {
+	Collections []*IndexDiff
+}
+
+// DiffIndexes compares the desired indexes with the existing ones. Indexes are matched by their key:
+//
+//    - Desired indexes which key does not exist are reported as missing.
+//    - Existing indexes which key is not desired are reported as extra. The default '_id_' index is never reported.
+//    - Indexes with the same key but different options are reported as conflicting.
+//
+func DiffIndexes(desired, existing []Index) *IndexDiff {
+	diff := &IndexDiff{}
+	byKey := map[string]Index{}
+	wanted := map[string]bool{}
+
+	for _, idx := range existing {
+		byKey[indexKey(idx.Key)] = idx
+	}
+
+	for _, idx := range desired {
+		key := indexKey(idx.Key)
+		wanted[key] = true
+
+		current, ok := byKey[key]
+		if !ok {
+			diff.Missing = append(diff.Missing, idx)
+		} else if differences := compareIndexes(idx, current); len(differences) > 0 {
+			diff.Conflicting = append(diff.Conflicting, &IndexConflict{
+				Desired:     idx,
+				Existing:    current,
+				Differences: differences,
+			})
+		} else {
+			diff.Matching = append(diff.Matching, idx)
+		}
+	}
+
+	for _, idx := range existing {
+		if idx.Name != idIndexName && !wanted[indexKey(idx.Key)] {
+			diff.Extra = append(diff.Extra, idx)
+		}
+	}
+
+	return diff
+}
+
+// PlanIndexes compares the desired indexes of multiple collections with the ones existing in the database without
+// applying any changes. Collections that do not exist yet are treated as collections without indexes.
+//
+//   {db}       - The database containing the collections
+//   {desired}  - The desired indexes by collection name
+//
+func PlanIndexes(db IDatabase, desired map[string][]Index) (*IndexPlan, error) {
+	names := make([]string, 0, len(desired))
+	for name := range desired {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	plan := &IndexPlan{}
+
+	for _, name := range names {
+		existing, err := db.C(name).Indexes()
+		if err != nil && !isNamespaceNotFound(err) {
+			return nil, fmt.Errorf("unable to obtain the indexes of collection [%s], %v", name, err)
+		}
+		diff := DiffIndexes(desired[name], existing)
+		diff.Collection = name
+		plan.Collections = append(plan.Collections, diff)
+	}
+
+	return plan, nil
+}
+
+// HasChanges indicates whether the collection requires index changes.
+func (d *IndexDiff) HasChanges() bool {
+	return len(d.Missing) > 0 || len(d.Extra) > 0 || len(d.Conflicting) > 0
+}
+
+// String renders the diff in a human readable format.
+func (d *IndexDiff) String() string {
+	b := &strings.Builder{}
+
+	if !d.HasChanges() {
+		fmt.Fprintf(b, "collection [%s]: up to date\n", d.Collection)
+		return b.String()
+	}
+
+	fmt.Fprintf(b, "collection [%s]:\n", d.Collection)
+	for _, idx := range d.Missing {
+		fmt.Fprintf(b, "  + create %s\n", describeIndex(idx))
+	}
+	for _, idx := range d.Extra {
+		fmt.Fprintf(b, "  - drop   %s\n", describeIndex(idx))
+	}
+	for _, c := range d.Conflicting {
+		fmt.Fprintf(b, "  ~ change %s: %s\n", describeIndex(c.Existing), strings.Join(c.Differences, ", "))
+	}
+	return b.String()
+}
+
+// MarshalJSON implements json.Marshaler
+func (d *IndexDiff) MarshalJSON() ([]byte, error) {
+	type conflict struct {
+		Desired     *planIndex `json:"desired"`
+		Existing    *planIndex `json:"existing"`
+		Differences []string   `json:"differences"`
+	}
+
+	ret := struct {
+		Collection  string       `json:"collection"`
+		Missing     []*planIndex `json:"missing"`
+		Extra       []*planIndex `json:"extra"`
+		Conflicting []*conflict  `json:"conflicting"`
+		Matching    []*planIndex `json:"matching"`
+	}{
+		Collection:  d.Collection,
+		Missing:     toPlanIndexes(d.Missing),
+		Extra:       toPlanIndexes(d.Extra),
+		Conflicting: []*conflict{},
+		Matching:    toPlanIndexes(d.Matching),
+	}
+
+	for _, c := range d.Conflicting {
+		ret.Conflicting = append(ret.Conflicting, &conflict{
+			Desired:     toPlanIndex(c.Desired),
+			Existing:    toPlanIndex(c.Existing),
+			Differences: c.Differences,
+		})
+	}
+
+	return json.Marshal(ret)
+}
+
+// HasChanges indicates whether any of the collections in the plan requires index changes.
+func (p *IndexPlan) HasChanges() bool {
+	for _, d := range p.Collections {
+		if d.HasChanges() {
+			return true
+		}
+	}
+	return false
+}
+
+// String renders the plan in a human readable format.
+func (p *IndexPlan) String() string {
+	b := &strings.Builder{}
+	for _, d := range p.Collections {
+		b.WriteString(d.String())
+	}
+	return b.String()
+}
+
+// JSON renders the plan in JSON format.
+func (p *IndexPlan) JSON() ([]byte, error) {
+	return json.MarshalIndent(p, "", "  ")
+}
+
+// MarshalJSON implements json.Marshaler
+func (p *IndexPlan) MarshalJSON() ([]byte, error) {
+	collections := p.Collections
+	if collections == nil {
+		collections = []*IndexDiff{}
+	}
+	return json.Marshal(struct {
+		HasChanges  bool         `json:"has_changes"`
+		Collections []*IndexDiff `json:"collections"`
+	}{
+		HasChanges:  p.HasChanges(),
+		Collections: collections,
+	})
+}
+
+// planIndex is the JSON representation of an Index in a plan.
+type planIndex struct {
+	Name        string         `json:"name"`
+	Key         []string       `json:"key"`
+	Unique      bool           `json:"unique,omitempty"`
+	Sparse      bool           `json:"sparse,omitempty"`
+	ExpireAfter string         `json:"expire_after,omitempty"`
+	Weights     map[string]int `json:"weights,omitempty"`
+	Collation   *mgo.Collation `json:"collation,omitempty"`
+}
+
+func toPlanIndex(idx Index) *planIndex {
+	ret := &planIndex{
+		Name:      indexName(idx),
+		Key:       idx.Key,
+		Unique:    idx.Unique,
+		Sparse:    idx.Sparse,
+		Weights:   idx.Weights,
+		Collation: idx.Collation,
+	}
+	if idx.ExpireAfter > 0 {
+		ret.ExpireAfter = idx.ExpireAfter.String()
+	}
+	return ret
+}
+
+func toPlanIndexes(indexes []Index) []*planIndex {
+	ret := make([]*planIndex, 0, len(indexes))
+	for _, idx := range indexes {
+		ret = append(ret, toPlanIndex(idx))
+	}
+	return ret
+}
+
+// compareIndexes returns the list of options that differ between the desired and the existing index. Options that are
+// not persisted by the server (such as Background or DropDups) are ignored, and the name and text options are only
+// compared when they are explicitly set in the desired index.
+func compareIndexes(desired, existing Index) []string {
+	var ret []string
+
+	diff := func(option string, want, got interface{}) {
+		ret = append(ret, fmt.Sprintf("%s (existing: %v, desired: %v)", option, got, want))
+	}
+
+	if desired.Name != "" && desired.Name != existing.Name {
+		diff("name", desired.Name, existing.Name)
+	}
+	if desired.Unique != existing.Unique {
+		diff("unique", desired.Unique, existing.Unique)
+	}
+	if desired.Sparse != existing.Sparse {
+		diff("sparse", desired.Sparse, existing.Sparse)
+	}
+	if desired.ExpireAfter != existing.ExpireAfter {
+		diff("expire_after", desired.ExpireAfter, existing.ExpireAfter)
+	}
+	if desired.Bits != existing.Bits {
+		diff("bits", desired.Bits, existing.Bits)
+	}
+	if desired.Min != existing.Min || desired.Max != existing.Max {
+		diff("min/max", fmt.Sprintf("%d/%d", desired.Min, desired.Max), fmt.Sprintf("%d/%d", existing.Min, existing.Max))
+	}
+	if desired.BucketSize != existing.BucketSize {
+		diff("bucket_size", desired.BucketSize, existing.BucketSize)
+	}
+	if desired.DefaultLanguage != "" && desired.DefaultLanguage != existing.DefaultLanguage {
+		diff("default_language", desired.DefaultLanguage, existing.DefaultLanguage)
+	}
+	if desired.LanguageOverride != "" && desired.LanguageOverride != existing.LanguageOverride {
+		diff("language_override", desired.LanguageOverride, existing.LanguageOverride)
+	}
+	if len(desired.Weights) > 0 && !reflect.DeepEqual(desired.Weights, existing.Weights) {
+		diff("weights", desired.Weights, existing.Weights)
+	}
+	if desired.Collation != nil && !reflect.DeepEqual(desired.Collation, existing.Collation) {
+		diff("collation", desired.Collation, existing.Collation)
+	}
+
+	return ret
+}
+
+// indexName returns the name of the index, or the name MongoDB would generate for it if none was provided.
+func indexName(idx Index) string {
+	if idx.Name != "" {
+		return idx.Name
+	}
+
+	parts := make([]string, 0, len(idx.Key))
+	for _, field := range idx.Key {
+		kind := "1"
+		if strings.HasPrefix(field, "$") {
+			if i := strings.Index(field, ":"); i > 1 {
+				kind, field = field[1:i], field[i+1:]
+			}
+		}
+		switch {
+		case strings.HasPrefix(field, "-"):
+			kind, field = "-1", field[1:]
+		case strings.HasPrefix(field, "@"):
+			kind, field = "2d", field[1:]
+		case strings.HasPrefix(field, "+"):
+			field = field[1:]
+		}
+		parts = append(parts, field+"_"+kind)
+	}
+	return strings.Join(parts, "_")
+}
+
+func describeIndex(idx Index) string {
+	ret := fmt.Sprintf("%s {%s}", indexName(idx), strings.Join(idx.Key, ", "))
+	if idx.Unique {
+		ret += " unique"
+	}
+	if idx.Sparse {
+		ret += " sparse"
+	}
+	if idx.ExpireAfter > 0 {
+		ret += " ttl=" + idx.ExpireAfter.String()
+	}
+	return ret
+}
+
+func isNamespaceNotFound(err error) bool {
+	if qe, ok := err.(*mgo.QueryError); ok {
+		return qe.Code == errCodeNamespaceNotFound
+	}
+	return strings.Contains(err.Error(), "ns does not exist")
+}
@@ -0,0 +1,89 @@
+package mgo
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffIndexes(t *testing.T) {
+	existing := []Index{
+		{Key: []string{"_id"}, Name: "_id_"},
+		{Key: []string{"email"}, Name: "email_1", Unique: true},
+		{Key: []string{"session"}, Name: "session_1", ExpireAfter: time.Hour},
+		{Key: []string{"legacy"}, Name: "legacy_1"},
+	}
+	desired := []Index{
+		{Key: []string{"email"}, Unique: true, Background: true},
+		{Key: []string{"session"}, ExpireAfter: 2 * time.Hour},
+		{Key: []string{"first_name", "-last_name"}},
+	}
+
+	diff := DiffIndexes(desired, existing)
+	assert.True(t, diff.HasChanges())
+	assert.Equal(t, []Index{desired[0]}, diff.Matching)
+	assert.Equal(t, []Index{desired[2]}, diff.Missing)
+	assert.Equal(t, []Index{existing[3]}, diff.Extra)
+	assert.Len(t, diff.Conflicting, 1)
+	assert.Equal(t, []string{"expire_after (existing: 1h0m0s, desired: 2h0m0s)"}, diff.Conflicting[0].Differences)
+
+	assert.False(t, DiffIndexes(existing[1:], existing).HasChanges())
+}
+
+func TestPlanIndexes(t *testing.T) {
+	db := MockDb()
+	db.WhenC("users", &indexCollection{existing: []Index{
+		{Key: []string{"_id"}, Name: "_id_"},
+		{Key: []string{"email"}, Name: "email_1"},
+		{Key: []string{"legacy"}, Name: "legacy_1"},
+	}})
+	db.WhenC("events", &indexCollection{existing: []Index{
+		{Key: []string{"_id"}, Name: "_id_"},
+		{Key: []string{"-created_at"}, Name: "created_at_-1"},
+	}})
+
+	plan, err := PlanIndexes(db, map[string][]Index{
+		"users": {
+			{Key: []string{"email"}, Unique: true},
+			{Key: []string{"$text:bio"}},
+		},
+		"events": {
+			{Key: []string{"-created_at"}},
+		},
+	})
+
+	assert.NoError(t, err)
+	assert.True(t, plan.HasChanges())
+	assert.Equal(t, ""+
+		"collection [events]: up to date\n"+
+		"collection [users]:\n"+
+		"  + create bio_text {$text:bio}\n"+
+		"  - drop   legacy_1 {legacy}\n"+
+		"  ~ change email_1 {email}: unique (existing: false, desired: true)\n",
+		plan.String())
+
+	data, err := plan.JSON()
+	assert.NoError(t, err)
+
+	var decoded struct {
+		HasChanges  bool `json:"has_changes"`
+		Collections []struct {
+			Collection string `json:"collection"`
+			Missing    []struct {
+				Name string   `json:"name"`
+				Key  []string `json:"key"`
+			} `json:"missing"`
+			Conflicting []struct {
+				Differences []string `json:"differences"`
+			} `json:"conflicting"`
+		} `json:"collections"`
+	}
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.True(t, decoded.HasChanges)
+	assert.Len(t, decoded.Collections, 2)
+	assert.Equal(t, "users", decoded.Collections[1].Collection)
+	assert.Equal(t, "bio_text", decoded.Collections[1].Missing[0].Name)
+	assert.Len(t, decoded.Collections[1].Conflicting, 1)
+}
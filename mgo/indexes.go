@@ -0,0 +1,310 @@
+package mgo
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/jucardi/go-mongodb-lib/log"
+)
+
+// IndexTag is the struct tag name used to declare indexes on model types.
+//
+//   Supported tag options (comma separated, multiple declarations on the same field may be separated by ';'):
+//
+//      index           - Marks the field as indexed. Required for the declaration to be considered.
+//      unique          - Prevents two documents from having the same index key.
+//      sparse          - Only indexes documents containing the key fields.
+//      background      - Builds the index in background.
+//      desc            - Uses descending order for the field.
+//      text            - Creates a text index for the field.
+//      hashed          - Creates a hashed index for the field.
+//      2dsphere        - Creates a 2dsphere index for the field.
+//      ttl=<duration>  - Expires documents after the given duration (eg: 'ttl=24h'). Not supported in groups since
+//                        MongoDB only supports TTL in single field indexes.
+//      name=<name>     - Uses the given name for the index.
+//      group=<name>    - Groups the field with other fields declaring the same group into a compound index. Fields are
+//                        added to the compound key in declaration order, and the options of every member apply to the
+//                        whole index.
+//
+//   Example:
+//
+//      type User struct {
+//          Email     string    `bson:"email" mgo:"index,unique"`
+//          FirstName string    `bson:"first_name" mgo:"index,group=name"`
+//          LastName  string    `bson:"last_name" mgo:"index,group=name,desc"`
+//          Session   time.Time `bson:"session" mgo:"index,ttl=1h"`
+//      }
+//
+const IndexTag = "mgo"
+
+const idIndexName = "_id_"
+
+// SyncOptions defines the behavior of SyncIndexes.
+type SyncOptions struct {
+	// DropUndeclared indicates whether indexes that exist in the collection but are not declared should be dropped.
+	// The default '_id_' index is never dropped.
+	DropUndeclared bool
+}
+
+// SyncReport contains the changes performed by SyncIndexes.
+type SyncReport struct {
+	Collection  string           `json:"collection"`
+	Created     []Index          `json:"created,omitempty"`
+	Dropped     []Index          `json:"dropped,omitempty"`
+	Unchanged   []Index          `json:"unchanged,omitempty"`
+	Conflicting []*IndexConflict `json:"-"`
+}
+
+// IndexesOf reads the index declarations from the struct tags of the provided model and returns the resulting list of
+// indexes. The model may be a struct value, a pointer to a struct or a reflect.Type of either. See IndexTag for the
+// supported tag options.
+func IndexesOf(model interface{}) ([]Index, error) {
+	t, ok := model.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(model)
+	}
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("unable to read indexes, the model must be a struct, got %v", t)
+	}
+
+	p := &indexParser{groups: map[string]*Index{}}
+	if err := p.parse(t, "", map[reflect.Type]bool{}); err != nil {
+		return nil, err
+	}
+	p.flush()
+	return p.indexes, nil
+}
+
+// SyncIndexes compares the provided declared indexes with the ones existing in the collection, creates the missing
+// ones and optionally drops the ones that are not declared (see SyncOptions). Declared indexes which key already exists
+// with different options are not modified, they are reported as conflicting so they can be addressed manually.
+//
+//   {col}      - The collection to synchronize
+//   {indexes}  - The declared indexes. Use IndexesOf to obtain them from a model type
+//   {opts}     - (Optional) Synchronization options
+//
+func SyncIndexes(col ICollection, indexes []Index, opts ...SyncOptions) (*SyncReport, error) {
+	cfg := SyncOptions{}
+	if len(opts) > 0 {
+		cfg = opts[0]
+	}
+
+	existing, err := col.Indexes()
+	if err != nil && !isNamespaceNotFound(err) {
+		return nil, fmt.Errorf("unable to obtain the indexes of collection [%s], %v", col.Name(), err)
+	}
+
+	diff := DiffIndexes(indexes, existing)
+	report := &SyncReport{
+		Collection:  col.Name(),
+		Unchanged:   diff.Matching,
+		Conflicting: diff.Conflicting,
+	}
+
+	for _, c := range diff.Conflicting {
+		log.Get().Warn(fmt.Sprintf("collection [%s] index [%s] differs from its declaration: %s", col.Name(), indexName(c.Existing), strings.Join(c.Differences, ", ")))
+	}
+
+	for _, idx := range diff.Missing {
+		if err := col.EnsureIndex(idx); err != nil {
+			return report, fmt.Errorf("unable to create index [%s] in collection [%s], %v", indexName(idx), col.Name(), err)
+		}
+		log.Get().Info(fmt.Sprintf("collection [%s] index [%s] created", col.Name(), indexName(idx)))
+		report.Created = append(report.Created, idx)
+	}
+
+	if !cfg.DropUndeclared {
+		return report, nil
+	}
+
+	for _, idx := range diff.Extra {
+		if err := col.DropIndexName(idx.Name); err != nil {
+			return report, fmt.Errorf("unable to drop index [%s] in collection [%s], %v", idx.Name, col.Name(), err)
+		}
+		log.Get().Info(fmt.Sprintf("collection [%s] index [%s] dropped", col.Name(), idx.Name))
+		report.Dropped = append(report.Dropped, idx)
+	}
+
+	return report, nil
+}
+
+// SyncModelIndexes is a convenience helper equivalent to:
+//
+//     indexes, err := IndexesOf(model)
+//     ...
+//     SyncIndexes(col, indexes, opts...)
+//
+func SyncModelIndexes(col ICollection, model interface{}, opts ...SyncOptions) (*SyncReport, error) {
+	indexes, err := IndexesOf(model)
+	if err != nil {
+		return nil, err
+	}
+	return SyncIndexes(col, indexes, opts...)
+}
+
+func indexKey(key []string) string {
+	return strings.Join(key, ",")
+}
+
+type indexParser struct {
+	indexes []Index
+	groups  map[string]*Index
+	order   []string
+}
+
+func (p *indexParser) parse(t reflect.Type, prefix string, visiting map[reflect.Type]bool) error {
+	if visiting[t] {
+		return nil
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+
+		name, inline := bsonName(f)
+		if name == "-" {
+			continue
+		}
+		if !inline {
+			name = prefix + name
+		}
+
+		if tag, ok := f.Tag.Lookup(IndexTag); ok {
+			for _, decl := range strings.Split(tag, ";") {
+				if err := p.declare(name, f.Name, decl); err != nil {
+					return err
+				}
+			}
+		}
+
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if ft.Kind() != reflect.Struct || ft == reflect.TypeOf(time.Time{}) {
+			continue
+		}
+		next := name + "."
+		if inline {
+			next = prefix
+		}
+		if err := p.parse(ft, next, visiting); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// flush appends the compound indexes of the groups, once all the fields have been parsed since a group may span
+// multiple embedded structs.
+func (p *indexParser) flush() {
+	for _, g := range p.order {
+		p.indexes = append(p.indexes, *p.groups[g])
+	}
+	p.order = nil
+}
+
+func (p *indexParser) declare(field, goName, decl string) error {
+	var (
+		idx    Index
+		group  string
+		key    = field
+		marked bool
+	)
+
+	for _, opt := range strings.Split(decl, ",") {
+		opt = strings.TrimSpace(opt)
+		value := ""
+		if i := strings.Index(opt, "="); i >= 0 {
+			opt, value = opt[:i], opt[i+1:]
+		}
+
+		switch opt {
+		case "":
+		case "index":
+			marked = true
+		case "unique":
+			idx.Unique = true
+		case "sparse":
+			idx.Sparse = true
+		case "background":
+			idx.Background = true
+		case "desc":
+			key = "-" + field
+		case "text", "hashed", "2dsphere":
+			key = "$" + opt + ":" + field
+		case "ttl":
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("invalid ttl '%s' in index declaration of field '%s', %v", value, goName, err)
+			}
+			idx.ExpireAfter = d
+		case "name":
+			idx.Name = value
+		case "group":
+			group = value
+		default:
+			return fmt.Errorf("unknown option '%s' in index declaration of field '%s'", opt, goName)
+		}
+	}
+
+	if !marked {
+		return nil
+	}
+	if group != "" && idx.ExpireAfter > 0 {
+		return fmt.Errorf("invalid ttl in index declaration of field '%s', the compound index of group '%s' cannot expire documents", goName, group)
+	}
+
+	if group == "" {
+		idx.Key = []string{key}
+		p.indexes = append(p.indexes, idx)
+		return nil
+	}
+
+	g, ok := p.groups[group]
+	if !ok {
+		g = &Index{}
+		p.groups[group] = g
+		p.order = append(p.order, group)
+	}
+
+	g.Key = append(g.Key, key)
+	g.Unique = g.Unique || idx.Unique
+	g.Sparse = g.Sparse || idx.Sparse
+	g.Background = g.Background || idx.Background
+	if idx.Name != "" {
+		g.Name = idx.Name
+	}
+	return nil
+}
+
+// bsonName returns the document key used by the bson marshaller for the given field, and whether the field is inlined.
+func bsonName(f reflect.StructField) (string, bool) {
+	tag := f.Tag.Get("bson")
+	if tag == "" && !strings.Contains(string(f.Tag), ":") {
+		tag = string(f.Tag)
+	}
+
+	parts := strings.Split(tag, ",")
+	inline := false
+	for _, flag := range parts[1:] {
+		if flag == "inline" {
+			inline = true
+		}
+	}
+
+	if parts[0] != "" {
+		return parts[0], inline
+	}
+	return strings.ToLower(f.Name), inline
+}
@@ -0,0 +1,129 @@
+package mgo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type indexAddress struct {
+	City string `bson:"city" mgo:"index"`
+}
+
+type indexAudit struct {
+	CreatedAt time.Time `bson:"created_at" mgo:"index,ttl=24h"`
+}
+
+type indexModel struct {
+	indexAudit `bson:",inline"`
+	Id         string       `bson:"_id"`
+	Email      string       `bson:"email" mgo:"index,unique"`
+	FirstName  string       `bson:"first_name" mgo:"index,group=name"`
+	LastName   string       `bson:"last_name" mgo:"index,group=name,desc,name=full_name"`
+	Score      int          `mgo:"index,desc;index,group=name,sparse"`
+	Address    indexAddress `bson:"address"`
+	Ignored    string       `bson:"-" mgo:"index"`
+	Plain      string       `bson:"plain"`
+}
+
+type indexCollection struct {
+	ICollection
+	existing []Index
+	ensured  []Index
+	dropped  []string
+}
+
+func (c *indexCollection) Name() string {
+	return "models"
+}
+
+func (c *indexCollection) Indexes() ([]Index, error) {
+	return c.existing, nil
+}
+
+func (c *indexCollection) EnsureIndex(index Index) error {
+	c.ensured = append(c.ensured, index)
+	return nil
+}
+
+func (c *indexCollection) DropIndexName(name string) error {
+	c.dropped = append(c.dropped, name)
+	return nil
+}
+
+func TestIndexesOf(t *testing.T) {
+	indexes, err := IndexesOf(&indexModel{})
+	assert.NoError(t, err)
+	assert.Equal(t, []Index{
+		{Key: []string{"created_at"}, ExpireAfter: 24 * time.Hour},
+		{Key: []string{"email"}, Unique: true},
+		{Key: []string{"-score"}},
+		{Key: []string{"address.city"}},
+		{Key: []string{"first_name", "-last_name", "score"}, Name: "full_name", Sparse: true},
+	}, indexes)
+}
+
+type indexTenant struct {
+	TenantId string `bson:"tenant_id" mgo:"index,group=tenant_email"`
+	Region   string `bson:"region" mgo:"index,group=tenant_email"`
+}
+
+func TestIndexesOf_GroupSpanningInlineEmbed(t *testing.T) {
+	indexes, err := IndexesOf(struct {
+		indexTenant `bson:",inline"`
+		Email       string `bson:"email" mgo:"index,group=tenant_email,unique"`
+	}{})
+	assert.NoError(t, err)
+	assert.Equal(t, []Index{
+		{Key: []string{"tenant_id", "region", "email"}, Unique: true},
+	}, indexes)
+}
+
+func TestIndexesOf_Errors(t *testing.T) {
+	_, err := IndexesOf("not a struct")
+	assert.Error(t, err)
+
+	_, err = IndexesOf(struct {
+		A string `mgo:"index,ttl=forever"`
+	}{})
+	assert.Error(t, err)
+
+	_, err = IndexesOf(struct {
+		A string `mgo:"index,unknown"`
+	}{})
+	assert.Equal(t, "unknown option 'unknown' in index declaration of field 'A'", err.Error())
+
+	_, err = IndexesOf(struct {
+		A string `mgo:"index,group=g"`
+		B string `mgo:"index,group=g,ttl=1h"`
+	}{})
+	assert.EqualError(t, err, "invalid ttl in index declaration of field 'B', the compound index of group 'g' cannot expire documents")
+}
+
+func TestSyncIndexes(t *testing.T) {
+	col := &indexCollection{existing: []Index{
+		{Key: []string{"_id"}, Name: "_id_"},
+		{Key: []string{"email"}, Name: "email_1", Unique: true},
+		{Key: []string{"legacy"}, Name: "legacy_1"},
+	}}
+	declared := []Index{
+		{Key: []string{"email"}, Unique: true},
+		{Key: []string{"-score"}},
+	}
+
+	report, err := SyncIndexes(col, declared)
+	assert.NoError(t, err)
+	assert.Equal(t, "models", report.Collection)
+	assert.Equal(t, []Index{{Key: []string{"-score"}}}, report.Created)
+	assert.Equal(t, []Index{{Key: []string{"email"}, Unique: true}}, report.Unchanged)
+	assert.Empty(t, report.Dropped)
+	assert.Empty(t, col.dropped)
+
+	col.ensured = nil
+	report, err = SyncIndexes(col, declared, SyncOptions{DropUndeclared: true})
+	assert.NoError(t, err)
+	assert.Len(t, col.ensured, 1)
+	assert.Equal(t, []string{"legacy_1"}, col.dropped)
+	assert.Len(t, report.Dropped, 1)
+}
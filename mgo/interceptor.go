@@ -0,0 +1,149 @@
+package mgo
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	// OperationRead identifies operations that read documents.
+	OperationRead OperationKind = iota
+	// OperationWrite identifies operations that modify documents.
+	OperationWrite
+	// OperationCommand identifies administrative operations and commands, such as index management or Ping.
+	OperationCommand
+)
+
+// OperationKind indicates the type of a database operation.
+type OperationKind int
+
+// Operation contains the information of a database operation passed through the interceptors. The interceptors may
+// modify the filter, update, documents and pipeline before invoking the next interceptor, and read the duration and the
+// error of the operation after it returns.
+type Operation struct {
+	// Name is the name of the operation, eg: "Insert", "Query.All", "Pipe.One", "Bulk.Run", "Ping".
+	Name string
+	// Kind indicates the type of the operation.
+	Kind OperationKind
+	// Database is the name of the database the operation runs on, if any.
+	Database string
+	// Collection is the name of the collection the operation runs on, if any.
+	Collection string
+	// Filter is the selector or query document of the operation.
+	Filter interface{}
+	// Update is the update document of the operation.
+	Update interface{}
+	// Documents contains the documents to insert.
+	Documents []interface{}
+	// Pipeline is the aggregation pipeline of Pipe operations.
+	Pipeline interface{}
+	// Command is the command document of Run operations.
+	Command interface{}
+	// Sort contains the sort fields of query operations.
+	Sort []string
+	// Projection is the field selector of query operations.
+	Projection interface{}
+	// Skip and Limit of query operations.
+	Skip, Limit int
+	// BulkSize is the amount of operations queued in a bulk operation.
+	BulkSize int
+	// Cursor indicates the operation opens a cursor: Query.Iter, Query.Tail and Pipe.Iter. The duration and the error
+	// of these operations cover the creation of the cursor and its first batch only, the errors of the following
+	// batches are returned by the iterator without going through the interceptors.
+	Cursor bool
+	// Explain runs the explain of the query or pipe of the operation, without going through the interceptors. Only
+	// available for the Query and Pipe operations, nil otherwise.
+	Explain func(result interface{}) error
+	// Returned is the amount of documents returned by the operation. Available once the invoker returns.
+	Returned int
+	// Duration is the time it took to execute the operation. Available once the invoker returns.
+	Duration time.Duration
+	// Err is the error returned by the operation. Available once the invoker returns.
+	Err error
+
+	session func() ISession
+}
+
+// Session returns the session the operation runs on, or nil if it is unknown, eg: when the collection passed to
+// InterceptCollection is not bound to a database.
+func (op *Operation) Session() ISession {
+	if op.session == nil {
+		return nil
+	}
+	return op.session()
+}
+
+// Invoker executes an operation, either by calling the next interceptor in the chain or the database operation itself.
+type Invoker func(op *Operation) error
+
+// Interceptor is a middleware invoked around every database operation. Implementations must call 'next' for the
+// operation to proceed, or may return an error without calling it to abort the operation.
+//
+//   Example:
+//
+//      logger := func(op *mgo.Operation, next mgo.Invoker) error {
+//          err := next(op)
+//          log.Printf("%s on %s took %v, err: %v", op.Name, op.Collection, op.Duration, err)
+//          return err
+//      }
+//
+//      session = mgo.InterceptSession(session, logger)
+//
+type Interceptor func(op *Operation, next Invoker) error
+
+// InterceptSession decorates the provided session so every operation performed through it, and through the databases,
+// collections, queries, pipes and bulks obtained from it, runs through the provided interceptors. Interceptors are
+// invoked in the order they are provided.
+func InterceptSession(s ISession, interceptors ...Interceptor) ISession {
+	if len(interceptors) == 0 {
+		return s
+	}
+	return &interceptedSession{ISession: s, chain: interceptors}
+}
+
+// InterceptDatabase decorates the provided database so every operation performed through it, and through the
+// collections, queries, pipes and bulks obtained from it, runs through the provided interceptors.
+func InterceptDatabase(db IDatabase, interceptors ...Interceptor) IDatabase {
+	if len(interceptors) == 0 {
+		return db
+	}
+	return &interceptedDatabase{IDatabase: db, chain: interceptors}
+}
+
+// InterceptCollection decorates the provided collection so every operation performed through it, and through the
+// queries, pipes and bulks obtained from it, runs through the provided interceptors.
+func InterceptCollection(col ICollection, interceptors ...Interceptor) ICollection {
+	if len(interceptors) == 0 {
+		return col
+	}
+	db, name := splitFullName(col.FullName())
+	return newInterceptedCollection(col, interceptors, db, name)
+}
+
+type interceptorChain []Interceptor
+
+// run executes the call through the interceptor chain.
+func (ic interceptorChain) run(op *Operation, call func(op *Operation) error) error {
+	invoker := func(op *Operation) error {
+		start := time.Now()
+		op.Err = call(op)
+		op.Duration = time.Since(start)
+		return op.Err
+	}
+
+	for i := len(ic) - 1; i >= 0; i-- {
+		interceptor, next := ic[i], invoker
+		invoker = func(op *Operation) error {
+			return interceptor(op, next)
+		}
+	}
+
+	return invoker(op)
+}
+
+func splitFullName(fullName string) (string, string) {
+	if i := strings.Index(fullName, "."); i >= 0 {
+		return fullName[:i], fullName[i+1:]
+	}
+	return "", fullName
+}
@@ -0,0 +1,137 @@
+package mgo
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jucardi/go-mongodb-lib/log"
+)
+
+const (
+	// DefaultRetryMaxAttempts is the default amount of attempts, including the first one, of a retried operation.
+	DefaultRetryMaxAttempts = 3
+	// DefaultRetryInitialBackoff is the default time to wait before the first retry.
+	DefaultRetryInitialBackoff = 100 * time.Millisecond
+	// DefaultRetryMaxBackoff is the default maximum time to wait between retries.
+	DefaultRetryMaxBackoff = 5 * time.Second
+	// DefaultRetryMultiplier is the default factor by which the backoff grows after every retry.
+	DefaultRetryMultiplier = 2
+	// DefaultRetryJitter is the default fraction of the backoff randomly added or subtracted to spread the retries.
+	DefaultRetryJitter = 0.2
+)
+
+// sleep is replaced in the tests to avoid waiting for the backoffs.
+var sleep = time.Sleep
+
+// RetryPolicy defines which operations are retried and how.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum amount of attempts, including the first one. Defaults to DefaultRetryMaxAttempts.
+	MaxAttempts int
+	// InitialBackoff is the time to wait before the first retry. Defaults to DefaultRetryInitialBackoff.
+	InitialBackoff time.Duration
+	// MaxBackoff is the maximum time to wait between retries. Defaults to DefaultRetryMaxBackoff.
+	MaxBackoff time.Duration
+	// Multiplier is the factor by which the backoff grows after every retry. Defaults to DefaultRetryMultiplier.
+	Multiplier float64
+	// Jitter is the fraction of the backoff randomly added or subtracted to it, between 0 and 1. Defaults to
+	// DefaultRetryJitter, a negative value disables it.
+	Jitter float64
+	// Retryable indicates whether an error is transient and the operation should be retried. Defaults to IsRetryable.
+	Retryable func(err error) bool
+	// RetryWrites enables retrying write operations. Reads are idempotent and are always retried, but a write may have
+	// been applied before the error occurred, so retrying it is only safe for idempotent writes, eg: updates with $set.
+	RetryWrites bool
+}
+
+// Retry returns an Interceptor that retries the read operations, and optionally the write operations, that fail with a
+// transient error, waiting an exponential backoff with jitter between attempts and refreshing the session before
+// retrying so a new socket is obtained. Commands are never retried.
+//
+// Interceptors placed after Retry in the chain are invoked on every attempt, those placed before it are invoked once.
+//
+//   Example:
+//
+//      session = mgo.InterceptSession(session, mgo.Retry(mgo.RetryPolicy{MaxAttempts: 5, RetryWrites: true}))
+//
+func Retry(policy ...RetryPolicy) Interceptor {
+	p := RetryPolicy{}
+	if len(policy) > 0 {
+		p = policy[0]
+	}
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = DefaultRetryMaxAttempts
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = DefaultRetryInitialBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = DefaultRetryMaxBackoff
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = DefaultRetryMultiplier
+	}
+	if p.Jitter == 0 {
+		p.Jitter = DefaultRetryJitter
+	}
+	if p.Retryable == nil {
+		p.Retryable = IsRetryable
+	}
+
+	return func(op *Operation, next Invoker) error {
+		if !p.retries(op) {
+			return next(op)
+		}
+
+		err := next(op)
+		for attempt := 1; err != nil && attempt < p.MaxAttempts && p.Retryable(err); attempt++ {
+			backoff := p.backoff(attempt)
+			log.With(
+				log.F("operation", op.Name),
+				log.F("collection", op.Collection),
+				log.F("attempt", attempt+1),
+				log.F("max_attempts", p.MaxAttempts),
+				log.F("retry_in", backoff.String()),
+				log.F("error", err.Error()),
+			).Warn("Retrying operation")
+
+			sleep(backoff)
+			if s := op.Session(); s != nil {
+				s.Refresh()
+			}
+			err = next(op)
+		}
+		return err
+	}
+}
+
+func (p RetryPolicy) retries(op *Operation) bool {
+	switch op.Kind {
+	case OperationRead:
+		return true
+	case OperationWrite:
+		return p.RetryWrites
+	}
+	return false
+}
+
+// backoff returns the time to wait before the provided retry, starting at 1.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	return WithJitter(ExponentialBackoff(p.InitialBackoff, p.MaxBackoff, p.Multiplier), p.Jitter)(retry)
+}
+
+// IsRetryable indicates whether the error is a transient error after which the operation may succeed if retried, such
+// as network errors, socket timeouts and primary step downs. See Classify.
+func IsRetryable(err error) bool {
+	var e *Error
+	if !errors.As(Classify(err), &e) {
+		return false
+	}
+	switch e.Category {
+	case ErrNetwork, ErrNotPrimary:
+		return true
+	case ErrTimeout:
+		// The operations that exceeded their server time limit would exceed it again.
+		return e.Code != 50 && e.Code != 262
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package mgo
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/mgo.v2"
+)
+
+type refreshSession struct {
+	ISession
+	refreshed int
+}
+
+func (s *refreshSession) Refresh() {
+	s.refreshed++
+}
+
+// failing returns an invoker that fails with the provided errors before succeeding.
+func failing(calls *int, errs ...error) Invoker {
+	return func(op *Operation) error {
+		*calls++
+		if *calls <= len(errs) {
+			return errs[*calls-1]
+		}
+		return nil
+	}
+}
+
+func noSleep(t *testing.T) *[]time.Duration {
+	var waits []time.Duration
+	sleep = func(d time.Duration) { waits = append(waits, d) }
+	t.Cleanup(func() { sleep = time.Sleep })
+	return &waits
+}
+
+func TestRetry_Read(t *testing.T) {
+	waits := noSleep(t)
+	s := &refreshSession{}
+	op := &Operation{Name: "Query.One", Kind: OperationRead, session: func() ISession { return s }}
+
+	calls := 0
+	err := Retry(RetryPolicy{InitialBackoff: time.Second, Jitter: -1})(op, failing(&calls, io.EOF, &mgo.QueryError{Code: 10107}))
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, 2, s.refreshed)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, *waits)
+}
+
+func TestRetry_MaxAttempts(t *testing.T) {
+	noSleep(t)
+	calls := 0
+	err := Retry(RetryPolicy{MaxAttempts: 2})(&Operation{Kind: OperationRead}, failing(&calls, io.EOF, io.EOF, io.EOF))
+
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 2, calls)
+}
+
+func TestRetry_NotRetryable(t *testing.T) {
+	noSleep(t)
+	calls := 0
+	err := Retry()(&Operation{Kind: OperationRead}, failing(&calls, ErrNotFound))
+
+	assert.Equal(t, ErrNotFound, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetry_Writes(t *testing.T) {
+	noSleep(t)
+
+	calls := 0
+	assert.Equal(t, io.EOF, Retry()(&Operation{Kind: OperationWrite}, failing(&calls, io.EOF)))
+	assert.Equal(t, 1, calls)
+
+	calls = 0
+	assert.NoError(t, Retry(RetryPolicy{RetryWrites: true})(&Operation{Kind: OperationWrite}, failing(&calls, io.EOF)))
+	assert.Equal(t, 2, calls)
+
+	calls = 0
+	assert.Equal(t, io.EOF, Retry(RetryPolicy{RetryWrites: true})(&Operation{Kind: OperationCommand}, failing(&calls, io.EOF)))
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 3, Jitter: 0.5}
+	for retry, expected := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 300 * time.Millisecond, 3: 900 * time.Millisecond, 4: time.Second} {
+		backoff := p.backoff(retry)
+		assert.True(t, backoff >= expected/2 && backoff <= expected*3/2, "retry %d: %v", retry, backoff)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	assert.True(t, IsRetryable(io.EOF))
+	assert.True(t, IsRetryable(&net.OpError{Op: "read", Err: errors.New("connection reset by peer")}))
+	assert.True(t, IsRetryable(&mgo.LastError{Code: 189}))
+	assert.True(t, IsRetryable(errors.New("no reachable servers")))
+	assert.False(t, IsRetryable(nil))
+	assert.False(t, IsRetryable(ErrNotFound))
+	assert.False(t, IsRetryable(&mgo.LastError{Code: 11000}))
+	assert.False(t, IsRetryable(errors.New("boom")))
+}
@@ -0,0 +1,129 @@
+package mgo
+
+import (
+	"strings"
+	"time"
+
+	"github.com/jucardi/go-mongodb-lib/log"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// DefaultSlowThreshold is the threshold used by SlowLog if none is provided.
+const DefaultSlowThreshold = 100 * time.Millisecond
+
+// SlowLogOptions defines when and how the slow operations are logged.
+type SlowLogOptions struct {
+	// Threshold is the duration above which a query or pipe operation is considered slow. Defaults to
+	// DefaultSlowThreshold.
+	Threshold time.Duration
+	// Explain indicates whether the slow operations should be explained to attach the winning plan and the amount of
+	// documents and keys examined to the log entry. Explaining runs the query again, so it should be enabled with a
+	// threshold high enough to keep the amount of explains low.
+	Explain bool
+}
+
+// SlowLog returns an Interceptor that logs the query and pipe operations that take longer than the configured
+// threshold through log.Get(), including the collection, filter, sort, projection and duration of the operation as
+// fields of the entry. See log.With. The operations opening cursors are not logged since their duration does not cover
+// the iteration, see Operation.Cursor.
+//
+//   Example:
+//
+//      session = mgo.InterceptSession(session, mgo.SlowLog(mgo.SlowLogOptions{Threshold: time.Second, Explain: true}))
+//
+func SlowLog(opts ...SlowLogOptions) Interceptor {
+	cfg := SlowLogOptions{}
+	if len(opts) > 0 {
+		cfg = opts[0]
+	}
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = DefaultSlowThreshold
+	}
+
+	return func(op *Operation, next Invoker) error {
+		err := next(op)
+		if op.Explain != nil && op.Kind == OperationRead && !op.Cursor && op.Duration >= cfg.Threshold && !strings.HasSuffix(op.Name, ".Explain") {
+			log.With(slowLogFields(op, cfg.Explain)...).Warn("Slow operation")
+		}
+		return err
+	}
+}
+
+// slowLogFields returns the fields of the slow operation entry. The filter, pipeline and projection are redacted, see
+// log.SetSensitiveFields.
+func slowLogFields(op *Operation, explain bool) []log.Field {
+	fields := []log.Field{
+		log.F("operation", op.Name),
+		log.F("collection", op.Database+"."+op.Collection),
+		log.F("duration", op.Duration.String()),
+	}
+
+	if op.Filter != nil {
+		fields = append(fields, log.F("filter", log.Redact(op.Filter)))
+	}
+	if op.Pipeline != nil {
+		fields = append(fields, log.F("pipeline", log.Redact(op.Pipeline)))
+	}
+	if len(op.Sort) > 0 {
+		fields = append(fields, log.F("sort", op.Sort))
+	}
+	if op.Projection != nil {
+		fields = append(fields, log.F("projection", log.Redact(op.Projection)))
+	}
+
+	if explain {
+		result := bson.M{}
+		if err := op.Explain(&result); err != nil {
+			fields = append(fields, log.F("explain_error", err.Error()))
+		} else {
+			fields = append(fields, explainSummary(result)...)
+		}
+	}
+
+	return fields
+}
+
+// explainSummary extracts the winning plan and the amount of documents and keys examined from the result of an
+// explain. Supports the explain format of MongoDB 3.0+ for queries and pipes, and the legacy query format.
+func explainSummary(result bson.M) []log.Field {
+	var ret []log.Field
+	if plan, ok := findExplainKey(result, "winningPlan"); ok {
+		ret = append(ret, log.F("plan", plan))
+	} else if cursor, ok := findExplainKey(result, "cursor"); ok {
+		ret = append(ret, log.F("plan", cursor))
+	}
+	if n, ok := findExplainKey(result, "totalDocsExamined"); ok {
+		ret = append(ret, log.F("docs_examined", n))
+	} else if n, ok := findExplainKey(result, "nscannedObjects"); ok {
+		ret = append(ret, log.F("docs_examined", n))
+	}
+	if n, ok := findExplainKey(result, "totalKeysExamined"); ok {
+		ret = append(ret, log.F("keys_examined", n))
+	} else if n, ok := findExplainKey(result, "nscanned"); ok {
+		ret = append(ret, log.F("keys_examined", n))
+	}
+	return ret
+}
+
+// findExplainKey searches the key in the explain result depth-first, since its location depends on the server version
+// and on whether the explained operation is a query or a pipe.
+func findExplainKey(value interface{}, key string) (interface{}, bool) {
+	switch v := value.(type) {
+	case bson.M:
+		if ret, ok := v[key]; ok {
+			return ret, true
+		}
+		for _, child := range v {
+			if ret, ok := findExplainKey(child, key); ok {
+				return ret, true
+			}
+		}
+	case []interface{}:
+		for _, child := range v {
+			if ret, ok := findExplainKey(child, key); ok {
+				return ret, true
+			}
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,107 @@
+package mgo
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jucardi/go-mongodb-lib/log"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// recorderLogger records the warnings and errors logged.
+type recorderLogger struct {
+	entries []string
+}
+
+func (l *recorderLogger) Debug(args ...interface{}) {}
+func (l *recorderLogger) Info(args ...interface{})  {}
+func (l *recorderLogger) Warn(args ...interface{}) {
+	l.entries = append(l.entries, fmt.Sprint(args...))
+}
+func (l *recorderLogger) Error(args ...interface{}) {
+	l.entries = append(l.entries, fmt.Sprint(args...))
+}
+
+func captureLog(t *testing.T) *recorderLogger {
+	logger, previous := &recorderLogger{}, log.Get()
+	log.Set(logger)
+	t.Cleanup(func() { log.Set(previous) })
+	return logger
+}
+
+func TestSlowLog(t *testing.T) {
+	logger := captureLog(t)
+	rec := &recorderCollection{}
+	explained := 0
+
+	col := InterceptCollection(rec, func(op *Operation, next Invoker) error {
+		if op.Explain != nil {
+			explain := op.Explain
+			op.Explain = func(result interface{}) error {
+				explained++
+				*result.(*bson.M) = bson.M{
+					"queryPlanner":   bson.M{"winningPlan": bson.M{"stage": "COLLSCAN"}},
+					"executionStats": bson.M{"totalDocsExamined": 1000, "totalKeysExamined": 0},
+				}
+				return explain(result)
+			}
+		}
+		return next(op)
+	}, SlowLog(SlowLogOptions{Threshold: time.Nanosecond, Explain: true}))
+
+	var result []bson.M
+	assert.NoError(t, col.Find(bson.M{"email": "someone@example.com", "password": "secret"}).Sort("-age").Select(bson.M{"email": 1, "logins": bson.M{"$elemMatch": bson.M{"password": "secret"}}}).All(&result))
+	assert.NoError(t, col.Insert(bson.M{"email": "someone@example.com"}))
+
+	assert.Equal(t, 1, explained)
+	assert.Len(t, logger.entries, 1)
+	assert.Regexp(t, `^Slow operation operation=Query.All collection=test.records duration=\S+ filter={"email":"someone@example.com","password":"\[REDACTED\]"} sort=\["-age"\] projection={"email":1,"logins":{"\$elemMatch":{"password":"\[REDACTED\]"}}} plan={"stage":"COLLSCAN"} docs_examined=1000 keys_examined=0$`, logger.entries[0])
+}
+
+func TestSlowLog_BelowThreshold(t *testing.T) {
+	logger := captureLog(t)
+	col := InterceptCollection(&recorderCollection{}, SlowLog(SlowLogOptions{Threshold: time.Hour, Explain: true}))
+
+	var result []bson.M
+	assert.NoError(t, col.Find(nil).All(&result))
+	assert.Empty(t, logger.entries)
+}
+
+// cursorCollection returns queries which cursors are empty.
+type cursorCollection struct {
+	*recorderCollection
+}
+
+type cursorQuery struct {
+	IQuery
+}
+
+func (c *cursorCollection) Find(query interface{}) IQuery {
+	return &cursorQuery{IQuery: c.recorderCollection.Find(query)}
+}
+
+func (q *cursorQuery) Iter() IIter {
+	return &errIter{}
+}
+
+func (q *cursorQuery) Tail(time.Duration) IIter {
+	return &errIter{}
+}
+
+func TestSlowLog_Cursors(t *testing.T) {
+	logger := captureLog(t)
+	var cursors []string
+	col := InterceptCollection(&cursorCollection{recorderCollection: &recorderCollection{}}, func(op *Operation, next Invoker) error {
+		if op.Cursor {
+			cursors = append(cursors, op.Name)
+		}
+		return next(op)
+	}, SlowLog(SlowLogOptions{Threshold: time.Nanosecond}))
+
+	assert.NoError(t, col.Find(nil).Iter().Close())
+	assert.NoError(t, col.Find(nil).Tail(time.Second).Close())
+	assert.Equal(t, []string{"Query.Iter", "Query.Tail"}, cursors)
+	assert.Empty(t, logger.entries)
+}
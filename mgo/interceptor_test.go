@@ -0,0 +1,106 @@
+package mgo
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestInterceptCollection_Chain(t *testing.T) {
+	rec := &recorderCollection{}
+	var calls []string
+	var captured *Operation
+
+	first := func(op *Operation, next Invoker) error {
+		calls = append(calls, "first:"+op.Name)
+		op.Filter = bson.M{"tenant": "a"}
+		err := next(op)
+		calls = append(calls, "first:done")
+		return err
+	}
+	second := func(op *Operation, next Invoker) error {
+		calls = append(calls, "second:"+op.Name)
+		err := next(op)
+		captured = op
+		return err
+	}
+
+	col := InterceptCollection(rec, first, second)
+	assert.NoError(t, col.Update(bson.M{"_id": "x"}, bson.M{"$set": bson.M{"a": 1}}))
+
+	assert.Equal(t, []string{"first:Update", "second:Update", "first:done"}, calls)
+	assert.Equal(t, bson.M{"tenant": "a"}, rec.Last()[0])
+	assert.Equal(t, "test", captured.Database)
+	assert.Equal(t, "records", captured.Collection)
+	assert.Equal(t, OperationWrite, captured.Kind)
+	assert.Equal(t, bson.M{"$set": bson.M{"a": 1}}, captured.Update)
+	assert.Nil(t, captured.Err)
+}
+
+func TestInterceptCollection_Abort(t *testing.T) {
+	rec := &recorderCollection{}
+	invalid := errors.New("invalid document")
+
+	col := InterceptCollection(rec, func(op *Operation, next Invoker) error {
+		if op.Name == "Insert" && len(op.Documents) > 1 {
+			return invalid
+		}
+		return next(op)
+	})
+
+	assert.Equal(t, invalid, col.Insert(bson.M{"a": 1}, bson.M{"a": 2}))
+	assert.Empty(t, rec.Names())
+	assert.NoError(t, col.Insert(bson.M{"a": 1}))
+	assert.Equal(t, []string{"Insert"}, rec.Names())
+}
+
+func TestInterceptCollection_QueryAndBulk(t *testing.T) {
+	rec := &recorderCollection{}
+	var ops []Operation
+	stop := errors.New("stop")
+
+	col := InterceptCollection(rec, func(op *Operation, next Invoker) error {
+		ops = append(ops, *op)
+		if op.Name == "Bulk.Run" {
+			return stop
+		}
+		return next(op)
+	})
+
+	n, err := col.Find(bson.M{"name": "x"}).Sort("-age").Select(bson.M{"name": 1}).Skip(5).Limit(10).Count()
+	assert.NoError(t, err)
+	assert.Equal(t, rec.count, n)
+	assert.Equal(t, "Query.Count", ops[0].Name)
+	assert.Equal(t, OperationRead, ops[0].Kind)
+	assert.Equal(t, bson.M{"name": "x"}, ops[0].Filter)
+	assert.Equal(t, []string{"-age"}, ops[0].Sort)
+	assert.Equal(t, bson.M{"name": 1}, ops[0].Projection)
+	assert.Equal(t, 5, ops[0].Skip)
+	assert.Equal(t, 10, ops[0].Limit)
+
+	_, err = col.Bulk().Insert(1, 2, 3).Upsert(bson.M{"_id": 1}, bson.M{"a": 1}).Run()
+	assert.Equal(t, stop, err)
+	assert.Equal(t, "Bulk.Run", ops[1].Name)
+	assert.Equal(t, 4, ops[1].BulkSize)
+}
+
+func TestInterceptCollection_Returned(t *testing.T) {
+	rec := &recorderCollection{}
+	var op *Operation
+
+	col := InterceptCollection(rec, func(o *Operation, next Invoker) error {
+		op = o
+		return next(o)
+	})
+
+	rec.allHandler = func(args ...interface{}) []interface{} {
+		*args[0].(*[]bson.M) = []bson.M{{"a": 1}, {"a": 2}}
+		return []interface{}{nil}
+	}
+
+	var result []bson.M
+	assert.NoError(t, col.Find(nil).All(&result))
+	assert.Equal(t, 2, op.Returned)
+}
@@ -38,3 +38,16 @@ type IIter interface {
 	// See Iter as an elegant replacement.
 	For(result interface{}, f func() error) (err error)
 }
+
+// errIter is an IIter that fails with the provided error without returning any document.
+type errIter struct {
+	err error
+}
+
+func (i *errIter) Err() error                          { return i.err }
+func (i *errIter) Close() error                        { return i.err }
+func (i *errIter) Done() bool                          { return true }
+func (i *errIter) Timeout() bool                       { return false }
+func (i *errIter) Next(interface{}) bool               { return false }
+func (i *errIter) All(interface{}) error               { return i.err }
+func (i *errIter) For(interface{}, func() error) error { return i.err }
@@ -0,0 +1,221 @@
+package mgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	// DefaultLocksCollection is the default name of the collection that holds the locks.
+	DefaultLocksCollection = "locks"
+	// DefaultLockRetryInterval is the default time Acquire waits between attempts while the lock is held.
+	DefaultLockRetryInterval = time.Second
+	// DefaultLockCleanupAfter is the default time after their expiration the locks are removed by the TTL index.
+	DefaultLockCleanupAfter = 24 * time.Hour
+)
+
+var (
+	// ErrLockHeld is the error returned by TryAcquire when the lock is held by another owner.
+	ErrLockHeld = errors.New("the lock is held by another owner")
+	// ErrLockLost is the error returned by Refresh and Release when the lock expired and was acquired by another owner,
+	// or was already released.
+	ErrLockLost = errors.New("the lock is no longer held")
+)
+
+// LockManagerOptions defines the owner and timings of a lock manager. See NewLockManager.
+type LockManagerOptions struct {
+	// Owner identifies the process holding the locks, for troubleshooting. Defaults to '{hostname}-{pid}'.
+	Owner string
+	// RetryInterval is the time Acquire waits between attempts while the lock is held. Defaults to
+	// DefaultLockRetryInterval.
+	RetryInterval time.Duration
+	// CleanupAfter is the time after their expiration the lock documents are removed by the TTL index. Since removing a
+	// lock document resets its fencing counter, it should be much greater than the time a process may take to notice it
+	// lost a lock. Defaults to DefaultLockCleanupAfter.
+	CleanupAfter time.Duration
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// LockManager manages distributed locks (leases) stored in a collection, one document per lock name:
+//
+//    { "_id": "<name>", "owner": "<owner>", "token": "<token>", "fence": <counter>, "acquired_at": <time>, "expires_at": <time> }
+//
+// A lock is acquired atomically with findAndModify when its document does not exist or is expired, relying on the
+// unique _id to reject concurrent acquisitions. Every acquisition generates a new token, which identifies the holder in
+// Refresh and Release, and increments the fencing counter, which can be sent along with the writes performed while
+// holding the lock so the resources protected by it can reject the writes of holders whose lease expired.
+//
+// The expiration is computed with the clock of the processes, so their clocks should be synchronized and the TTL of
+// the locks much greater than the clock skew.
+//
+//   Example:
+//
+//      locks, err := mgo.NewLockManager(db.C(mgo.DefaultLocksCollection))
+//      if err != nil {
+//          return err
+//      }
+//      lock, err := locks.Acquire("billing-job", time.Minute)
+//      if err != nil {
+//          return err
+//      }
+//      defer lock.Release()
+//
+type LockManager struct {
+	col  ICollection
+	opts LockManagerOptions
+}
+
+// Lock is a lock acquired by a LockManager.
+type Lock struct {
+	// Name is the name of the lock.
+	Name string `bson:"_id"`
+	// Owner is the owner of the lock manager that acquired the lock.
+	Owner string `bson:"owner"`
+	// Token identifies this acquisition of the lock.
+	Token string `bson:"token"`
+	// Fence is the fencing counter, incremented every time the lock is acquired.
+	Fence int64 `bson:"fence"`
+	// AcquiredAt is the time the lock was acquired.
+	AcquiredAt time.Time `bson:"acquired_at"`
+	// ExpiresAt is the time the lock expires unless refreshed.
+	ExpiresAt time.Time `bson:"expires_at"`
+
+	manager *LockManager
+}
+
+// NewLockManager creates a new lock manager which stores the locks in the provided collection, ensuring the TTL index
+// that removes the abandoned locks. Returns an error if the index cannot be created.
+//
+//   {col}   - The locks collection
+//   {opts}  - (Optional) The owner and timings of the locks
+//
+func NewLockManager(col ICollection, opts ...LockManagerOptions) (*LockManager, error) {
+	cfg := LockManagerOptions{}
+	if len(opts) > 0 {
+		cfg = opts[0]
+	}
+	if cfg.Owner == "" {
+		host, _ := os.Hostname()
+		cfg.Owner = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	if cfg.RetryInterval <= 0 {
+		cfg.RetryInterval = DefaultLockRetryInterval
+	}
+	if cfg.CleanupAfter <= 0 {
+		cfg.CleanupAfter = DefaultLockCleanupAfter
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+
+	err := col.EnsureIndex(Index{
+		Key:         []string{"expires_at"},
+		Background:  true,
+		ExpireAfter: cfg.CleanupAfter,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create the index of the locks collection: %v", err)
+	}
+	return &LockManager{col: col, opts: cfg}, nil
+}
+
+// TryAcquire acquires the lock with the given name for the provided time, or returns ErrLockHeld if it is held by
+// another owner.
+func (m *LockManager) TryAcquire(name string, ttl time.Duration) (*Lock, error) {
+	token, err := NewToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := m.opts.Now()
+	change := Change{
+		Update: bson.M{
+			"$set": bson.M{
+				"owner":       m.opts.Owner,
+				"token":       token,
+				"acquired_at": now,
+				"expires_at":  now.Add(ttl),
+			},
+			"$inc": bson.M{"fence": 1},
+		},
+		Upsert:    true,
+		ReturnNew: true,
+	}
+
+	lock := &Lock{}
+	_, err = m.col.Find(bson.M{"_id": name, "expires_at": bson.M{"$lte": now}}).Apply(change, lock)
+	if IsDup(err) {
+		// The document exists and is not expired, so the upsert tried to insert a second document with the same name.
+		return nil, ErrLockHeld
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to acquire the lock '%s': %v", name, err)
+	}
+	lock.manager = m
+	return lock, nil
+}
+
+// Acquire acquires the lock with the given name for the provided time, waiting until it is available.
+func (m *LockManager) Acquire(name string, ttl time.Duration) (*Lock, error) {
+	return m.AcquireContext(context.Background(), name, ttl)
+}
+
+// AcquireContext works like Acquire, giving up when the context is done.
+func (m *LockManager) AcquireContext(ctx context.Context, name string, ttl time.Duration) (*Lock, error) {
+	for {
+		lock, err := m.TryAcquire(name, ttl)
+		if err != ErrLockHeld {
+			return lock, err
+		}
+
+		timer := time.NewTimer(m.opts.RetryInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("unable to acquire the lock '%s': %w", name, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// Refresh extends the expiration of the lock to the provided time from now. Returns ErrLockLost if the lock is no
+// longer held by this acquisition.
+func (l *Lock) Refresh(ttl time.Duration) error {
+	expiresAt := l.manager.opts.Now().Add(ttl)
+	err := l.manager.col.Update(
+		bson.M{"_id": l.Name, "token": l.Token},
+		bson.M{"$set": bson.M{"expires_at": expiresAt}},
+	)
+	if err == ErrNotFound {
+		return ErrLockLost
+	}
+	if err != nil {
+		return err
+	}
+	l.ExpiresAt = expiresAt
+	return nil
+}
+
+// Release releases the lock so it can be acquired immediately. The lock document is kept to preserve its fencing
+// counter. Returns ErrLockLost if the lock is no longer held by this acquisition.
+func (l *Lock) Release() error {
+	now := l.manager.opts.Now()
+	err := l.manager.col.Update(
+		bson.M{"_id": l.Name, "token": l.Token},
+		bson.M{"$set": bson.M{"expires_at": now}, "$unset": bson.M{"token": ""}},
+	)
+	if err == ErrNotFound {
+		return ErrLockLost
+	}
+	if err != nil {
+		return err
+	}
+	l.ExpiresAt = now
+	return nil
+}
@@ -0,0 +1,152 @@
+package mgo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jucardi/go-mongodb-lib/testutils"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var errDupLock = &mgo.LastError{Code: 11000, Err: "E11000 duplicate key error"}
+
+// locksMock is a locks collection which findAndModify responds with the provided errors in order, and which updates
+// respond with result. It records the Find, Apply and Update calls received.
+type locksMock struct {
+	*CollectionMock
+	testutils.Recorder
+	result error
+}
+
+func mockLocks(errs ...error) *locksMock {
+	m := &locksMock{CollectionMock: MockCollection()}
+	m.WhenReturn("EnsureIndex", nil)
+	m.When("Find", func(args ...interface{}) []interface{} {
+		m.Record("Find", args...)
+		q := MockQuery()
+		q.When("Apply", func(args ...interface{}) []interface{} {
+			m.Record("Apply", args...)
+			// The last error is repeated once the others are used.
+			err := errs[0]
+			if len(errs) > 1 {
+				errs = errs[1:]
+			}
+			if err != nil {
+				return []interface{}{nil, err}
+			}
+			set := args[0].(Change).Update.(bson.M)["$set"].(bson.M)
+			lock := args[1].(*Lock)
+			lock.Name, lock.Fence = "job", int64(len(m.ArgsOf("Apply")))
+			lock.Owner, lock.Token = set["owner"].(string), set["token"].(string)
+			lock.AcquiredAt, lock.ExpiresAt = set["acquired_at"].(time.Time), set["expires_at"].(time.Time)
+			return []interface{}{&ChangeInfo{Updated: 1}, nil}
+		})
+		return []interface{}{q}
+	})
+	m.When("Update", func(args ...interface{}) []interface{} {
+		m.Record("Update", args...)
+		return []interface{}{m.result}
+	})
+	return m
+}
+
+func TestNewLockManager(t *testing.T) {
+	col := MockCollection()
+	var index Index
+	col.When("EnsureIndex", func(args ...interface{}) []interface{} {
+		index = args[0].(Index)
+		return []interface{}{nil}
+	})
+	_, err := NewLockManager(col)
+	assert.NoError(t, err)
+	assert.Equal(t, Index{Key: []string{"expires_at"}, Background: true, ExpireAfter: DefaultLockCleanupAfter}, index)
+
+	col.WhenReturn("EnsureIndex", errors.New("not authorized"))
+	_, err = NewLockManager(col)
+	assert.EqualError(t, err, "unable to create the index of the locks collection: not authorized")
+}
+
+func TestLockManager_TryAcquire(t *testing.T) {
+	c := testutils.NewClock(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))
+	col := mockLocks(nil, errDupLock, errors.New("connection reset"), nil)
+	locks, err := NewLockManager(col, LockManagerOptions{Owner: "a", Now: c.Now})
+	assert.NoError(t, err)
+
+	lock, err := locks.TryAcquire("job", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"_id": "job", "expires_at": bson.M{"$lte": c.Now()}}, col.ArgsOf("Find")[0][0])
+	assert.Len(t, lock.Token, 32)
+	assert.Equal(t, Change{
+		Update: bson.M{
+			"$set": bson.M{
+				"owner":       "a",
+				"token":       lock.Token,
+				"acquired_at": c.Now(),
+				"expires_at":  c.Now().Add(time.Minute),
+			},
+			"$inc": bson.M{"fence": 1},
+		},
+		Upsert:    true,
+		ReturnNew: true,
+	}, col.ArgsOf("Apply")[0][0])
+	assert.Equal(t, "a", lock.Owner)
+	assert.Equal(t, int64(1), lock.Fence)
+
+	// The upsert of a lock that is not expired fails with a duplicate key error.
+	_, err = locks.TryAcquire("job", time.Minute)
+	assert.Equal(t, ErrLockHeld, err)
+
+	_, err = locks.TryAcquire("job", time.Minute)
+	assert.EqualError(t, err, "unable to acquire the lock 'job': connection reset")
+
+	again, err := locks.TryAcquire("job", time.Minute)
+	assert.NoError(t, err)
+	assert.NotEqual(t, lock.Token, again.Token)
+}
+
+func TestLock_RefreshAndRelease(t *testing.T) {
+	c := testutils.NewClock(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))
+	col := mockLocks(nil)
+	locks, _ := NewLockManager(col, LockManagerOptions{Now: c.Now})
+	lock, _ := locks.TryAcquire("job", time.Minute)
+
+	c.Add(30 * time.Second)
+	assert.NoError(t, lock.Refresh(time.Minute))
+	assert.Equal(t, []interface{}{
+		bson.M{"_id": "job", "token": lock.Token},
+		bson.M{"$set": bson.M{"expires_at": c.Now().Add(time.Minute)}},
+	}, col.Last())
+	assert.True(t, c.Now().Add(time.Minute).Equal(lock.ExpiresAt))
+
+	assert.NoError(t, lock.Release())
+	assert.Equal(t, []interface{}{
+		bson.M{"_id": "job", "token": lock.Token},
+		bson.M{"$set": bson.M{"expires_at": c.Now()}, "$unset": bson.M{"token": ""}},
+	}, col.Last())
+
+	// The token no longer matches once the lock expired and was acquired by another owner.
+	col.result = ErrNotFound
+	assert.Equal(t, ErrLockLost, lock.Refresh(time.Minute))
+	assert.Equal(t, ErrLockLost, lock.Release())
+}
+
+func TestLockManager_Acquire(t *testing.T) {
+	col := mockLocks(errDupLock, errDupLock, nil)
+	locks, _ := NewLockManager(col, LockManagerOptions{RetryInterval: time.Millisecond})
+
+	lock, err := locks.Acquire("job", time.Minute)
+	assert.NoError(t, err)
+	assert.NotNil(t, lock)
+	assert.Len(t, col.ArgsOf("Apply"), 3)
+
+	col = mockLocks(errDupLock)
+	locks, _ = NewLockManager(col, LockManagerOptions{RetryInterval: time.Millisecond})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = locks.AcquireContext(ctx, "job", time.Minute)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
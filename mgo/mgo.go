@@ -4,7 +4,6 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"errors"
-	"fmt"
 	"math/rand"
 	"net"
 	"time"
@@ -20,6 +19,10 @@ var (
 	// ErrCursor is the error returned when the cursor used in a mongo operation is not valid.
 	ErrCursor = mgo.ErrCursor
 
+	// ErrConcurrentModification is the error returned by the versioned update operations when the document exists but
+	// its version does not match the expected one, which means it was modified by someone else.
+	ErrConcurrentModification = errors.New("the document was modified concurrently")
+
 	rnd = rand.New(rand.NewSource(time.Now().UnixNano()))
 )
 
@@ -40,31 +43,7 @@ type ExtraConfig struct {
 //     - See mgo.Dial documentation in `gopkg.in/mgo.v2` for more information.
 //
 func Dial(url ...string) (ISession, error) {
-	if len(url) == 0 {
-		return nil, errors.New("expected a 'url'")
-	}
-
-	var (
-		s   *mgo.Session
-		err error
-	)
-
-	for _, u := range url {
-		s, err = mgo.Dial(u)
-
-		for j := 1; err != nil && j <= DialMaxRetries; j++ {
-			log.Get().Error(fmt.Sprintf("Can't connect to mongo on '%s': %v. Retrying in %v", url, err, DialRetrySleep))
-			time.Sleep(DialRetrySleep)
-			log.Get().Warn(fmt.Sprintf("Retrying to connect to mongo, attempt %d of %d", j, DialMaxRetries))
-			s, err = mgo.Dial(u)
-		}
-
-		if err == nil {
-			break
-		}
-	}
-
-	return fromSession(s), err
+	return DefaultDialOptions().Dial(url...)
 }
 
 // DialWithTimeout works like Dial, but uses timeout as the amount of time to
@@ -74,7 +53,12 @@ func Dial(url ...string) (ISession, error) {
 //
 // See SetSyncTimeout for customizing the timeout for the session.
 func DialWithTimeout(url string, timeout time.Duration) (ISession, error) {
-	s, err := mgo.DialWithTimeout(url, timeout)
+	info, err := ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	info.Timeout = timeout
+	s, err := mgo.DialWithInfo(info)
 	return fromSession(s), err
 }
 
@@ -83,46 +67,7 @@ func DialWithTimeout(url string, timeout time.Duration) (ISession, error) {
 //   {extraCfg} - (Optional) additional dialing options.
 //
 func DialWithInfo(info *mgo.DialInfo, extraCfg ...ExtraConfig) (ISession, error) {
-	cfg := ExtraConfig{}
-
-	if len(extraCfg) > 0 {
-		cfg = extraCfg[0]
-	}
-
-	var (
-		s   *mgo.Session
-		err error
-	)
-
-	if cfg.ShuffleHosts && len(info.Addrs) > 1 {
-		for i := len(info.Addrs) - 1; i > 0; i-- { // Fisher–Yates shuffle
-			j := rand.Intn(i + 1)
-			info.Addrs[i], info.Addrs[j] = info.Addrs[j], info.Addrs[i]
-		}
-	}
-
-	addrs := info.Addrs
-
-	for _, u := range addrs {
-		if cfg.IndependentHosts {
-			info.Addrs = []string{u}
-		}
-
-		s, err = mgo.DialWithInfo(info)
-
-		for i := 1; err != nil && i <= DialMaxRetries; i++ {
-			log.Get().Error(fmt.Sprintf("Can't connect to mongo on '%v': %v. Retrying in %v", info.Addrs, err, DialRetrySleep))
-			time.Sleep(DialRetrySleep)
-			log.Get().Warn(fmt.Sprintf("Retrying to connect to mongo, attempt %d of %d", i, DialMaxRetries))
-			s, err = mgo.DialWithInfo(info)
-		}
-
-		if err == nil || !cfg.IndependentHosts {
-			break
-		}
-	}
-
-	return fromSession(s), err
+	return DefaultDialOptions().DialWithInfo(info, extraCfg...)
 }
 
 // DialWithTls attempts to establish a MongoDB connection using TLS with the provided PEM encoded
@@ -137,48 +82,22 @@ func DialWithInfo(info *mgo.DialInfo, extraCfg ...ExtraConfig) (ISession, error)
 //                            for testing.
 //
 func DialWithTls(url string, cert []byte, insecureSkipVerify ...bool) (ISession, error) {
-	rootCerts := x509.NewCertPool()
-	rootCerts.AppendCertsFromPEM(cert)
-
-	var lastErr error
-
-	info, err := mgo.ParseURL(url)
-	if err != nil {
-		return nil, err
-	}
-	info.Timeout = DialTimeout
-	info.DialServer = func(addr *mgo.ServerAddr) (net.Conn, error) {
-		tlsCfg := &tls.Config{
-			RootCAs: rootCerts,
-		}
-		if len(insecureSkipVerify) > 0 && insecureSkipVerify[0] {
-			tlsCfg.InsecureSkipVerify = insecureSkipVerify[0]
-		}
-		conn, err := tls.Dial("tcp", addr.String(), tlsCfg)
-		if err != nil {
-			lastErr = err
-		}
-		return conn, err
-	}
-
-	// Dial with TLS
-	s, err := mgo.DialWithInfo(info)
-
-	for i := 1; err != nil && i <= DialMaxRetries; i++ {
-		if lastErr != nil {
-			err = lastErr
-			lastErr = nil
-		}
-		log.Get().Error(fmt.Sprintf("Can't connect to mongo on '%s': %v. Retrying in %v", url, err, DialRetrySleep))
-		time.Sleep(DialRetrySleep)
-		log.Get().Warn(fmt.Sprintf("Retrying to connect to mongo, attempt %d of %d", i, DialMaxRetries))
-		s, err = mgo.DialWithInfo(info)
-	}
+	return DefaultDialOptions().DialWithTls(url, cert, insecureSkipVerify...)
+}
 
-	return fromSession(s), err
+// DialWithTlsConfig attempts to establish a MongoDB connection using TLS with the provided
+// configuration, which supports mutual TLS, certificate reloading, server name override,
+// minimum version and cipher suites. The TLS options contained in the URL are ignored.
+//
+//    {url}  - The URL to dial to Mongo
+//    {cfg}  - The TLS configuration
+//
+func DialWithTlsConfig(url string, cfg TlsConfig) (ISession, error) {
+	return DefaultDialOptions().DialWithTlsConfig(url, cfg)
 }
 
-// AddTlsHandler adds the TLS handling logic to a provided `*mgo.DialInfo`
+// AddTlsHandler adds the TLS handling logic to a provided `*mgo.DialInfo`. See TlsConfig.Apply
+// for mutual TLS and the rest of the TLS options.
 //
 //    {dialInfo}            - The DialInfo to use
 //    {cert}                - The PEM bytes of the CA cert to use for TLS
@@ -207,14 +126,44 @@ func AddTlsHandler(dialInfo *mgo.DialInfo, cert []byte, insecureSkipVerify bool)
 // ParseURL parses a MongoDB URL as accepted by the Dial function and returns
 // a value suitable for providing into DialWithInfo.
 //
+// Besides the options supported by the driver, the 'tls' (or 'ssl'), 'tlsCAFile',
+// 'tlsCertificateKeyFile', 'tlsInsecure', 'tlsAllowInvalidCertificates' and
+// 'tlsAllowInvalidHostnames' options are supported. When TLS is enabled, the
+// DialServer function of the returned DialInfo is set, see TlsConfig.
+//
+// The 'mongodb+srv://' URLs are resolved with DefaultResolver, see ResolveURL.
+//
 // See Dial for more details on the format of url.
 func ParseURL(url string) (*mgo.DialInfo, error) {
-	return mgo.ParseURL(url)
+	url, err := ResolveURL(url)
+	if err != nil {
+		return nil, err
+	}
+	url, tlsCfg, err := parseTlsURL(url)
+	if err != nil {
+		return nil, err
+	}
+	info, err := mgo.ParseURL(url)
+	if err != nil || tlsCfg == nil {
+		return info, err
+	}
+	return info, tlsCfg.Apply(info)
 }
 
 // IsDup returns whether err informs of a duplicate key error because
 // a primary key index or a secondary unique index already has an entry
-// with the given value.
+// with the given value. Supports the errors returned by Classify.
 func IsDup(err error) bool {
+	switch e := err.(type) {
+	case *Error:
+		return e.Category == ErrDuplicateKey
+	case *BulkError:
+		for _, c := range e.Cases {
+			if c.Err.Category != ErrDuplicateKey {
+				return false
+			}
+		}
+		return len(e.Cases) > 0
+	}
 	return mgo.IsDup(err)
 }
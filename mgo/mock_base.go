@@ -62,6 +62,12 @@ func (m *MockBase) exec(name string, args ...interface{}) []interface{} {
 	return nil
 }
 
+// mocked indicates whether the behavior of the function was set with When or WhenReturn.
+func (m *MockBase) mocked(name string) bool {
+	_, ok := m.when[name]
+	return ok
+}
+
 func (m *MockBase) returnSingle(name string, args ...interface{}) interface{} {
 	ret := m.exec(name, args...)
 	if len(ret) > 0 && ret[0] != nil {
@@ -105,6 +111,14 @@ func (m *MockBase) returnQuery(name string, args ...interface{}) IQuery {
 	return nil
 }
 
+func (m *MockBase) returnChangeInfo(name string, args ...interface{}) (*ChangeInfo, error) {
+	ret, err := m.returnSingleWithError(name, args...)
+	if ret != nil {
+		return ret.(*ChangeInfo), err
+	}
+	return nil, err
+}
+
 func (m *MockBase) returnDB(name string, args ...interface{}) IDatabase {
 	if val, ok := m.returnSingle(name, args...).(IDatabase); ok {
 		return val
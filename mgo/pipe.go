@@ -1,6 +1,9 @@
 package mgo
 
-import "gopkg.in/mgo.v2"
+import (
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
 
 // NewPipe creates an instance of IPipe with the given *mgo.Pipe if passed as an arg.
 // Note: The IPipe instance returned will not work without a valid *mgo.Pipe.
@@ -32,6 +35,10 @@ type IPipe interface {
 	// per-session basis as well, using the Batch method of Session.
 	//     - See the Tail documentation in `gopkg.in/mgo.v2` for more information.
 	Batch(n int) IPipe
+	// ReadPreference runs the pipeline in a session with the given consistency mode, restricted to the servers with the
+	// given tags (see SetMode and SelectServers in ISession). Only supported by the pipelines of a SessionManager, the
+	// pipeline fails with ErrReadPreferenceUnsupported otherwise since its session cannot be replaced.
+	ReadPreference(mode mgo.Mode, tags ...bson.D) IPipe
 	// P returns the internal mgo.pipe used by this implementation.
 	P() *mgo.Pipe
 }
@@ -45,7 +52,7 @@ func (p *pipe) P() *mgo.Pipe {
 }
 
 func (p *pipe) Iter() IIter {
-	return p.Iter()
+	return p.P().Iter()
 }
 
 func (p *pipe) AllowDiskUse() IPipe {
@@ -56,6 +63,10 @@ func (p *pipe) Batch(n int) IPipe {
 	return p.update(p.P().Batch(n))
 }
 
+func (p *pipe) ReadPreference(mgo.Mode, ...bson.D) IPipe {
+	return &errPipe{err: ErrReadPreferenceUnsupported}
+}
+
 func (p *pipe) update(pipe *mgo.Pipe) IPipe {
 	p.Pipe = pipe
 	return p
@@ -67,3 +78,19 @@ func fromPipe(p *mgo.Pipe) IPipe {
 	}
 	return &pipe{Pipe: p}
 }
+
+// errPipe is an IPipe that fails with the provided error when executed, used by the decorators that reject a pipeline
+// since IPipe cannot report errors until it runs.
+type errPipe struct {
+	err error
+}
+
+func (p *errPipe) P() *mgo.Pipe              { return nil }
+func (p *errPipe) Iter() IIter               { return &errIter{err: p.err} }
+func (p *errPipe) All(interface{}) error     { return p.err }
+func (p *errPipe) One(interface{}) error     { return p.err }
+func (p *errPipe) Explain(interface{}) error { return p.err }
+func (p *errPipe) AllowDiskUse() IPipe       { return p }
+func (p *errPipe) Batch(int) IPipe           { return p }
+
+func (p *errPipe) ReadPreference(mgo.Mode, ...bson.D) IPipe { return p }
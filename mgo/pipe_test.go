@@ -0,0 +1,103 @@
+package mgo
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// fakeServer is a single node which answers the commands of the wire protocol with the provided handler.
+func fakeServer(t *testing.T, handle func(cmd bson.M) bson.M) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveConn(conn, handle)
+		}
+	}()
+	return l.Addr().String()
+}
+
+func serveConn(conn net.Conn, handle func(cmd bson.M) bson.M) {
+	defer conn.Close()
+	for {
+		header := make([]byte, 16)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		body := make([]byte, binary.LittleEndian.Uint32(header)-16)
+		if _, err := io.ReadFull(conn, body); err != nil {
+			return
+		}
+
+		// OP_QUERY: flags, collection name, skip, limit and the query document.
+		i := 4
+		for body[i] != 0 {
+			i++
+		}
+		i += 9
+		var cmd bson.M
+		if err := bson.Unmarshal(body[i:i+int(binary.LittleEndian.Uint32(body[i:]))], &cmd); err != nil {
+			return
+		}
+
+		var reply bson.M
+		if _, ok := cmd["ismaster"]; ok {
+			reply = bson.M{"ok": 1, "ismaster": true, "maxWireVersion": 2}
+		} else if _, ok := cmd["getnonce"]; ok {
+			reply = bson.M{"ok": 1, "nonce": "2375531c32080ae8"}
+		} else {
+			reply = handle(cmd)
+		}
+		doc, _ := bson.Marshal(reply)
+
+		// OP_REPLY: flags, cursor id, starting from, number returned and the documents.
+		out := make([]byte, 36, 36+len(doc))
+		binary.LittleEndian.PutUint32(out[0:], uint32(36+len(doc)))
+		copy(out[8:12], header[4:8])
+		binary.LittleEndian.PutUint32(out[12:], 1)
+		binary.LittleEndian.PutUint32(out[32:], 1)
+		if _, err := conn.Write(append(out, doc...)); err != nil {
+			return
+		}
+	}
+}
+
+func TestPipe_Iter(t *testing.T) {
+	var aggregated bson.M
+	addr := fakeServer(t, func(cmd bson.M) bson.M {
+		if _, ok := cmd["aggregate"]; ok {
+			aggregated = cmd
+			return bson.M{"ok": 1, "result": []bson.M{{"n": 1}, {"n": 2}}}
+		}
+		return bson.M{"ok": 1}
+	})
+
+	s, err := mgo.DialWithTimeout(addr, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	pipeline := []bson.M{{"$match": bson.M{"n": bson.M{"$gt": 0}}}}
+	iter := NewPipe(s.DB("test").C("items").Pipe(pipeline)).Iter()
+
+	var docs []bson.M
+	assert.NoError(t, iter.All(&docs))
+	assert.Equal(t, []bson.M{{"n": 1}, {"n": 2}}, docs)
+	assert.Equal(t, "items", aggregated["aggregate"])
+}
@@ -3,7 +3,9 @@ package mgo
 import (
 	"time"
 
+	"github.com/jucardi/go-mongodb-lib/pages"
 	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
 )
 
 // NewQuery creates an instance of IQuery with the given *mgo.Query if passed as an arg.
@@ -99,6 +101,10 @@ type IQuery interface {
 	//     - See the Apply documentation in `gopkg.in/mgo.v2` for more information.
 	Apply(change Change, result interface{}) (info *ChangeInfo, err error)
 
+	// ReadPreference runs the query in a session with the given consistency mode, restricted to the servers with the given tags (see SetMode and SelectServers in ISession).
+	// Only supported by the queries of a SessionManager, the query fails with ErrReadPreferenceUnsupported otherwise since its session cannot be replaced.
+	ReadPreference(mode mgo.Mode, tags ...bson.D) IQuery
+
 	// Returns the internal mgo.query used by this implementation.
 	Q() *mgo.Query
 }
@@ -183,6 +189,10 @@ func (q *query) Apply(change Change, result interface{}) (*ChangeInfo, error) {
 	return makeChangeInfo(info), err
 }
 
+func (q *query) ReadPreference(mgo.Mode, ...bson.D) IQuery {
+	return &errQuery{err: ErrReadPreferenceUnsupported}
+}
+
 // update: Updates the inner *mgo.query contained by this instance.
 func (q *query) update(query *mgo.Query) IQuery {
 	q.Query = query
@@ -192,3 +202,36 @@ func (q *query) update(query *mgo.Query) IQuery {
 func fromQuery(q *mgo.Query) IQuery {
 	return &query{Query: q}
 }
+
+// errQuery is an IQuery that fails with the provided error when executed, the counterpart of errPipe for queries.
+type errQuery struct {
+	err error
+}
+
+func (q *errQuery) Q() *mgo.Query                                             { return nil }
+func (q *errQuery) Batch(int) IQuery                                          { return q }
+func (q *errQuery) Prefetch(float64) IQuery                                   { return q }
+func (q *errQuery) Skip(int) IQuery                                           { return q }
+func (q *errQuery) Limit(int) IQuery                                          { return q }
+func (q *errQuery) Select(interface{}) IQuery                                 { return q }
+func (q *errQuery) Sort(...string) IQuery                                     { return q }
+func (q *errQuery) Hint(...string) IQuery                                     { return q }
+func (q *errQuery) SetMaxScan(int) IQuery                                     { return q }
+func (q *errQuery) SetMaxTime(time.Duration) IQuery                           { return q }
+func (q *errQuery) Snapshot() IQuery                                          { return q }
+func (q *errQuery) Comment(string) IQuery                                     { return q }
+func (q *errQuery) LogReplay() IQuery                                         { return q }
+func (q *errQuery) ReadPreference(mgo.Mode, ...bson.D) IQuery                 { return q }
+func (q *errQuery) Page(...*pages.Page) IQuery                                { return q }
+func (q *errQuery) Explain(interface{}) error                                 { return q.err }
+func (q *errQuery) One(interface{}) error                                     { return q.err }
+func (q *errQuery) All(interface{}) error                                     { return q.err }
+func (q *errQuery) Distinct(string, interface{}) error                        { return q.err }
+func (q *errQuery) Count() (int, error)                                       { return 0, q.err }
+func (q *errQuery) Iter() IIter                                               { return &errIter{err: q.err} }
+func (q *errQuery) Tail(time.Duration) IIter                                  { return &errIter{err: q.err} }
+func (q *errQuery) MapReduce(*MapReduce, interface{}) (*MapReduceInfo, error) { return nil, q.err }
+func (q *errQuery) Apply(Change, interface{}) (*ChangeInfo, error)            { return nil, q.err }
+func (q *errQuery) WrapPage(interface{}, ...*pages.Page) (*pages.Paginated, error) {
+	return nil, q.err
+}
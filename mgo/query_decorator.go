@@ -0,0 +1,89 @@
+package mgo
+
+import (
+	"time"
+
+	"github.com/jucardi/go-mongodb-lib/pages"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// queryDecorator is the base for IQuery decorators. It makes the functions used to build a query return the decorator
+// instead of the wrapped IQuery, so the decorated behavior is preserved when the functions are chained.
+type queryDecorator struct {
+	IQuery
+	self IQuery
+}
+
+func (q *queryDecorator) Batch(n int) IQuery {
+	q.IQuery = q.IQuery.Batch(n)
+	return q.self
+}
+
+func (q *queryDecorator) Prefetch(p float64) IQuery {
+	q.IQuery = q.IQuery.Prefetch(p)
+	return q.self
+}
+
+func (q *queryDecorator) Skip(n int) IQuery {
+	q.IQuery = q.IQuery.Skip(n)
+	return q.self
+}
+
+func (q *queryDecorator) Limit(n int) IQuery {
+	q.IQuery = q.IQuery.Limit(n)
+	return q.self
+}
+
+func (q *queryDecorator) Select(selector interface{}) IQuery {
+	q.IQuery = q.IQuery.Select(selector)
+	return q.self
+}
+
+func (q *queryDecorator) Sort(fields ...string) IQuery {
+	q.IQuery = q.IQuery.Sort(fields...)
+	return q.self
+}
+
+func (q *queryDecorator) Hint(indexKey ...string) IQuery {
+	q.IQuery = q.IQuery.Hint(indexKey...)
+	return q.self
+}
+
+func (q *queryDecorator) SetMaxScan(n int) IQuery {
+	q.IQuery = q.IQuery.SetMaxScan(n)
+	return q.self
+}
+
+func (q *queryDecorator) SetMaxTime(d time.Duration) IQuery {
+	q.IQuery = q.IQuery.SetMaxTime(d)
+	return q.self
+}
+
+func (q *queryDecorator) Snapshot() IQuery {
+	q.IQuery = q.IQuery.Snapshot()
+	return q.self
+}
+
+func (q *queryDecorator) Comment(comment string) IQuery {
+	q.IQuery = q.IQuery.Comment(comment)
+	return q.self
+}
+
+func (q *queryDecorator) LogReplay() IQuery {
+	q.IQuery = q.IQuery.LogReplay()
+	return q.self
+}
+
+func (q *queryDecorator) ReadPreference(mode mgo.Mode, tags ...bson.D) IQuery {
+	q.IQuery = q.IQuery.ReadPreference(mode, tags...)
+	return q.self
+}
+
+func (q *queryDecorator) Page(page ...*pages.Page) IQuery {
+	return pageHandler(q.self, page...)
+}
+
+func (q *queryDecorator) WrapPage(result interface{}, page ...*pages.Page) (*pages.Paginated, error) {
+	return wrapPageHandler(q.self, result, page...)
+}
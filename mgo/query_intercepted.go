@@ -0,0 +1,265 @@
+package mgo
+
+import (
+	"reflect"
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// interceptedQuery is the IQuery decorator returned by the intercepted collections. The filter, sort, projection, skip
+// and limit of the query are reported in the operations for information purposes only, changing them in an
+// interceptor does not modify the query.
+type interceptedQuery struct {
+	*queryDecorator
+	chain interceptorChain
+	base  Operation
+}
+
+func newInterceptedQuery(q IQuery, chain interceptorChain, base Operation) IQuery {
+	ret := &interceptedQuery{queryDecorator: &queryDecorator{IQuery: q}, chain: chain, base: base}
+	ret.self = ret
+	return ret
+}
+
+func (q *interceptedQuery) Sort(fields ...string) IQuery {
+	q.base.Sort = fields
+	return q.queryDecorator.Sort(fields...)
+}
+
+func (q *interceptedQuery) Select(selector interface{}) IQuery {
+	q.base.Projection = selector
+	return q.queryDecorator.Select(selector)
+}
+
+func (q *interceptedQuery) Skip(n int) IQuery {
+	q.base.Skip = n
+	return q.queryDecorator.Skip(n)
+}
+
+func (q *interceptedQuery) Limit(n int) IQuery {
+	if n > 0 {
+		q.base.Limit = n
+	}
+	return q.queryDecorator.Limit(n)
+}
+
+func (q *interceptedQuery) One(result interface{}) error {
+	return q.chain.run(q.op("Query.One", OperationRead), func(op *Operation) error {
+		return returnedOne(op, q.IQuery.One(result))
+	})
+}
+
+func (q *interceptedQuery) All(result interface{}) error {
+	return q.chain.run(q.op("Query.All", OperationRead), func(op *Operation) error {
+		return returnedAll(op, result, q.IQuery.All(result))
+	})
+}
+
+func (q *interceptedQuery) Count() (n int, err error) {
+	err = q.chain.run(q.op("Query.Count", OperationRead), func(*Operation) error {
+		n, err = q.IQuery.Count()
+		return err
+	})
+	return
+}
+
+func (q *interceptedQuery) Distinct(key string, result interface{}) error {
+	return q.chain.run(q.op("Query.Distinct", OperationRead), func(*Operation) error {
+		return q.IQuery.Distinct(key, result)
+	})
+}
+
+func (q *interceptedQuery) Explain(result interface{}) error {
+	return q.chain.run(q.op("Query.Explain", OperationRead), func(*Operation) error {
+		return q.IQuery.Explain(result)
+	})
+}
+
+// Iter reports the creation of the cursor only, see Operation.Cursor.
+func (q *interceptedQuery) Iter() (iter IIter) {
+	q.chain.run(q.cursorOp("Query.Iter"), func(*Operation) error {
+		iter = q.IQuery.Iter()
+		return iterErr(iter)
+	})
+	return
+}
+
+// Tail reports the creation of the cursor only, see Operation.Cursor.
+func (q *interceptedQuery) Tail(timeout time.Duration) (iter IIter) {
+	q.chain.run(q.cursorOp("Query.Tail"), func(*Operation) error {
+		iter = q.IQuery.Tail(timeout)
+		return iterErr(iter)
+	})
+	return
+}
+
+func (q *interceptedQuery) MapReduce(job *MapReduce, result interface{}) (info *MapReduceInfo, err error) {
+	err = q.chain.run(q.op("Query.MapReduce", OperationRead), func(*Operation) error {
+		info, err = q.IQuery.MapReduce(job, result)
+		return err
+	})
+	return
+}
+
+func (q *interceptedQuery) Apply(change Change, result interface{}) (info *ChangeInfo, err error) {
+	op := q.op("Query.Apply", OperationWrite)
+	op.Update = change.Update
+	err = q.chain.run(op, func(op *Operation) error {
+		change.Update = op.Update
+		info, err = q.IQuery.Apply(change, result)
+		return returnedOne(op, err)
+	})
+	return
+}
+
+func (q *interceptedQuery) op(name string, kind OperationKind) *Operation {
+	op := q.base
+	op.Name = name
+	op.Kind = kind
+	op.Explain = q.IQuery.Explain
+	return &op
+}
+
+func (q *interceptedQuery) cursorOp(name string) *Operation {
+	op := q.op(name, OperationRead)
+	op.Cursor = true
+	return op
+}
+
+// interceptedPipe is the IPipe decorator returned by the intercepted collections. The pipeline is reported in the
+// operations for information purposes only, changing it in an interceptor does not modify the pipe.
+type interceptedPipe struct {
+	IPipe
+	chain interceptorChain
+	base  Operation
+}
+
+// Iter reports the creation of the cursor only, see Operation.Cursor.
+func (p *interceptedPipe) Iter() (iter IIter) {
+	op := p.op("Pipe.Iter")
+	op.Cursor = true
+	p.chain.run(op, func(*Operation) error {
+		iter = p.IPipe.Iter()
+		return iterErr(iter)
+	})
+	return
+}
+
+func (p *interceptedPipe) All(result interface{}) error {
+	return p.chain.run(p.op("Pipe.All"), func(op *Operation) error {
+		return returnedAll(op, result, p.IPipe.All(result))
+	})
+}
+
+func (p *interceptedPipe) One(result interface{}) error {
+	return p.chain.run(p.op("Pipe.One"), func(op *Operation) error {
+		return returnedOne(op, p.IPipe.One(result))
+	})
+}
+
+func (p *interceptedPipe) Explain(result interface{}) error {
+	return p.chain.run(p.op("Pipe.Explain"), func(*Operation) error {
+		return p.IPipe.Explain(result)
+	})
+}
+
+func (p *interceptedPipe) AllowDiskUse() IPipe {
+	p.IPipe = p.IPipe.AllowDiskUse()
+	return p
+}
+
+func (p *interceptedPipe) Batch(n int) IPipe {
+	p.IPipe = p.IPipe.Batch(n)
+	return p
+}
+
+func (p *interceptedPipe) ReadPreference(mode mgo.Mode, tags ...bson.D) IPipe {
+	p.IPipe = p.IPipe.ReadPreference(mode, tags...)
+	return p
+}
+
+func (p *interceptedPipe) op(name string) *Operation {
+	op := p.base
+	op.Name = name
+	op.Kind = OperationRead
+	op.Explain = p.IPipe.Explain
+	return &op
+}
+
+// interceptedBulk is the IBulk decorator returned by the intercepted collections.
+type interceptedBulk struct {
+	IBulk
+	chain interceptorChain
+	base  Operation
+}
+
+func (b *interceptedBulk) Unordered() IBulk {
+	b.IBulk = b.IBulk.Unordered()
+	return b
+}
+
+func (b *interceptedBulk) Insert(docs ...interface{}) IBulk {
+	return b.queue(b.IBulk.Insert(docs...), len(docs))
+}
+
+func (b *interceptedBulk) Remove(selectors ...interface{}) IBulk {
+	return b.queue(b.IBulk.Remove(selectors...), len(selectors))
+}
+
+func (b *interceptedBulk) RemoveAll(selectors ...interface{}) IBulk {
+	return b.queue(b.IBulk.RemoveAll(selectors...), len(selectors))
+}
+
+func (b *interceptedBulk) Update(pairs ...interface{}) IBulk {
+	return b.queue(b.IBulk.Update(pairs...), len(pairs)/2)
+}
+
+func (b *interceptedBulk) UpdateAll(pairs ...interface{}) IBulk {
+	return b.queue(b.IBulk.UpdateAll(pairs...), len(pairs)/2)
+}
+
+func (b *interceptedBulk) Upsert(pairs ...interface{}) IBulk {
+	return b.queue(b.IBulk.Upsert(pairs...), len(pairs)/2)
+}
+
+func (b *interceptedBulk) Run() (result *BulkResult, err error) {
+	op := b.base
+	op.Name = "Bulk.Run"
+	op.Kind = OperationWrite
+	err = b.chain.run(&op, func(*Operation) error {
+		result, err = b.IBulk.Run()
+		return err
+	})
+	return
+}
+
+func (b *interceptedBulk) queue(bulk IBulk, n int) IBulk {
+	b.IBulk = bulk
+	b.base.BulkSize += n
+	return b
+}
+
+func iterErr(iter IIter) error {
+	if iter == nil {
+		return nil
+	}
+	return iter.Err()
+}
+
+// returnedOne sets the amount of returned documents of an operation that returns a single document.
+func returnedOne(op *Operation, err error) error {
+	if err == nil {
+		op.Returned = 1
+	}
+	return err
+}
+
+// returnedAll sets the amount of returned documents of an operation that unmarshals the results into a slice.
+func returnedAll(op *Operation, result interface{}, err error) error {
+	if v := reflect.ValueOf(result); err == nil && v.Kind() == reflect.Ptr && v.Elem().Kind() == reflect.Slice {
+		op.Returned = v.Elem().Len()
+	}
+	return err
+}
@@ -2,12 +2,14 @@ package mgo
 
 import (
 	"github.com/jucardi/go-mongodb-lib/pages"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
 )
 
 // TODO: Handle conditionals on arguments to support more use cases when testing. Add all IQuery methods.
 
 // All functions that return IQuery to easily initialize
-var queryFuncs = []string{"Batch", "Prefetch", "Skip", "Limit", "Select", "Sort", "Count", "Explain", "Hint", "SetMaxScan", "SetMaxTime", "Snapshot", "Comment", "LogReplay", "Page"}
+var queryFuncs = []string{"Batch", "Prefetch", "Skip", "Limit", "Select", "Sort", "Count", "Explain", "Hint", "SetMaxScan", "SetMaxTime", "Snapshot", "Comment", "LogReplay", "ReadPreference", "Page"}
 
 // QueryMock is a mock implementation of IQuery
 type QueryMock struct {
@@ -54,6 +56,18 @@ func (m *QueryMock) Limit(n int) IQuery {
 	return m.returnQuery("Limit", n)
 }
 
+func (m *QueryMock) Select(selector interface{}) IQuery {
+	return m.returnQuery("Select", selector)
+}
+
+func (m *QueryMock) ReadPreference(mode mgo.Mode, tags ...bson.D) IQuery {
+	args := []interface{}{mode}
+	for _, v := range tags {
+		args = append(args, v)
+	}
+	return m.returnQuery("ReadPreference", args...)
+}
+
 func (m *QueryMock) Page(page ...*pages.Page) IQuery {
 	p := make([]interface{}, len(page))
 	for i, v := range page {
@@ -89,3 +103,21 @@ func (m *QueryMock) Count() (int, error) {
 func (m *QueryMock) All(result interface{}) error {
 	return m.returnError("All", result)
 }
+
+func (m *QueryMock) One(result interface{}) error {
+	return m.returnError("One", result)
+}
+
+func (m *QueryMock) Explain(result interface{}) error {
+	return m.returnError("Explain", result)
+}
+
+func (m *QueryMock) Apply(change Change, result interface{}) (*ChangeInfo, error) {
+	ret, err := m.returnSingleWithError("Apply", change, result)
+
+	if ret != nil {
+		return ret.(*ChangeInfo), err
+	}
+
+	return nil, err
+}
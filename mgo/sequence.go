@@ -0,0 +1,229 @@
+package mgo
+
+import (
+	"fmt"
+	"sync"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	// DefaultCountersCollection is the default name of the collection that holds the sequence counters.
+	DefaultCountersCollection = "counters"
+
+	// DefaultSequenceField is the default counter document field that holds the last allocated value of a sequence.
+	DefaultSequenceField = "seq"
+)
+
+// SequenceOptions defines how the sequences allocate the values. See NewSequences.
+type SequenceOptions struct {
+	// BlockSize is the amount of values reserved in each round trip to the database, which are then handed out from
+	// memory. A crashed process loses its unused values, so sequences with a block size greater than 1 may have gaps.
+	// Defaults to 1.
+	BlockSize int64
+	// Field is the counter document field that holds the last allocated value. Defaults to DefaultSequenceField.
+	Field string
+}
+
+// Sequences allocates incrementing values from the counters stored in a collection, one counter document per sequence:
+//
+//    { "_id": "<sequence name>", "seq": <last allocated value> }
+//
+// The values are allocated atomically with findAndModify, using '$inc' and returning the new document, so they are
+// unique across processes. The counters are created on first use and the first value of a sequence is 1.
+//
+//   Example:
+//
+//      sequences := mgo.NewSequences(db.C(mgo.DefaultCountersCollection), mgo.SequenceOptions{BlockSize: 20})
+//      id, err := sequences.Next("invoices")
+//
+type Sequences struct {
+	col    ICollection
+	opts   SequenceOptions
+	mux    sync.Mutex
+	blocks map[string]*sequenceBlock
+}
+
+// sequenceBlock holds the values reserved for a sequence, from next to last inclusive.
+type sequenceBlock struct {
+	mux  sync.Mutex
+	next int64
+	last int64
+}
+
+// NewSequences creates a new instance of Sequences which uses the provided collection to store the counters.
+//
+//   {col}   - The counters collection
+//   {opts}  - (Optional) The block size and the field of the counters
+//
+func NewSequences(col ICollection, opts ...SequenceOptions) *Sequences {
+	cfg := SequenceOptions{}
+	if len(opts) > 0 {
+		cfg = opts[0]
+	}
+	if cfg.BlockSize <= 0 {
+		cfg.BlockSize = 1
+	}
+	if cfg.Field == "" {
+		cfg.Field = DefaultSequenceField
+	}
+	return &Sequences{col: col, opts: cfg, blocks: map[string]*sequenceBlock{}}
+}
+
+// Next returns the next value of the sequence with the given name.
+func (s *Sequences) Next(name string) (int64, error) {
+	block := s.block(name)
+	block.mux.Lock()
+	defer block.mux.Unlock()
+
+	if block.next == 0 || block.next > block.last {
+		last, err := s.reserve(name, s.opts.BlockSize)
+		if err != nil {
+			return 0, err
+		}
+		block.next, block.last = last-s.opts.BlockSize+1, last
+	}
+
+	ret := block.next
+	block.next++
+	return ret, nil
+}
+
+// Current returns the last value allocated from the database for the sequence with the given name, including the
+// values reserved but not yet handed out. Returns 0 if the sequence does not exist.
+func (s *Sequences) Current(name string) (int64, error) {
+	doc := bson.M{}
+	err := s.col.FindId(name).One(&doc)
+	if err == ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return toInt64(doc[s.opts.Field])
+}
+
+func (s *Sequences) block(name string) *sequenceBlock {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	block, ok := s.blocks[name]
+	if !ok {
+		block = &sequenceBlock{}
+		s.blocks[name] = block
+	}
+	return block
+}
+
+// reserve increments the counter by n and returns its new value.
+func (s *Sequences) reserve(name string, n int64) (int64, error) {
+	change := Change{
+		Update:    bson.M{"$inc": bson.M{s.opts.Field: n}},
+		Upsert:    true,
+		ReturnNew: true,
+	}
+
+	doc := bson.M{}
+	_, err := s.col.FindId(name).Apply(change, &doc)
+	if IsDup(err) {
+		// Another process created the counter concurrently, the upsert can be retried safely.
+		doc = bson.M{}
+		_, err = s.col.FindId(name).Apply(change, &doc)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("unable to allocate values from sequence '%s': %v", name, err)
+	}
+	return toInt64(doc[s.opts.Field])
+}
+
+func toInt64(v interface{}) (int64, error) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), nil
+	case int32:
+		return int64(n), nil
+	case int64:
+		return n, nil
+	case float64:
+		return int64(n), nil
+	}
+	return 0, fmt.Errorf("the sequence value %v is not a number", v)
+}
+
+// NewSequenceCollection decorates the provided collection so Insert sets the ID of the documents from the sequence
+// named after the collection. Documents that already contain an ID are not modified, and pointers to structs are
+// updated in place. Bulk inserts are not affected.
+//
+//   Example:
+//
+//      sequences := mgo.NewSequences(db.C(mgo.DefaultCountersCollection))
+//      invoices := mgo.NewSequenceCollection(db.C("invoices"), sequences)
+//      invoice := &Invoice{Total: 100}
+//      err := invoices.Insert(invoice) // invoice.Id = 1
+//
+//   {col}       - The collection to decorate
+//   {sequences} - The sequences to allocate the IDs from
+//   {field}     - (Optional) The field of the ID, defaults to "_id"
+//
+func NewSequenceCollection(col ICollection, sequences *Sequences, field ...string) ICollection {
+	f := "_id"
+	if len(field) > 0 && field[0] != "" {
+		f = field[0]
+	}
+	return &sequenceCollection{ICollection: col, sequences: sequences, field: f}
+}
+
+type sequenceCollection struct {
+	ICollection
+	sequences *Sequences
+	field     string
+}
+
+func (c *sequenceCollection) With(s ISession) ICollection {
+	return &sequenceCollection{ICollection: c.ICollection.With(s), sequences: c.sequences, field: c.field}
+}
+
+func (c *sequenceCollection) Insert(docs ...interface{}) error {
+	prepared := make([]interface{}, len(docs))
+	for i, doc := range docs {
+		d, err := c.prepareInsert(doc)
+		if err != nil {
+			return err
+		}
+		prepared[i] = d
+	}
+	return c.ICollection.Insert(prepared...)
+}
+
+func (c *sequenceCollection) prepareInsert(doc interface{}) (interface{}, error) {
+	if f := structField(doc, c.field); f.IsValid() {
+		if !isInt(f) {
+			return nil, fmt.Errorf("the field '%s' of %T must be an integer to be set from a sequence", c.field, doc)
+		}
+		if f.Int() != 0 {
+			return doc, nil
+		}
+		id, err := c.sequences.Next(c.Name())
+		if err != nil {
+			return nil, err
+		}
+		if f.OverflowInt(id) {
+			return nil, fmt.Errorf("the sequence value %d overflows the field '%s' of %T", id, c.field, doc)
+		}
+		f.SetInt(id)
+		return doc, nil
+	}
+
+	m, err := toDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+	if !isEmptyValue(m[c.field]) {
+		return m, nil
+	}
+	id, err := c.sequences.Next(c.Name())
+	if err != nil {
+		return nil, err
+	}
+	m[c.field] = id
+	return m, nil
+}
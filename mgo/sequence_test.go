@@ -0,0 +1,121 @@
+package mgo
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type countersCollection struct {
+	ICollection
+	mux      sync.Mutex
+	counters map[string]int64
+	applies  int
+	dups     int
+}
+
+func (c *countersCollection) FindId(id interface{}) IQuery {
+	name := id.(string)
+	q := MockQuery()
+	q.When("Apply", func(args ...interface{}) []interface{} {
+		c.mux.Lock()
+		defer c.mux.Unlock()
+		if c.dups > 0 {
+			c.dups--
+			return []interface{}{nil, &mgo.LastError{Code: 11000, Err: "E11000 duplicate key error"}}
+		}
+		c.applies++
+		inc := args[0].(Change).Update.(bson.M)["$inc"].(bson.M)["seq"].(int64)
+		c.counters[name] += inc
+		*args[1].(*bson.M) = bson.M{"_id": name, "seq": c.counters[name]}
+		return []interface{}{&ChangeInfo{Updated: 1}, nil}
+	})
+	q.When("One", func(args ...interface{}) []interface{} {
+		c.mux.Lock()
+		defer c.mux.Unlock()
+		v, ok := c.counters[name]
+		if !ok {
+			return []interface{}{ErrNotFound}
+		}
+		*args[0].(*bson.M) = bson.M{"_id": name, "seq": v}
+		return []interface{}{nil}
+	})
+	return q
+}
+
+func TestSequences_Next(t *testing.T) {
+	col := &countersCollection{counters: map[string]int64{}, dups: 1}
+	seq := NewSequences(col)
+
+	for i := int64(1); i <= 3; i++ {
+		n, err := seq.Next("invoices")
+		assert.NoError(t, err)
+		assert.Equal(t, i, n)
+	}
+	n, err := seq.Next("orders")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	assert.Equal(t, 4, col.applies)
+
+	current, err := seq.Current("invoices")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), current)
+	current, err = seq.Current("missing")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), current)
+}
+
+func TestSequences_Blocks(t *testing.T) {
+	col := &countersCollection{counters: map[string]int64{"invoices": 7}}
+	a := NewSequences(col, SequenceOptions{BlockSize: 10})
+	b := NewSequences(col, SequenceOptions{BlockSize: 10})
+
+	var wg sync.WaitGroup
+	var mux sync.Mutex
+	seen := map[int64]bool{}
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(s *Sequences) {
+			defer wg.Done()
+			n, err := s.Next("invoices")
+			assert.NoError(t, err)
+			mux.Lock()
+			defer mux.Unlock()
+			assert.False(t, seen[n], "duplicated value %d", n)
+			seen[n] = true
+		}([]*Sequences{a, b}[i%2])
+	}
+	wg.Wait()
+
+	assert.Len(t, seen, 50)
+	assert.Equal(t, 6, col.applies)
+	for n := range seen {
+		assert.True(t, n >= 8 && n <= 67)
+	}
+}
+
+type sequenceModel struct {
+	Id    int64  `bson:"_id"`
+	Label string `bson:"label"`
+}
+
+func TestSequenceCollection_Insert(t *testing.T) {
+	rec := &recorderCollection{}
+	seq := NewSequences(&countersCollection{counters: map[string]int64{}})
+	col := NewSequenceCollection(rec, seq)
+
+	model := &sequenceModel{Label: "a"}
+	existing := &sequenceModel{Id: 99}
+	assert.NoError(t, col.Insert(model, existing, bson.M{"label": "c"}))
+	assert.Equal(t, int64(1), model.Id)
+	assert.Equal(t, int64(99), existing.Id)
+	assert.Equal(t, bson.M{"_id": int64(2), "label": "c"}, rec.Last()[2])
+
+	err := col.Insert(&struct {
+		Id string `bson:"_id"`
+	}{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,142 @@
+package mgo
+
+import (
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// interceptedSession is the ISession decorator created by InterceptSession
+type interceptedSession struct {
+	ISession
+	chain interceptorChain
+}
+
+func (s *interceptedSession) DB(name string) IDatabase {
+	return &interceptedDatabase{IDatabase: s.ISession.DB(name), chain: s.chain}
+}
+
+func (s *interceptedSession) New() ISession {
+	return &interceptedSession{ISession: s.ISession.New(), chain: s.chain}
+}
+
+func (s *interceptedSession) Copy() ISession {
+	return &interceptedSession{ISession: s.ISession.Copy(), chain: s.chain}
+}
+
+func (s *interceptedSession) Clone() ISession {
+	return &interceptedSession{ISession: s.ISession.Clone(), chain: s.chain}
+}
+
+func (s *interceptedSession) FindRef(ref *mgo.DBRef) IQuery {
+	return newInterceptedQuery(s.ISession.FindRef(ref), s.chain, Operation{
+		Database:   ref.Database,
+		Collection: ref.Collection,
+		Filter:     bson.M{"_id": ref.Id},
+		session:    s.session,
+	})
+}
+
+func (s *interceptedSession) Ping() error {
+	return s.chain.run(s.op("Ping", nil), func(*Operation) error {
+		return s.ISession.Ping()
+	})
+}
+
+func (s *interceptedSession) Run(cmd interface{}, result interface{}) error {
+	return s.chain.run(s.op("Run", cmd), func(op *Operation) error {
+		return s.ISession.Run(op.Command, result)
+	})
+}
+
+func (s *interceptedSession) Fsync(async bool) error {
+	return s.chain.run(s.op("Fsync", nil), func(*Operation) error {
+		return s.ISession.Fsync(async)
+	})
+}
+
+func (s *interceptedSession) DatabaseNames() (names []string, err error) {
+	err = s.chain.run(s.op("DatabaseNames", nil), func(*Operation) error {
+		names, err = s.ISession.DatabaseNames()
+		return err
+	})
+	return
+}
+
+func (s *interceptedSession) BuildInfo() (info mgo.BuildInfo, err error) {
+	err = s.chain.run(s.op("BuildInfo", nil), func(*Operation) error {
+		info, err = s.ISession.BuildInfo()
+		return err
+	})
+	return
+}
+
+func (s *interceptedSession) op(name string, cmd interface{}) *Operation {
+	op := &Operation{Name: name, Kind: OperationCommand, Command: cmd, session: s.session}
+	if cmd != nil {
+		op.Database = "admin"
+	}
+	return op
+}
+
+func (s *interceptedSession) session() ISession {
+	return s.ISession
+}
+
+// interceptedDatabase is the IDatabase decorator created by InterceptDatabase
+type interceptedDatabase struct {
+	IDatabase
+	chain interceptorChain
+}
+
+func (d *interceptedDatabase) C(name string) ICollection {
+	return newInterceptedCollection(d.IDatabase.C(name), d.chain, d.Name(), name)
+}
+
+func (d *interceptedDatabase) With(s ISession) IDatabase {
+	return &interceptedDatabase{IDatabase: d.IDatabase.With(s), chain: d.chain}
+}
+
+func (d *interceptedDatabase) Session() ISession {
+	return &interceptedSession{ISession: d.IDatabase.Session(), chain: d.chain}
+}
+
+func (d *interceptedDatabase) FindRef(ref *mgo.DBRef) IQuery {
+	database := ref.Database
+	if database == "" {
+		database = d.Name()
+	}
+	return newInterceptedQuery(d.IDatabase.FindRef(ref), d.chain, Operation{
+		Database:   database,
+		Collection: ref.Collection,
+		Filter:     bson.M{"_id": ref.Id},
+		session:    d.IDatabase.Session,
+	})
+}
+
+func (d *interceptedDatabase) MustEnsureIndex(index Index, collection string) {
+	d.C(collection).MustEnsureIndex(index)
+}
+
+func (d *interceptedDatabase) Run(cmd interface{}, result interface{}) error {
+	return d.chain.run(d.op("Run", cmd), func(op *Operation) error {
+		return d.IDatabase.Run(op.Command, result)
+	})
+}
+
+func (d *interceptedDatabase) CollectionNames() (names []string, err error) {
+	err = d.chain.run(d.op("CollectionNames", nil), func(*Operation) error {
+		names, err = d.IDatabase.CollectionNames()
+		return err
+	})
+	return
+}
+
+func (d *interceptedDatabase) DropDatabase() error {
+	return d.chain.run(d.op("DropDatabase", nil), func(*Operation) error {
+		return d.IDatabase.DropDatabase()
+	})
+}
+
+func (d *interceptedDatabase) op(name string, cmd interface{}) *Operation {
+	return &Operation{Name: name, Kind: OperationCommand, Database: d.Name(), Command: cmd, session: d.IDatabase.Session}
+}
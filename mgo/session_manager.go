@@ -0,0 +1,306 @@
+package mgo
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// ErrReadPreferenceUnsupported is returned by the queries and pipelines which read preference was changed while their
+// session cannot be replaced, only the ones of a SessionManager support it.
+var ErrReadPreferenceUnsupported = errors.New("the read preference can only be changed in the queries and pipelines of a SessionManager")
+
+// SessionManagerOptions defines the default read preference of a session manager.
+type SessionManagerOptions struct {
+	// ReadMode is the consistency mode of the read session. Defaults to mgo.SecondaryPreferred.
+	ReadMode *mgo.Mode
+	// ReadTags restricts the reads to the servers with the given tags, see SelectServers in ISession.
+	ReadTags []bson.D
+}
+
+// SessionManager splits the reads from the writes: it holds a primary session for the writes and a secondary
+// preferred session for the reads, so the queries do not hit the primary unless no secondary is available.
+//
+// The collections obtained through DB route Find, FindId, Count and Pipe to the read session, and any other operation
+// to the write session. The read preference can be overridden per query and pipeline with ReadPreference, eg: to send
+// the analytics queries to the servers tagged for them.
+//
+// Like any mgo session in these modes, each session of the manager reserves a single socket, so the concurrent
+// operations performed through the same manager are serialized. Use Copy to obtain a manager per request, which
+// sessions use their own sockets from the pool, and Close it once the request is done:
+//
+//   Example:
+//
+//      manager := mgo.NewSessionManager(session)
+//      defer manager.Close()
+//
+//      func handle(w http.ResponseWriter, r *http.Request) {
+//          m := manager.Copy()
+//          defer m.Close()
+//
+//          orders := m.DB("shop").C("orders")
+//          err := orders.Insert(order)                               // primary
+//          err = orders.FindId(id).One(&order)                       // secondary preferred
+//          err = orders.Pipe(pipeline).
+//              ReadPreference(mgo.Secondary, bson.D{{"use", "analytics"}}).
+//              All(&report)                                          // secondaries tagged for analytics
+//      }
+//
+// The read sessions are copies of the provided session, so its interceptors apply to every session of the manager.
+type SessionManager struct {
+	write    ISession
+	readMode mgo.Mode
+	readTags []bson.D
+	mux      sync.Mutex
+	reads    map[string]ISession
+}
+
+// NewSessionManager creates a new session manager which copies the provided session to obtain the write and read
+// sessions.
+//
+//   {session} - The session to copy
+//   {opts}    - (Optional) The default read preference
+//
+func NewSessionManager(session ISession, opts ...SessionManagerOptions) *SessionManager {
+	cfg := SessionManagerOptions{}
+	if len(opts) > 0 {
+		cfg = opts[0]
+	}
+	mode := mgo.SecondaryPreferred
+	if cfg.ReadMode != nil {
+		mode = *cfg.ReadMode
+	}
+
+	write := session.Copy()
+	write.SetMode(mgo.Primary, true)
+	return &SessionManager{write: write, readMode: mode, readTags: cfg.ReadTags, reads: map[string]ISession{}}
+}
+
+// Copy returns a new session manager with the same read preference, which sessions are copies of the sessions of m and
+// use their own sockets. Close must be invoked once the copy is no longer used.
+func (m *SessionManager) Copy() *SessionManager {
+	write := m.write.Copy()
+	write.SetMode(mgo.Primary, true)
+	return &SessionManager{write: write, readMode: m.readMode, readTags: m.readTags, reads: map[string]ISession{}}
+}
+
+// Write returns the session used for the writes, which always uses the primary.
+func (m *SessionManager) Write() ISession {
+	return m.write
+}
+
+// Read returns the session used for the reads, with the default read preference.
+func (m *SessionManager) Read() ISession {
+	return m.ReadWith(m.readMode, m.readTags...)
+}
+
+// ReadWith returns a session with the given consistency mode, restricted to the servers with the given tags. The
+// sessions are created once per read preference and reused.
+func (m *SessionManager) ReadWith(mode mgo.Mode, tags ...bson.D) ISession {
+	key := fmt.Sprintf("%d:%v", mode, tags)
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if s, ok := m.reads[key]; ok {
+		return s
+	}
+	s := m.write.Copy()
+	s.SetMode(mode, true)
+	if len(tags) > 0 {
+		s.SelectServers(tags...)
+	}
+	m.reads[key] = s
+	return s
+}
+
+// DB returns a database which collections split the reads from the writes, using the sessions of the manager.
+func (m *SessionManager) DB(name string) IDatabase {
+	return &splitDatabase{IDatabase: m.write.DB(name), manager: m}
+}
+
+// Close closes the write and read sessions. The session provided to NewSessionManager is not closed.
+func (m *SessionManager) Close() {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	for key, s := range m.reads {
+		s.Close()
+		delete(m.reads, key)
+	}
+	m.write.Close()
+}
+
+// splitDatabase is the IDatabase returned by SessionManager.DB. Its commands run in the write session.
+type splitDatabase struct {
+	IDatabase
+	manager *SessionManager
+}
+
+// With returns a copy of the database that performs the commands and the writes with the provided session, the reads
+// keep using the read sessions of the manager.
+func (d *splitDatabase) With(s ISession) IDatabase {
+	return &splitDatabase{IDatabase: d.IDatabase.With(s), manager: d.manager}
+}
+
+func (d *splitDatabase) C(name string) ICollection {
+	return &splitCollection{ICollection: d.IDatabase.C(name), manager: d.manager, db: d.Name(), name: name}
+}
+
+func (d *splitDatabase) MustEnsureIndex(index Index, collection string) {
+	d.C(collection).MustEnsureIndex(index)
+}
+
+// splitCollection is the ICollection returned by the databases of a SessionManager, the read operations use the read
+// session and the rest the write session.
+type splitCollection struct {
+	ICollection
+	manager *SessionManager
+	db      string
+	name    string
+}
+
+// With returns a copy of the collection that performs the writes with the provided session, the reads keep using the
+// read sessions of the manager.
+func (c *splitCollection) With(s ISession) ICollection {
+	return &splitCollection{ICollection: c.ICollection.With(s), manager: c.manager, db: c.db, name: c.name}
+}
+
+func (c *splitCollection) Find(query interface{}) IQuery {
+	return c.newQuery(c.manager.Read(), query, nil)
+}
+
+func (c *splitCollection) FindId(id interface{}) IQuery {
+	return c.Find(bson.M{"_id": id})
+}
+
+func (c *splitCollection) Count() (int, error) {
+	return c.reader(c.manager.Read()).Count()
+}
+
+func (c *splitCollection) Pipe(pipeline interface{}) IPipe {
+	return c.newPipe(c.manager.Read(), pipeline, nil)
+}
+
+func (c *splitCollection) reader(s ISession) ICollection {
+	return s.DB(c.db).C(c.name)
+}
+
+func (c *splitCollection) newQuery(s ISession, filter interface{}, ops []func(IQuery) IQuery) IQuery {
+	q := c.reader(s).Find(filter)
+	for _, op := range ops {
+		q = op(q)
+	}
+	ret := &splitQuery{queryDecorator: &queryDecorator{IQuery: q}, col: c, filter: filter, ops: ops}
+	ret.self = ret
+	return ret
+}
+
+func (c *splitCollection) newPipe(s ISession, pipeline interface{}, ops []func(IPipe) IPipe) IPipe {
+	p := c.reader(s).Pipe(pipeline)
+	for _, op := range ops {
+		p = op(p)
+	}
+	return &splitPipe{IPipe: p, col: c, pipeline: pipeline, ops: ops}
+}
+
+// splitQuery records the functions used to build the query so it can be recreated in a different session by
+// ReadPreference and Apply.
+type splitQuery struct {
+	*queryDecorator
+	col    *splitCollection
+	filter interface{}
+	ops    []func(IQuery) IQuery
+}
+
+func (q *splitQuery) ReadPreference(mode mgo.Mode, tags ...bson.D) IQuery {
+	return q.col.newQuery(q.col.manager.ReadWith(mode, tags...), q.filter, q.ops)
+}
+
+// Apply runs the findAndModify command in the write session, since it cannot run in the secondaries.
+func (q *splitQuery) Apply(change Change, result interface{}) (*ChangeInfo, error) {
+	return q.col.newQuery(q.col.manager.Write(), q.filter, q.ops).(*splitQuery).IQuery.Apply(change, result)
+}
+
+func (q *splitQuery) record(op func(IQuery) IQuery) IQuery {
+	q.ops = append(q.ops[:len(q.ops):len(q.ops)], op)
+	q.IQuery = op(q.IQuery)
+	return q
+}
+
+func (q *splitQuery) Batch(n int) IQuery {
+	return q.record(func(q IQuery) IQuery { return q.Batch(n) })
+}
+
+func (q *splitQuery) Prefetch(p float64) IQuery {
+	return q.record(func(q IQuery) IQuery { return q.Prefetch(p) })
+}
+
+func (q *splitQuery) Skip(n int) IQuery {
+	return q.record(func(q IQuery) IQuery { return q.Skip(n) })
+}
+
+func (q *splitQuery) Limit(n int) IQuery {
+	return q.record(func(q IQuery) IQuery { return q.Limit(n) })
+}
+
+func (q *splitQuery) Select(selector interface{}) IQuery {
+	return q.record(func(q IQuery) IQuery { return q.Select(selector) })
+}
+
+func (q *splitQuery) Sort(fields ...string) IQuery {
+	return q.record(func(q IQuery) IQuery { return q.Sort(fields...) })
+}
+
+func (q *splitQuery) Hint(indexKey ...string) IQuery {
+	return q.record(func(q IQuery) IQuery { return q.Hint(indexKey...) })
+}
+
+func (q *splitQuery) SetMaxScan(n int) IQuery {
+	return q.record(func(q IQuery) IQuery { return q.SetMaxScan(n) })
+}
+
+func (q *splitQuery) SetMaxTime(d time.Duration) IQuery {
+	return q.record(func(q IQuery) IQuery { return q.SetMaxTime(d) })
+}
+
+func (q *splitQuery) Snapshot() IQuery {
+	return q.record(func(q IQuery) IQuery { return q.Snapshot() })
+}
+
+func (q *splitQuery) Comment(comment string) IQuery {
+	return q.record(func(q IQuery) IQuery { return q.Comment(comment) })
+}
+
+func (q *splitQuery) LogReplay() IQuery {
+	return q.record(func(q IQuery) IQuery { return q.LogReplay() })
+}
+
+// splitPipe records the functions used to build the pipeline so it can be recreated in a different session by
+// ReadPreference.
+type splitPipe struct {
+	IPipe
+	col      *splitCollection
+	pipeline interface{}
+	ops      []func(IPipe) IPipe
+}
+
+func (p *splitPipe) ReadPreference(mode mgo.Mode, tags ...bson.D) IPipe {
+	return p.col.newPipe(p.col.manager.ReadWith(mode, tags...), p.pipeline, p.ops)
+}
+
+func (p *splitPipe) AllowDiskUse() IPipe {
+	return p.record(func(p IPipe) IPipe { return p.AllowDiskUse() })
+}
+
+func (p *splitPipe) Batch(n int) IPipe {
+	return p.record(func(p IPipe) IPipe { return p.Batch(n) })
+}
+
+func (p *splitPipe) record(op func(IPipe) IPipe) IPipe {
+	p.ops = append(p.ops[:len(p.ops):len(p.ops)], op)
+	p.IPipe = op(p.IPipe)
+	return p
+}
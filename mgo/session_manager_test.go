@@ -0,0 +1,206 @@
+package mgo
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type modeSession struct {
+	ISession
+	mode    mgo.Mode
+	tags    []bson.D
+	copies  *[]*modeSession
+	closed  bool
+	queries []*QueryMock
+}
+
+func (s *modeSession) Copy() ISession {
+	c := &modeSession{mode: s.mode, copies: s.copies}
+	*s.copies = append(*s.copies, c)
+	return c
+}
+
+func (s *modeSession) SetMode(mode mgo.Mode, _ bool) {
+	s.mode = mode
+}
+
+func (s *modeSession) SelectServers(tags ...bson.D) {
+	s.tags = tags
+}
+
+func (s *modeSession) Close() {
+	s.closed = true
+}
+
+func (s *modeSession) DB(name string) IDatabase {
+	return &modeDatabase{name: name, session: s}
+}
+
+type modeDatabase struct {
+	IDatabase
+	name    string
+	session *modeSession
+}
+
+func (d *modeDatabase) Name() string {
+	return d.name
+}
+
+func (d *modeDatabase) C(name string) ICollection {
+	return &modeCollection{session: d.session}
+}
+
+func (d *modeDatabase) With(s ISession) IDatabase {
+	return &modeDatabase{name: d.name, session: s.(*modeSession)}
+}
+
+type modeCollection struct {
+	ICollection
+	session *modeSession
+	removed []interface{}
+}
+
+func (c *modeCollection) With(s ISession) ICollection {
+	return &modeCollection{session: s.(*modeSession)}
+}
+
+func (c *modeCollection) Find(query interface{}) IQuery {
+	q := MockQuery()
+	q.WhenReturn("Apply", &ChangeInfo{}, nil)
+	c.session.queries = append(c.session.queries, q)
+	return q
+}
+
+func (c *modeCollection) Pipe(pipeline interface{}) IPipe {
+	return &modePipe{session: c.session}
+}
+
+func (c *modeCollection) Remove(selector interface{}) error {
+	c.session.queries = append(c.session.queries, nil)
+	return nil
+}
+
+type modePipe struct {
+	IPipe
+	session   *modeSession
+	diskUsage bool
+}
+
+func (p *modePipe) AllowDiskUse() IPipe {
+	p.diskUsage = true
+	return p
+}
+
+func newTestManager() (*SessionManager, *[]*modeSession) {
+	copies := &[]*modeSession{}
+	return NewSessionManager(&modeSession{mode: mgo.Strong, copies: copies}), copies
+}
+
+func TestSessionManager_Split(t *testing.T) {
+	manager, copies := newTestManager()
+	write := manager.Write().(*modeSession)
+	assert.Equal(t, mgo.Primary, write.mode)
+
+	col := manager.DB("shop").C("orders")
+	col.Find(bson.M{"a": 1}).Sort("name").Limit(5)
+	read := manager.Read().(*modeSession)
+	assert.Equal(t, mgo.SecondaryPreferred, read.mode)
+	assert.Len(t, read.queries, 1)
+	assert.Equal(t, 1, read.queries[0].Times("Sort"))
+
+	assert.NoError(t, col.Remove(bson.M{"a": 1}))
+	assert.Len(t, write.queries, 1)
+
+	_, err := col.Find(bson.M{"a": 1}).Sort("name").Apply(Change{Remove: true}, nil)
+	assert.NoError(t, err)
+	assert.Len(t, write.queries, 2)
+	assert.Equal(t, 1, write.queries[1].Times("Sort"))
+	assert.Equal(t, 1, write.queries[1].Times("Apply"))
+
+	manager.Close()
+	for _, c := range *copies {
+		assert.True(t, c.closed)
+	}
+}
+
+func TestSessionManager_ReadPreference(t *testing.T) {
+	manager, copies := newTestManager()
+	col := manager.DB("shop").C("orders")
+	tags := bson.D{{Name: "use", Value: "analytics"}}
+
+	q := col.Find(nil).Sort("-total").Limit(10).ReadPreference(mgo.Secondary, tags)
+	q.Skip(2)
+	analytics := manager.ReadWith(mgo.Secondary, tags).(*modeSession)
+	assert.Equal(t, mgo.Secondary, analytics.mode)
+	assert.Equal(t, []bson.D{tags}, analytics.tags)
+	assert.Len(t, analytics.queries, 1)
+	assert.Equal(t, 1, analytics.queries[0].Times("Sort"))
+	assert.Equal(t, 1, analytics.queries[0].Times("Limit"))
+	assert.Equal(t, 1, analytics.queries[0].Times("Skip"))
+
+	p := col.Pipe([]bson.M{}).AllowDiskUse().ReadPreference(mgo.Secondary, tags)
+	assert.True(t, p.(*splitPipe).IPipe.(*modePipe).diskUsage)
+	assert.Equal(t, analytics, p.(*splitPipe).IPipe.(*modePipe).session)
+
+	col.Find(nil).ReadPreference(mgo.Secondary, tags)
+	assert.Len(t, *copies, 3)
+
+	// The decorators forward the read preference to the wrapped queries
+	NewTenantCollection(col, "acme").Find(nil).ReadPreference(mgo.Secondary, tags)
+	assert.Len(t, analytics.queries, 3)
+}
+
+func TestReadPreference_Unsupported(t *testing.T) {
+	tags := bson.D{{Name: "use", Value: "analytics"}}
+
+	q := NewQuery().ReadPreference(mgo.Secondary, tags).Sort("name").Limit(1)
+	assert.Nil(t, q.Q())
+	assert.True(t, errors.Is(q.One(nil), ErrReadPreferenceUnsupported))
+	_, err := q.Count()
+	assert.True(t, errors.Is(err, ErrReadPreferenceUnsupported))
+	assert.True(t, errors.Is(q.Iter().Err(), ErrReadPreferenceUnsupported))
+
+	q = newInterceptedQuery(NewQuery(), nil, Operation{}).ReadPreference(mgo.Secondary, tags)
+	assert.True(t, errors.Is(q.All(nil), ErrReadPreferenceUnsupported))
+
+	p := NewPipe().ReadPreference(mgo.Secondary, tags).AllowDiskUse()
+	assert.True(t, errors.Is(p.All(nil), ErrReadPreferenceUnsupported))
+
+	p = (&interceptedPipe{IPipe: NewPipe()}).ReadPreference(mgo.Secondary, tags)
+	assert.True(t, errors.Is(p.One(nil), ErrReadPreferenceUnsupported))
+}
+
+func TestSessionManager_Copy(t *testing.T) {
+	manager, copies := newTestManager()
+	request := manager.Copy()
+	assert.NotSame(t, manager.Write(), request.Write())
+	assert.Equal(t, mgo.Primary, request.Write().(*modeSession).mode)
+
+	request.DB("shop").C("orders").Find(nil)
+	read := request.Read().(*modeSession)
+	assert.Len(t, read.queries, 1)
+	assert.Len(t, manager.Read().(*modeSession).queries, 0)
+
+	request.Close()
+	assert.True(t, request.Write().(*modeSession).closed)
+	assert.True(t, read.closed)
+	assert.False(t, manager.Write().(*modeSession).closed)
+	assert.Len(t, *copies, 4)
+}
+
+func TestSessionManager_With(t *testing.T) {
+	manager, copies := newTestManager()
+	other := &modeSession{mode: mgo.Primary, copies: copies}
+
+	col := manager.DB("shop").C("orders").With(other)
+	assert.NoError(t, col.Remove(bson.M{"a": 1}))
+	assert.Len(t, other.queries, 1)
+
+	col.Find(nil)
+	assert.Len(t, manager.Read().(*modeSession).queries, 1)
+	assert.Len(t, other.queries, 1)
+}
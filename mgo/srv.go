@@ -0,0 +1,213 @@
+package mgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const srvScheme = "mongodb+srv://"
+
+// srvTxtOptions are the only options allowed in the TXT record of a mongodb+srv URL.
+var srvTxtOptions = map[string]bool{
+	"authsource": true,
+	"replicaset": true,
+}
+
+// Resolver resolves the DNS records of the mongodb+srv URLs. It is satisfied by *net.Resolver.
+type Resolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (cname string, addrs []*net.SRV, err error)
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// DefaultResolver is the Resolver used by ParseURL and the dial functions to resolve the mongodb+srv URLs. It can be
+// replaced to use a different DNS server, eg: a *net.Resolver with a custom Dial function.
+var DefaultResolver Resolver = net.DefaultResolver
+
+// srvURL contains the parts of a mongodb+srv URL.
+type srvURL struct {
+	userInfo string
+	host     string
+	database string
+	options  []string
+}
+
+// ResolveURL converts a mongodb+srv URL into a mongodb URL, returning any other URL as is. The seedlist is obtained
+// from the SRV records of '_mongodb._tcp.{host}' and the default 'authSource' and 'replicaSet' options from its TXT
+// record, the options in the URL take precedence. A missing or empty TXT record provides no options. TLS is enabled
+// unless the URL sets the 'tls' or 'ssl' options.
+//
+//   {url}      - The URL to resolve
+//   {resolver} - (Optional) the resolver to use, defaults to DefaultResolver.
+//
+func ResolveURL(url string, resolver ...Resolver) (string, error) {
+	if !isSRVURL(url) {
+		return url, nil
+	}
+
+	parsed, err := parseSRVURL(url)
+	if err != nil {
+		return "", err
+	}
+
+	r := DefaultResolver
+	if len(resolver) > 0 && resolver[0] != nil {
+		r = resolver[0]
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), DialTimeout)
+	defer cancel()
+
+	hosts, err := parsed.lookupHosts(ctx, r)
+	if err != nil {
+		return "", err
+	}
+	options, err := parsed.lookupOptions(ctx, r)
+	if err != nil {
+		return "", err
+	}
+
+	ret := "mongodb://"
+	if parsed.userInfo != "" {
+		ret += parsed.userInfo + "@"
+	}
+	ret += strings.Join(hosts, ",") + "/" + parsed.database
+	if len(options) > 0 {
+		ret += "?" + strings.Join(options, "&")
+	}
+	return ret, nil
+}
+
+func isSRVURL(url string) bool {
+	return strings.HasPrefix(strings.ToLower(url), srvScheme)
+}
+
+// parseSRVURL validates the syntax of a mongodb+srv URL without resolving it.
+func parseSRVURL(connStr string) (*srvURL, error) {
+	ret := &srvURL{}
+	rest := connStr[len(srvScheme):]
+
+	if i := strings.Index(rest, "?"); i >= 0 {
+		ret.options = strings.FieldsFunc(rest[i+1:], func(r rune) bool { return r == '&' || r == ';' })
+		rest = rest[:i]
+	}
+	if i := strings.Index(rest, "/"); i >= 0 {
+		rest, ret.database = rest[:i], rest[i+1:]
+	}
+	if i := strings.LastIndex(rest, "@"); i >= 0 {
+		ret.userInfo, rest = rest[:i], rest[i+1:]
+	}
+
+	switch {
+	case rest == "":
+		return nil, errors.New("the mongodb+srv URL requires a host name")
+	case strings.Contains(rest, ","):
+		return nil, errors.New("the mongodb+srv URL must contain a single host name")
+	case strings.Contains(rest, ":"):
+		return nil, errors.New("the mongodb+srv URL must not contain a port")
+	case len(strings.Split(rest, ".")) < 3:
+		return nil, fmt.Errorf("the mongodb+srv host name '%s' must contain at least a domain name and a top level domain", rest)
+	}
+	ret.host = rest
+	return ret, nil
+}
+
+// username returns the unescaped username of the URL.
+func (u *srvURL) username() string {
+	name := u.userInfo
+	if i := strings.Index(name, ":"); i >= 0 {
+		name = name[:i]
+	}
+	if unescaped, err := url.QueryUnescape(name); err == nil {
+		return unescaped
+	}
+	return name
+}
+
+// lookupHosts returns the 'host:port' addresses of the SRV records, which must belong to the same parent domain as the
+// host of the URL.
+func (u *srvURL) lookupHosts(ctx context.Context, r Resolver) ([]string, error) {
+	_, records, err := r.LookupSRV(ctx, "mongodb", "tcp", u.host)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve the SRV records of '%s': %v", u.host, err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no SRV records found for '%s'", u.host)
+	}
+
+	domain := strings.ToLower(u.host[strings.Index(u.host, ".")+1:])
+	var hosts []string
+	for _, record := range records {
+		target := strings.TrimSuffix(record.Target, ".")
+		if !strings.HasSuffix(strings.ToLower(target), "."+domain) {
+			return nil, fmt.Errorf("the SRV record '%s' of '%s' does not belong to the domain '%s'", target, u.host, domain)
+		}
+		hosts = append(hosts, net.JoinHostPort(target, strconv.Itoa(int(record.Port))))
+	}
+	return hosts, nil
+}
+
+// lookupOptions returns the options of the URL followed by the options of the TXT record not present in the URL, and
+// enables TLS if not configured.
+func (u *srvURL) lookupOptions(ctx context.Context, r Resolver) ([]string, error) {
+	records, err := r.LookupTXT(ctx, u.host)
+	if err != nil && !isDNSNotFound(err) {
+		return nil, fmt.Errorf("unable to resolve the TXT record of '%s': %v", u.host, err)
+	}
+
+	// A missing or empty TXT record means no additional options.
+	var txt []string
+	for _, record := range records {
+		if strings.TrimSpace(record) != "" {
+			txt = append(txt, record)
+		}
+	}
+	if len(txt) > 1 {
+		return nil, fmt.Errorf("multiple TXT records found for '%s'", u.host)
+	}
+
+	var (
+		options = append([]string{}, u.options...)
+		present = map[string]bool{}
+	)
+	for _, opt := range u.options {
+		present[strings.ToLower(optionKey(opt))] = true
+	}
+
+	if len(txt) == 1 {
+		for _, opt := range strings.Split(strings.TrimSpace(txt[0]), "&") {
+			if opt == "" {
+				continue
+			}
+			key := strings.ToLower(optionKey(opt))
+			if !srvTxtOptions[key] {
+				return nil, fmt.Errorf("the TXT record of '%s' contains the option '%s', only authSource and replicaSet are allowed", u.host, optionKey(opt))
+			}
+			if !present[key] {
+				options = append(options, opt)
+			}
+		}
+	}
+
+	if !present["tls"] && !present["ssl"] {
+		options = append(options, "tls=true")
+	}
+	return options, nil
+}
+
+// isDNSNotFound indicates whether the error reports that the looked up name has no records.
+func isDNSNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+func optionKey(opt string) string {
+	if i := strings.Index(opt, "="); i >= 0 {
+		return opt[:i]
+	}
+	return opt
+}
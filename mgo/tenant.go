@@ -0,0 +1,700 @@
+package mgo
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	// TenantDatabase stores each tenant in its own database, named after the database prefix and the tenant ID.
+	TenantDatabase TenantMode = iota
+	// TenantCollectionPrefix stores the tenants in a shared database, prefixing the collection names with the tenant ID.
+	TenantCollectionPrefix
+	// TenantDiscriminator stores the tenants in shared collections, identifying the documents of every tenant with a
+	// field. See NewTenantCollection.
+	TenantDiscriminator
+)
+
+const (
+	// DefaultTenantField is the default document field that holds the tenant ID in TenantDiscriminator mode.
+	DefaultTenantField = "tenant_id"
+	// DefaultTenantSeparator is the default separator between the tenant ID and the collection name in
+	// TenantCollectionPrefix mode.
+	DefaultTenantSeparator = "_"
+)
+
+var (
+	// ErrInvalidTenant is matched by the errors returned when a tenant ID cannot be used, use errors.Is(err, ErrInvalidTenant).
+	ErrInvalidTenant = errors.New("invalid tenant")
+	// ErrTenantUnsupported is matched by the errors returned by the operations that cannot be restricted to a tenant, such
+	// as dropping a shared collection or running a command, use errors.Is(err, ErrTenantUnsupported).
+	ErrTenantUnsupported = errors.New("the operation cannot be restricted to a tenant")
+)
+
+// TenantMode defines how the tenants are isolated.
+type TenantMode int
+
+// TenantRouterOptions defines how the tenant router resolves the database of a tenant.
+type TenantRouterOptions struct {
+	// Mode is how the tenants are isolated. Defaults to TenantDatabase.
+	Mode TenantMode
+	// Database is the shared database used in TenantCollectionPrefix and TenantDiscriminator modes. Defaults to the
+	// database of the session, as provided in the dial URL.
+	Database string
+	// DatabasePrefix is prepended to the tenant ID to obtain the database name in TenantDatabase mode.
+	DatabasePrefix string
+	// Separator is placed between the tenant ID and the collection name in TenantCollectionPrefix mode, the tenant IDs
+	// containing it are rejected. Defaults to DefaultTenantSeparator.
+	Separator string
+	// TenantField is the field that holds the tenant ID in TenantDiscriminator mode. Defaults to DefaultTenantField.
+	TenantField string
+	// Cluster returns the URL of the cluster that hosts the tenant, or an empty string if the tenant is hosted by the
+	// cluster of the router session. The sessions are dialed once per URL and cached.
+	Cluster func(tenant string) (string, error)
+	// Dial is used to establish the sessions to the clusters returned by Cluster. Defaults to Dial.
+	Dial func(url string) (ISession, error)
+}
+
+// TenantRouter resolves the database of a tenant.
+//
+//   Example:
+//
+//      router := mgo.NewTenantRouter(session, mgo.TenantRouterOptions{
+//          Mode:     mgo.TenantDiscriminator,
+//          Database: "saas",
+//      })
+//      db, err := router.DB(tenantId)
+//      if err != nil {
+//          return err
+//      }
+//      // Only finds the users of the tenant
+//      err = db.C("users").Find(bson.M{"name": name}).One(&user)
+//
+type TenantRouter struct {
+	session  ISession
+	opts     TenantRouterOptions
+	mux      sync.Mutex
+	sessions map[string]*tenantDial
+}
+
+// NewTenantRouter creates a new tenant router which uses the provided session for the tenants hosted in its cluster.
+//
+//   {session} - The session of the default cluster
+//   {opts}    - (Optional) The isolation mode and naming of the tenants
+//
+func NewTenantRouter(session ISession, opts ...TenantRouterOptions) *TenantRouter {
+	cfg := TenantRouterOptions{}
+	if len(opts) > 0 {
+		cfg = opts[0]
+	}
+	if cfg.Separator == "" {
+		cfg.Separator = DefaultTenantSeparator
+	}
+	if cfg.TenantField == "" {
+		cfg.TenantField = DefaultTenantField
+	}
+	if cfg.Dial == nil {
+		cfg.Dial = func(url string) (ISession, error) { return Dial(url) }
+	}
+	return &TenantRouter{session: session, opts: cfg, sessions: map[string]*tenantDial{}}
+}
+
+// DB returns the database of the provided tenant.
+func (r *TenantRouter) DB(tenant string) (IDatabase, error) {
+	if err := r.validate(tenant); err != nil {
+		return nil, err
+	}
+
+	s, err := r.Session(tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	switch r.opts.Mode {
+	case TenantCollectionPrefix:
+		return &tenantDatabase{IDatabase: s.DB(r.opts.Database), prefix: tenant + r.opts.Separator}, nil
+	case TenantDiscriminator:
+		return &tenantDatabase{IDatabase: s.DB(r.opts.Database), field: r.opts.TenantField, tenant: tenant}, nil
+	}
+	return s.DB(r.opts.DatabasePrefix + tenant), nil
+}
+
+// Session returns the session of the cluster that hosts the provided tenant.
+func (r *TenantRouter) Session(tenant string) (ISession, error) {
+	if r.opts.Cluster == nil {
+		return r.session, nil
+	}
+	url, err := r.opts.Cluster(tenant)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve the cluster of tenant '%s': %v", tenant, err)
+	}
+	if url == "" {
+		return r.session, nil
+	}
+
+	// Dials outside the lock, so a slow cluster does not block the tenants of the other clusters. The concurrent
+	// callers of the same URL wait for the first dial, the failed dials are not cached.
+	r.mux.Lock()
+	d, ok := r.sessions[url]
+	if !ok {
+		d = &tenantDial{done: make(chan struct{})}
+		r.sessions[url] = d
+	}
+	r.mux.Unlock()
+
+	if !ok {
+		d.session, d.err = r.opts.Dial(url)
+		if d.err != nil {
+			r.mux.Lock()
+			if r.sessions[url] == d {
+				delete(r.sessions, url)
+			}
+			r.mux.Unlock()
+		}
+		close(d.done)
+	}
+
+	<-d.done
+	return d.session, d.err
+}
+
+// Close closes the sessions dialed by the router, waiting for the dials in progress. The session provided to
+// NewTenantRouter is not closed.
+func (r *TenantRouter) Close() {
+	r.mux.Lock()
+	sessions := r.sessions
+	r.sessions = map[string]*tenantDial{}
+	r.mux.Unlock()
+
+	for _, d := range sessions {
+		<-d.done
+		if d.session != nil {
+			d.session.Close()
+		}
+	}
+}
+
+// tenantDial is the dial of a cluster session, done is closed once the session or the error are set.
+type tenantDial struct {
+	done    chan struct{}
+	session ISession
+	err     error
+}
+
+func (r *TenantRouter) validate(tenant string) error {
+	if tenant == "" {
+		return fmt.Errorf("%w: the tenant ID is empty", ErrInvalidTenant)
+	}
+	if r.opts.Mode != TenantDiscriminator && strings.ContainsAny(tenant, "/\\. \"$*<>:|?\x00") {
+		return fmt.Errorf("%w: '%s' contains characters not allowed in database or collection names", ErrInvalidTenant, tenant)
+	}
+	// The prefix of a tenant would match the collections of the tenants which ID starts with it and the separator.
+	if r.opts.Mode == TenantCollectionPrefix && strings.Contains(tenant, r.opts.Separator) {
+		return fmt.Errorf("%w: '%s' contains the separator '%s'", ErrInvalidTenant, tenant, r.opts.Separator)
+	}
+	return nil
+}
+
+// tenantDatabase is the IDatabase of a tenant in TenantCollectionPrefix and TenantDiscriminator modes. Since the
+// database is shared by all the tenants, Run is rejected and DropDatabase only drops the collections of the tenant.
+type tenantDatabase struct {
+	IDatabase
+	prefix string
+	field  string
+	tenant string
+}
+
+func (d *tenantDatabase) C(name string) ICollection {
+	if d.field != "" {
+		return NewTenantCollection(d.IDatabase.C(name), d.tenant, d.field)
+	}
+	return &prefixedCollection{ICollection: d.IDatabase.C(d.prefix + name), db: d}
+}
+
+// Run is rejected, the commands cannot be restricted to the tenant.
+func (d *tenantDatabase) Run(interface{}, interface{}) error {
+	return fmt.Errorf("%w: Run in the shared database '%s'", ErrTenantUnsupported, d.Name())
+}
+
+// DropDatabase drops the collections of the tenant in TenantCollectionPrefix mode. It is rejected in TenantDiscriminator
+// mode, the tenant documents can be removed with RemoveAll instead.
+func (d *tenantDatabase) DropDatabase() error {
+	if d.field != "" {
+		return fmt.Errorf("%w: DropDatabase of the shared database '%s'", ErrTenantUnsupported, d.Name())
+	}
+	names, err := d.CollectionNames()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := d.IDatabase.C(d.prefix + name).DropCollection(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *tenantDatabase) With(s ISession) IDatabase {
+	return &tenantDatabase{IDatabase: d.IDatabase.With(s), prefix: d.prefix, field: d.field, tenant: d.tenant}
+}
+
+func (d *tenantDatabase) MustEnsureIndex(index Index, collection string) {
+	d.C(collection).MustEnsureIndex(index)
+}
+
+func (d *tenantDatabase) FindRef(ref *mgo.DBRef) IQuery {
+	if ref.Database != "" && ref.Database != d.Name() {
+		return d.IDatabase.FindRef(ref)
+	}
+	return d.C(ref.Collection).FindId(ref.Id)
+}
+
+// CollectionNames returns the names of the collections of the tenant, without the prefix in TenantCollectionPrefix mode.
+func (d *tenantDatabase) CollectionNames() ([]string, error) {
+	names, err := d.IDatabase.CollectionNames()
+	if err != nil || d.prefix == "" {
+		return names, err
+	}
+	var ret []string
+	for _, name := range names {
+		if strings.HasPrefix(name, d.prefix) {
+			ret = append(ret, strings.TrimPrefix(name, d.prefix))
+		}
+	}
+	return ret, nil
+}
+
+// prefixedCollection is a collection of a tenant in TenantCollectionPrefix mode, its Database is the tenant database.
+type prefixedCollection struct {
+	ICollection
+	db *tenantDatabase
+}
+
+func (c *prefixedCollection) With(s ISession) ICollection {
+	return &prefixedCollection{ICollection: c.ICollection.With(s), db: c.db.With(s).(*tenantDatabase)}
+}
+
+func (c *prefixedCollection) Database() IDatabase {
+	return c.db
+}
+
+// NewTenantCollection decorates the provided collection so it only contains the documents of a tenant:
+//
+//    - Find, FindId, Count, Pipe, the update and the remove operations only match the documents of the tenant. The
+//      pipelines with stages that access other collections, such as $lookup, are rejected with ErrTenantUnsupported.
+//    - Insert and the replacement documents of the update operations set the tenant field. Pointers to structs are
+//      updated in place. Documents that belong to another tenant are rejected with ErrInvalidTenant.
+//    - The operations queued in Bulk are filtered and prepared the same way.
+//    - DropCollection, Create and the index operations but Indexes are rejected with ErrTenantUnsupported, since the
+//      collection and its indexes are shared. They can be managed through the shared collection instead.
+//    - Database returns the database of the tenant, see TenantDiscriminator.
+//
+//   {col}    - The collection to decorate
+//   {tenant} - The ID of the tenant
+//   {field}  - (Optional) The field that holds the tenant ID, defaults to DefaultTenantField
+//
+func NewTenantCollection(col ICollection, tenant string, field ...string) ICollection {
+	f := DefaultTenantField
+	if len(field) > 0 && field[0] != "" {
+		f = field[0]
+	}
+	return &tenantCollection{ICollection: col, tenant: tenant, field: f}
+}
+
+type tenantCollection struct {
+	ICollection
+	tenant string
+	field  string
+}
+
+func (c *tenantCollection) With(s ISession) ICollection {
+	return &tenantCollection{ICollection: c.ICollection.With(s), tenant: c.tenant, field: c.field}
+}
+
+func (c *tenantCollection) Find(query interface{}) IQuery {
+	sel, err := mergeFilter(query, c.filter())
+	if err != nil {
+		// Never run the query unfiltered, let the server report the error.
+		return c.newQuery(c.ICollection.Find(bson.M{"$and": []interface{}{query, c.filter()}}))
+	}
+	return c.newQuery(c.ICollection.Find(sel))
+}
+
+func (c *tenantCollection) FindId(id interface{}) IQuery {
+	return c.Find(bson.M{"_id": id})
+}
+
+func (c *tenantCollection) Count() (int, error) {
+	return c.Find(nil).Count()
+}
+
+// Pipe prepends a $match of the tenant to the pipeline. The stages that read or write other collections ($lookup,
+// $graphLookup, $unionWith, $out and $merge), including the ones nested in $facet, cannot be restricted to the tenant
+// and are rejected, the returned pipe fails with ErrTenantUnsupported.
+func (c *tenantCollection) Pipe(pipeline interface{}) IPipe {
+	stages := pipelineStages(pipeline)
+	if err := checkTenantStages(stages); err != nil {
+		return &errPipe{err: err}
+	}
+	return c.ICollection.Pipe(append([]interface{}{bson.M{"$match": c.filter()}}, stages...))
+}
+
+func (c *tenantCollection) Insert(docs ...interface{}) error {
+	prepared := make([]interface{}, len(docs))
+	for i, doc := range docs {
+		d, err := c.prepareInsert(doc)
+		if err != nil {
+			return err
+		}
+		prepared[i] = d
+	}
+	return c.ICollection.Insert(prepared...)
+}
+
+func (c *tenantCollection) Update(selector interface{}, update interface{}) error {
+	sel, u, err := c.prepareUpdate(selector, update)
+	if err != nil {
+		return err
+	}
+	return c.ICollection.Update(sel, u)
+}
+
+func (c *tenantCollection) UpdateId(id interface{}, update interface{}) error {
+	return c.Update(bson.M{"_id": id}, update)
+}
+
+func (c *tenantCollection) UpdateAll(selector interface{}, update interface{}) (*ChangeInfo, error) {
+	sel, u, err := c.prepareUpdate(selector, update)
+	if err != nil {
+		return nil, err
+	}
+	return c.ICollection.UpdateAll(sel, u)
+}
+
+func (c *tenantCollection) Upsert(selector interface{}, update interface{}) (*ChangeInfo, error) {
+	sel, u, err := c.prepareUpdate(selector, update)
+	if err != nil {
+		return nil, err
+	}
+	return c.ICollection.Upsert(sel, u)
+}
+
+func (c *tenantCollection) UpsertId(id interface{}, update interface{}) (*ChangeInfo, error) {
+	return c.Upsert(bson.M{"_id": id}, update)
+}
+
+func (c *tenantCollection) Remove(selector interface{}) error {
+	sel, err := mergeFilter(selector, c.filter())
+	if err != nil {
+		return err
+	}
+	return c.ICollection.Remove(sel)
+}
+
+func (c *tenantCollection) RemoveId(id interface{}) error {
+	return c.Remove(bson.M{"_id": id})
+}
+
+func (c *tenantCollection) RemoveAll(selector interface{}) (*ChangeInfo, error) {
+	sel, err := mergeFilter(selector, c.filter())
+	if err != nil {
+		return nil, err
+	}
+	return c.ICollection.RemoveAll(sel)
+}
+
+// DropCollection is rejected, the collection is shared by all the tenants.
+func (c *tenantCollection) DropCollection() error {
+	return c.shared("DropCollection")
+}
+
+// Create is rejected, the collection is shared by all the tenants.
+func (c *tenantCollection) Create(*CollectionInfo) error {
+	return c.shared("Create")
+}
+
+// EnsureIndex is rejected, the indexes are shared by all the tenants.
+func (c *tenantCollection) EnsureIndex(Index) error {
+	return c.shared("EnsureIndex")
+}
+
+// EnsureIndexKey is rejected, the indexes are shared by all the tenants.
+func (c *tenantCollection) EnsureIndexKey(...string) error {
+	return c.shared("EnsureIndexKey")
+}
+
+// MustEnsureIndex panics, the indexes are shared by all the tenants.
+func (c *tenantCollection) MustEnsureIndex(index Index) {
+	mustEnsureIndex(c, index)
+}
+
+// DropIndex is rejected, the indexes are shared by all the tenants.
+func (c *tenantCollection) DropIndex(...string) error {
+	return c.shared("DropIndex")
+}
+
+// DropIndexName is rejected, the indexes are shared by all the tenants.
+func (c *tenantCollection) DropIndexName(string) error {
+	return c.shared("DropIndexName")
+}
+
+func (c *tenantCollection) Database() IDatabase {
+	return &tenantDatabase{IDatabase: c.ICollection.Database(), field: c.field, tenant: c.tenant}
+}
+
+func (c *tenantCollection) Bulk() IBulk {
+	return &tenantBulk{IBulk: c.ICollection.Bulk(), col: c}
+}
+
+func (c *tenantCollection) BulkUpsert(pairs ...interface{}) (*BulkResult, error) {
+	return c.Bulk().Upsert(pairs...).Run()
+}
+
+func (c *tenantCollection) UpdateIfVersion(selector interface{}, version int64, update interface{}) error {
+	sel, u, err := c.prepareUpdate(selector, update)
+	if err != nil {
+		return err
+	}
+	return c.ICollection.UpdateIfVersion(sel, version, u)
+}
+
+func (c *tenantCollection) ReplaceIfVersion(selector interface{}, version int64, doc interface{}) error {
+	sel, d, err := c.prepareUpdate(selector, doc)
+	if err != nil {
+		return err
+	}
+	return c.ICollection.ReplaceIfVersion(sel, version, d)
+}
+
+func (c *tenantCollection) filter() bson.M {
+	return bson.M{c.field: c.tenant}
+}
+
+// prepareInsert sets the tenant field of the document, failing if it belongs to another tenant.
+func (c *tenantCollection) prepareInsert(doc interface{}) (interface{}, error) {
+	if f := structField(doc, c.field); f.IsValid() && f.Kind() == reflect.String {
+		if f.String() != "" && f.String() != c.tenant {
+			return nil, c.mismatch(f.String())
+		}
+		f.SetString(c.tenant)
+		return doc, nil
+	}
+
+	m, err := toDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := m[c.field]; ok && !isEmptyValue(v) && v != c.tenant {
+		return nil, c.mismatch(v)
+	}
+	m[c.field] = c.tenant
+	return m, nil
+}
+
+// prepareUpdate restricts the selector to the tenant and prepares the update document, see prepareUpdateDocument.
+func (c *tenantCollection) prepareUpdate(selector, update interface{}) (bson.M, interface{}, error) {
+	sel, err := mergeFilter(selector, c.filter())
+	if err != nil {
+		return nil, nil, err
+	}
+	u, err := c.prepareUpdateDocument(update)
+	return sel, u, err
+}
+
+// prepareUpdateDocument sets the tenant field of replacement documents. Update operators that change the tenant field
+// are rejected.
+func (c *tenantCollection) prepareUpdateDocument(update interface{}) (interface{}, error) {
+	m, err := toDocument(update)
+	if err != nil {
+		return nil, err
+	}
+	if !isOperatorDocument(m) {
+		return c.prepareInsert(update)
+	}
+
+	for op, v := range m {
+		fields, err := toDocument(v)
+		if err != nil {
+			return nil, err
+		}
+		if value, ok := fields[c.field]; ok && (op != "$set" && op != "$setOnInsert" || value != c.tenant) {
+			return nil, fmt.Errorf("%w: the update operator %s cannot modify the tenant field '%s'", ErrInvalidTenant, op, c.field)
+		}
+		if op != "$rename" {
+			continue
+		}
+		for _, to := range fields {
+			if to == c.field {
+				return nil, fmt.Errorf("%w: the update operator %s cannot modify the tenant field '%s'", ErrInvalidTenant, op, c.field)
+			}
+		}
+	}
+	return m, nil
+}
+
+func (c *tenantCollection) newQuery(q IQuery) IQuery {
+	ret := &tenantQuery{queryDecorator: &queryDecorator{IQuery: q}, col: c}
+	ret.self = ret
+	return ret
+}
+
+// tenantQuery prepares the update documents of Apply so they cannot move the documents to another tenant.
+type tenantQuery struct {
+	*queryDecorator
+	col *tenantCollection
+}
+
+func (q *tenantQuery) Apply(change Change, result interface{}) (*ChangeInfo, error) {
+	if !change.Remove {
+		u, err := q.col.prepareUpdateDocument(change.Update)
+		if err != nil {
+			return nil, err
+		}
+		change.Update = u
+	}
+	return q.IQuery.Apply(change, result)
+}
+
+// tenantUnsafeStages are the aggregation stages that read or write other collections, unfiltered by the tenant.
+var tenantUnsafeStages = []string{"$lookup", "$graphLookup", "$unionWith", "$out", "$merge"}
+
+// checkTenantStages fails with ErrTenantUnsupported if any of the stages, or the sub-pipelines of $facet, read or write
+// other collections.
+func checkTenantStages(stages []interface{}) error {
+	for _, stage := range stages {
+		doc, err := toDocument(stage)
+		if err != nil {
+			return err
+		}
+		for _, op := range tenantUnsafeStages {
+			if _, ok := doc[op]; ok {
+				return fmt.Errorf("%w: the %s stage reads or writes other collections", ErrTenantUnsupported, op)
+			}
+		}
+		if facet, ok := doc["$facet"]; ok {
+			pipelines, err := toDocument(facet)
+			if err != nil {
+				return err
+			}
+			for _, p := range pipelines {
+				if err := checkTenantStages(pipelineStages(p)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// shared returns the error of the operations that cannot be restricted to the tenant.
+func (c *tenantCollection) shared(operation string) error {
+	return fmt.Errorf("%w: %s of the shared collection '%s'", ErrTenantUnsupported, operation, c.Name())
+}
+
+func (c *tenantCollection) mismatch(value interface{}) error {
+	return fmt.Errorf("%w: the document belongs to tenant '%v' instead of '%s'", ErrInvalidTenant, value, c.tenant)
+}
+
+// tenantBulk is the IBulk of a tenant collection, the preparation errors are returned by Run.
+type tenantBulk struct {
+	IBulk
+	col *tenantCollection
+	err error
+}
+
+func (b *tenantBulk) Unordered() IBulk {
+	b.IBulk = b.IBulk.Unordered()
+	return b
+}
+
+func (b *tenantBulk) Insert(docs ...interface{}) IBulk {
+	prepared := make([]interface{}, 0, len(docs))
+	for _, doc := range docs {
+		d, err := b.col.prepareInsert(doc)
+		if err != nil {
+			b.setErr(err)
+			return b
+		}
+		prepared = append(prepared, d)
+	}
+	b.IBulk = b.IBulk.Insert(prepared...)
+	return b
+}
+
+func (b *tenantBulk) Remove(selectors ...interface{}) IBulk {
+	if sels, ok := b.selectors(selectors); ok {
+		b.IBulk = b.IBulk.Remove(sels...)
+	}
+	return b
+}
+
+func (b *tenantBulk) RemoveAll(selectors ...interface{}) IBulk {
+	if sels, ok := b.selectors(selectors); ok {
+		b.IBulk = b.IBulk.RemoveAll(sels...)
+	}
+	return b
+}
+
+func (b *tenantBulk) Update(pairs ...interface{}) IBulk {
+	if p, ok := b.pairs(pairs); ok {
+		b.IBulk = b.IBulk.Update(p...)
+	}
+	return b
+}
+
+func (b *tenantBulk) UpdateAll(pairs ...interface{}) IBulk {
+	if p, ok := b.pairs(pairs); ok {
+		b.IBulk = b.IBulk.UpdateAll(p...)
+	}
+	return b
+}
+
+func (b *tenantBulk) Upsert(pairs ...interface{}) IBulk {
+	if p, ok := b.pairs(pairs); ok {
+		b.IBulk = b.IBulk.Upsert(p...)
+	}
+	return b
+}
+
+func (b *tenantBulk) Run() (*BulkResult, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	return b.IBulk.Run()
+}
+
+func (b *tenantBulk) selectors(selectors []interface{}) ([]interface{}, bool) {
+	ret := make([]interface{}, 0, len(selectors))
+	for _, s := range selectors {
+		sel, err := mergeFilter(s, b.col.filter())
+		if err != nil {
+			b.setErr(err)
+			return nil, false
+		}
+		ret = append(ret, sel)
+	}
+	return ret, true
+}
+
+func (b *tenantBulk) pairs(pairs []interface{}) ([]interface{}, bool) {
+	ret, err := prepareBulkPairs(pairs, func(selector, update interface{}) (interface{}, interface{}, error) {
+		return b.col.prepareUpdate(selector, update)
+	})
+	if err != nil {
+		b.setErr(err)
+		return nil, false
+	}
+	return ret, true
+}
+
+func (b *tenantBulk) setErr(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
@@ -0,0 +1,335 @@
+package mgo
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type namedSession struct {
+	ISession
+	url         string
+	closed      bool
+	collections []string
+}
+
+func (s *namedSession) DB(name string) IDatabase {
+	return &namedDatabase{name: name, session: s}
+}
+
+func (s *namedSession) Close() {
+	s.closed = true
+}
+
+type namedDatabase struct {
+	IDatabase
+	name        string
+	session     *namedSession
+	collections map[string]*recorderCollection
+	dropped     bool
+}
+
+func (d *namedDatabase) Name() string {
+	return d.name
+}
+
+func (d *namedDatabase) C(name string) ICollection {
+	if d.collections == nil {
+		d.collections = map[string]*recorderCollection{}
+	}
+	if _, ok := d.collections[name]; !ok {
+		d.collections[name] = &recorderCollection{}
+	}
+	return d.collections[name]
+}
+
+func (d *namedDatabase) CollectionNames() ([]string, error) {
+	if d.session != nil && d.session.collections != nil {
+		return d.session.collections, nil
+	}
+	return []string{"acme_users", "acme_orders", "globex_users", "system.views"}, nil
+}
+
+func (d *namedDatabase) DropDatabase() error {
+	d.dropped = true
+	return nil
+}
+
+type tenantModel struct {
+	Id       string `bson:"_id"`
+	TenantId string `bson:"tenant_id"`
+}
+
+func TestTenantRouter_Modes(t *testing.T) {
+	s := &namedSession{}
+
+	db, err := NewTenantRouter(s, TenantRouterOptions{DatabasePrefix: "tenant_"}).DB("acme")
+	assert.NoError(t, err)
+	assert.Equal(t, "tenant_acme", db.Name())
+
+	db, err = NewTenantRouter(s, TenantRouterOptions{Mode: TenantCollectionPrefix, Database: "saas"}).DB("acme")
+	assert.NoError(t, err)
+	assert.Equal(t, "saas", db.Name())
+	db.C("users")
+	assert.Contains(t, db.(*tenantDatabase).IDatabase.(*namedDatabase).collections, "acme_users")
+	names, err := db.CollectionNames()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"users", "orders"}, names)
+
+	db, err = NewTenantRouter(s, TenantRouterOptions{Mode: TenantDiscriminator, Database: "saas"}).DB("acme")
+	assert.NoError(t, err)
+	assert.IsType(t, &tenantCollection{}, db.C("users"))
+
+	_, err = NewTenantRouter(s).DB("")
+	assert.True(t, errors.Is(err, ErrInvalidTenant))
+	_, err = NewTenantRouter(s).DB("../admin")
+	assert.True(t, errors.Is(err, ErrInvalidTenant))
+	_, err = NewTenantRouter(s, TenantRouterOptions{Mode: TenantDiscriminator}).DB("a.b")
+	assert.NoError(t, err)
+}
+
+func TestTenantRouter_PrefixSeparator(t *testing.T) {
+	s := &namedSession{collections: []string{"a_orders", "a_b_orders"}}
+	router := NewTenantRouter(s, TenantRouterOptions{Mode: TenantCollectionPrefix, Database: "saas"})
+
+	// The prefix 'a_' of tenant 'a' would match the collections of tenant 'a_b'.
+	_, err := router.DB("a_b")
+	assert.True(t, errors.Is(err, ErrInvalidTenant))
+	assert.EqualError(t, err, "invalid tenant: 'a_b' contains the separator '_'")
+
+	// Since 'a_b' cannot be a tenant, 'a_b_orders' is the collection 'b_orders' of tenant 'a'.
+	db, err := router.DB("a")
+	assert.NoError(t, err)
+	names, err := db.CollectionNames()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"orders", "b_orders"}, names)
+
+	_, err = NewTenantRouter(s, TenantRouterOptions{Mode: TenantCollectionPrefix, Separator: "-"}).DB("a_b")
+	assert.NoError(t, err)
+	_, err = NewTenantRouter(s).DB("a_b")
+	assert.NoError(t, err)
+}
+
+func TestTenantRouter_Clusters(t *testing.T) {
+	def := &namedSession{}
+	dials := 0
+	router := NewTenantRouter(def, TenantRouterOptions{
+		Cluster: func(tenant string) (string, error) {
+			if tenant == "big" {
+				return "mongodb://dedicated", nil
+			}
+			return "", nil
+		},
+		Dial: func(url string) (ISession, error) {
+			dials++
+			return &namedSession{url: url}, nil
+		},
+	})
+
+	s, err := router.Session("small")
+	assert.NoError(t, err)
+	assert.Equal(t, def, s)
+
+	s, err = router.Session("big")
+	assert.NoError(t, err)
+	assert.Equal(t, "mongodb://dedicated", s.(*namedSession).url)
+	again, _ := router.Session("big")
+	assert.Equal(t, s, again)
+	assert.Equal(t, 1, dials)
+
+	router.Close()
+	assert.True(t, s.(*namedSession).closed)
+	assert.False(t, def.closed)
+}
+
+func TestTenantCollection_Filters(t *testing.T) {
+	rec := &recorderCollection{}
+	col := NewTenantCollection(rec, "acme")
+
+	col.Find(bson.M{"name": "x"})
+	assert.Equal(t, bson.M{"name": "x", "tenant_id": "acme"}, rec.Last()[0])
+	col.FindId("a")
+	assert.Equal(t, bson.M{"_id": "a", "tenant_id": "acme"}, rec.Last()[0])
+	col.Find(bson.M{"tenant_id": "globex"})
+	assert.Equal(t, bson.M{"$and": []interface{}{bson.M{"tenant_id": "globex"}, bson.M{"tenant_id": "acme"}}}, rec.Last()[0])
+
+	col.Pipe([]bson.M{{"$group": bson.M{"_id": nil}}})
+	assert.Equal(t, []interface{}{bson.M{"$match": bson.M{"tenant_id": "acme"}}, bson.M{"$group": bson.M{"_id": nil}}}, rec.Last()[0])
+
+	assert.NoError(t, col.RemoveId("a"))
+	assert.Equal(t, bson.M{"_id": "a", "tenant_id": "acme"}, rec.Last()[0])
+
+	assert.NoError(t, col.Update(bson.M{"_id": "a"}, bson.M{"$set": bson.M{"name": "y"}}))
+	assert.Equal(t, []interface{}{bson.M{"_id": "a", "tenant_id": "acme"}, bson.M{"$set": bson.M{"name": "y"}}}, rec.Last())
+
+	err := col.Update(bson.M{"_id": "a"}, bson.M{"$set": bson.M{"tenant_id": "globex"}})
+	assert.True(t, errors.Is(err, ErrInvalidTenant))
+	err = col.Update(bson.M{"_id": "a"}, bson.M{"$unset": bson.M{"tenant_id": ""}})
+	assert.True(t, errors.Is(err, ErrInvalidTenant))
+
+	err = col.Update(bson.M{"_id": "a"}, bson.M{"$rename": bson.M{"owner": "tenant_id"}})
+	assert.True(t, errors.Is(err, ErrInvalidTenant))
+
+	_, err = col.Find(nil).Apply(Change{Update: bson.M{"$set": bson.M{"tenant_id": "globex"}}}, nil)
+	assert.True(t, errors.Is(err, ErrInvalidTenant))
+}
+
+func TestTenantCollection_PipeStages(t *testing.T) {
+	rec := &recorderCollection{}
+	col := NewTenantCollection(rec, "acme")
+
+	for _, stage := range []bson.M{
+		{"$lookup": bson.M{"from": "orders", "localField": "_id", "foreignField": "user", "as": "orders"}},
+		{"$graphLookup": bson.M{"from": "users"}},
+		{"$unionWith": "orders"},
+		{"$out": "report"},
+		{"$merge": bson.M{"into": "report"}},
+		{"$facet": bson.M{"orders": []interface{}{bson.M{"$lookup": bson.M{"from": "orders"}}}}},
+	} {
+		p := col.Pipe([]bson.M{{"$match": bson.M{"name": "x"}}, stage})
+		assert.True(t, errors.Is(p.All(nil), ErrTenantUnsupported), "%v", stage)
+		assert.True(t, errors.Is(p.Iter().Err(), ErrTenantUnsupported), "%v", stage)
+	}
+	assert.Empty(t, rec.Names())
+
+	col.Pipe([]interface{}{bson.D{{Name: "$facet", Value: bson.M{"count": []bson.M{{"$count": "n"}}}}}})
+	assert.Equal(t, []string{"Pipe"}, rec.Names())
+}
+
+func TestTenantCollection_Insert(t *testing.T) {
+	rec := &recorderCollection{}
+	col := NewTenantCollection(rec, "acme")
+
+	model := &tenantModel{Id: "a"}
+	assert.NoError(t, col.Insert(model, bson.M{"_id": "b"}))
+	assert.Equal(t, "acme", model.TenantId)
+	assert.Equal(t, bson.M{"_id": "b", "tenant_id": "acme"}, rec.Last()[1])
+
+	err := col.Insert(&tenantModel{Id: "c", TenantId: "globex"})
+	assert.True(t, errors.Is(err, ErrInvalidTenant))
+	err = col.Insert(bson.M{"_id": "c", "tenant_id": "globex"})
+	assert.True(t, errors.Is(err, ErrInvalidTenant))
+	assert.Len(t, rec.Names(), 1)
+
+	replacement := &tenantModel{Id: "a"}
+	assert.NoError(t, col.Update(bson.M{"_id": "a"}, replacement))
+	assert.Equal(t, "acme", replacement.TenantId)
+}
+
+func TestTenantCollection_Bulk(t *testing.T) {
+	col := NewTenantCollection(&recorderCollection{}, "acme")
+
+	_, err := col.Bulk().Insert(bson.M{"tenant_id": "globex"}).Run()
+	assert.True(t, errors.Is(err, ErrInvalidTenant))
+
+	_, err = col.Bulk().Update(bson.M{"_id": "a"}).Run()
+	assert.EqualError(t, err, "bulk update requires an even number of parameters")
+
+	_, err = col.BulkUpsert(bson.M{"_id": "a"}, bson.M{"tenant_id": "globex"})
+	assert.True(t, errors.Is(err, ErrInvalidTenant))
+}
+
+func TestTenantRouter_SharedOperations(t *testing.T) {
+	s := &namedSession{}
+
+	db, _ := NewTenantRouter(s, TenantRouterOptions{Mode: TenantDiscriminator, Database: "saas"}).DB("acme")
+	assert.True(t, errors.Is(db.DropDatabase(), ErrTenantUnsupported))
+	assert.True(t, errors.Is(db.Run("dropDatabase", nil), ErrTenantUnsupported))
+	assert.True(t, errors.Is(db.C("users").DropCollection(), ErrTenantUnsupported))
+	users := db.C("users")
+	assert.True(t, errors.Is(users.Create(&CollectionInfo{}), ErrTenantUnsupported))
+	assert.True(t, errors.Is(users.EnsureIndex(Index{Key: []string{"email"}}), ErrTenantUnsupported))
+	assert.True(t, errors.Is(users.EnsureIndexKey("email"), ErrTenantUnsupported))
+	assert.EqualError(t, users.DropIndex("email"), "the operation cannot be restricted to a tenant: DropIndex of the shared collection 'records'")
+	assert.True(t, errors.Is(users.DropIndexName("email_1"), ErrTenantUnsupported))
+	assert.Panics(t, func() { db.MustEnsureIndex(Index{Key: []string{"email"}}, "users") })
+	assert.Empty(t, db.(*tenantDatabase).IDatabase.(*namedDatabase).collections["users"].Names())
+	assert.True(t, errors.Is(db.C("users").Database().DropDatabase(), ErrTenantUnsupported))
+	assert.False(t, db.(*tenantDatabase).IDatabase.(*namedDatabase).dropped)
+
+	db, _ = NewTenantRouter(s, TenantRouterOptions{Mode: TenantCollectionPrefix, Database: "saas"}).DB("acme")
+	inner := db.(*tenantDatabase).IDatabase.(*namedDatabase)
+	assert.True(t, errors.Is(db.Run("dropDatabase", nil), ErrTenantUnsupported))
+	assert.True(t, errors.Is(db.C("users").Database().Run("dropDatabase", nil), ErrTenantUnsupported))
+	assert.NoError(t, db.DropDatabase())
+	assert.False(t, inner.dropped)
+	assert.Equal(t, []string{"DropCollection"}, inner.collections["acme_users"].Names())
+	assert.Equal(t, []string{"DropCollection"}, inner.collections["acme_orders"].Names())
+	assert.NotContains(t, inner.collections, "globex_users")
+
+	// Each tenant has its own database, it can be dropped.
+	db, _ = NewTenantRouter(s, TenantRouterOptions{DatabasePrefix: "tenant_"}).DB("acme")
+	assert.NoError(t, db.DropDatabase())
+	assert.True(t, db.(*namedDatabase).dropped)
+}
+
+func TestTenantRouter_ConcurrentDials(t *testing.T) {
+	var dials int32
+	slow := make(chan struct{})
+	router := NewTenantRouter(&namedSession{}, TenantRouterOptions{
+		Cluster: func(tenant string) (string, error) {
+			return "mongodb://" + tenant, nil
+		},
+		Dial: func(url string) (ISession, error) {
+			atomic.AddInt32(&dials, 1)
+			if url == "mongodb://slow" {
+				<-slow
+			}
+			return &namedSession{url: url}, nil
+		},
+	})
+
+	var wg sync.WaitGroup
+	sessions := make([]ISession, 3)
+	for i := range sessions {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sessions[i], _ = router.Session("slow")
+		}(i)
+	}
+
+	// The slow cluster does not block the sessions of the other clusters.
+	done := make(chan ISession)
+	go func() {
+		s, _ := router.Session("fast")
+		done <- s
+	}()
+	select {
+	case s := <-done:
+		assert.Equal(t, "mongodb://fast", s.(*namedSession).url)
+	case <-time.After(time.Second):
+		t.Fatal("the dial of a cluster blocked the other clusters")
+	}
+
+	close(slow)
+	wg.Wait()
+	assert.Equal(t, sessions[0], sessions[1])
+	assert.Equal(t, sessions[0], sessions[2])
+	assert.Equal(t, int32(2), atomic.LoadInt32(&dials))
+}
+
+func TestTenantRouter_FailedDialsAreRetried(t *testing.T) {
+	fail := true
+	router := NewTenantRouter(&namedSession{}, TenantRouterOptions{
+		Cluster: func(string) (string, error) { return "mongodb://dedicated", nil },
+		Dial: func(url string) (ISession, error) {
+			if fail {
+				return nil, errors.New("no reachable servers")
+			}
+			return &namedSession{url: url}, nil
+		},
+	})
+
+	_, err := router.Session("acme")
+	assert.EqualError(t, err, "no reachable servers")
+	fail = false
+	s, err := router.Session("acme")
+	assert.NoError(t, err)
+	assert.Equal(t, "mongodb://dedicated", s.(*namedSession).url)
+}
@@ -0,0 +1,285 @@
+package mgo
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jucardi/go-mongodb-lib/log"
+	"gopkg.in/mgo.v2"
+)
+
+// TlsConfig defines the TLS configuration used to connect to the servers. The certificates may be provided as PEM
+// files or bytes, files take precedence. Files are reloaded when they change, so rotated certificates are used by the
+// new connections without restarting.
+type TlsConfig struct {
+	// CAFile is the path of the PEM encoded root CA certificates used to verify the servers. If neither CAFile nor CA
+	// are set, the system roots are used.
+	CAFile string
+	// CA contains the PEM encoded root CA certificates used to verify the servers.
+	CA []byte
+	// CertFile is the path of the PEM encoded client certificate, used for mutual TLS. If KeyFile is not set, the
+	// private key is read from the same file, as in the 'tlsCertificateKeyFile' URL option.
+	CertFile string
+	// KeyFile is the path of the PEM encoded private key of the client certificate.
+	KeyFile string
+	// Cert contains the PEM encoded client certificate, used for mutual TLS. If Key is not set, the private key is read
+	// from the same bytes.
+	Cert []byte
+	// Key contains the PEM encoded private key of the client certificate.
+	Key []byte
+	// ServerName overrides the name used to verify the server certificates, which defaults to the host being dialed.
+	ServerName string
+	// MinVersion is the minimum TLS version accepted, eg: tls.VersionTLS12. Defaults to the crypto/tls default.
+	MinVersion uint16
+	// CipherSuites is the list of enabled cipher suites for TLS 1.2 and below. Defaults to the crypto/tls default.
+	CipherSuites []uint16
+	// AllowInvalidHostnames disables the verification of the server host name, while still verifying the certificate
+	// chain.
+	AllowInvalidHostnames bool
+	// InsecureSkipVerify disables the verification of the server certificates. In this mode, TLS is susceptible to
+	// man-in-the-middle attacks. This should be used only for testing.
+	InsecureSkipVerify bool
+}
+
+// Config builds the tls.Config, reading the certificate files.
+func (c *TlsConfig) Config() (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName:         c.ServerName,
+		MinVersion:         c.MinVersion,
+		CipherSuites:       c.CipherSuites,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+
+	ca, err := readPEM(c.CAFile, c.CA)
+	if err != nil {
+		return nil, err
+	}
+	if len(ca) > 0 {
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, errors.New("no valid CA certificates found")
+		}
+	}
+
+	cert, err := readPEM(c.CertFile, c.Cert)
+	if err != nil {
+		return nil, err
+	}
+	if len(cert) > 0 {
+		key, err := readPEM(c.KeyFile, c.Key)
+		if err != nil {
+			return nil, err
+		}
+		if len(key) == 0 {
+			key = cert
+		}
+		pair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %v", err)
+		}
+		cfg.Certificates = []tls.Certificate{pair}
+	}
+
+	if c.AllowInvalidHostnames && !c.InsecureSkipVerify {
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyPeerCertificate = verifyChain(cfg.RootCAs)
+	}
+
+	return cfg, nil
+}
+
+// Apply sets the DialServer function of the provided DialInfo to connect using TLS with this configuration. Returns
+// an error if the configuration is not valid.
+func (c *TlsConfig) Apply(info *mgo.DialInfo) error {
+	loader := &tlsLoader{cfg: *c}
+	if _, err := loader.config(); err != nil {
+		return err
+	}
+
+	info.DialServer = func(addr *mgo.ServerAddr) (net.Conn, error) {
+		cfg, err := loader.config()
+		if err != nil {
+			return nil, err
+		}
+		dialer := &net.Dialer{Timeout: info.Timeout}
+		conn, err := tls.DialWithDialer(dialer, "tcp", addr.String(), cfg)
+		if err != nil {
+			log.With(log.F("addr", addr.String()), log.F("error", err.Error())).Error("An error has occurred while dialing with TLS")
+		}
+		return conn, err
+	}
+	return nil
+}
+
+// tlsLoader caches the tls.Config built from a TlsConfig, rebuilding it when the certificate files change.
+type tlsLoader struct {
+	cfg     TlsConfig
+	mux     sync.Mutex
+	current *tls.Config
+	modTime map[string]time.Time
+}
+
+func (l *tlsLoader) config() (*tls.Config, error) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	modTime := map[string]time.Time{}
+	for _, file := range []string{l.cfg.CAFile, l.cfg.CertFile, l.cfg.KeyFile} {
+		if file == "" {
+			continue
+		}
+		if stat, err := os.Stat(file); err == nil {
+			modTime[file] = stat.ModTime()
+		}
+	}
+
+	if l.current != nil && sameModTimes(l.modTime, modTime) {
+		return l.current, nil
+	}
+
+	cfg, err := l.cfg.Config()
+	if err != nil {
+		if l.current != nil {
+			// Keeps using the previous certificates, the files may be in the middle of being replaced.
+			log.With(log.F("error", err.Error())).Error("Unable to reload the TLS certificates")
+			return l.current, nil
+		}
+		return nil, err
+	}
+
+	if l.current != nil {
+		log.Get().Info("TLS certificates reloaded")
+	}
+	l.current, l.modTime = cfg, modTime
+	return cfg, nil
+}
+
+func sameModTimes(a, b map[string]time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if !b[k].Equal(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func readPEM(file string, data []byte) ([]byte, error) {
+	if file == "" {
+		return data, nil
+	}
+	return os.ReadFile(file)
+}
+
+// verifyChain returns a function that verifies the certificate chain presented by the server without verifying the
+// host name.
+func verifyChain(roots *x509.CertPool) func([][]byte, [][]*x509.Certificate) error {
+	return func(raw [][]byte, _ [][]*x509.Certificate) error {
+		if len(raw) == 0 {
+			return errors.New("the server did not present any certificate")
+		}
+		certs := make([]*x509.Certificate, len(raw))
+		for i, data := range raw {
+			cert, err := x509.ParseCertificate(data)
+			if err != nil {
+				return err
+			}
+			certs[i] = cert
+		}
+		opts := x509.VerifyOptions{Roots: roots, Intermediates: x509.NewCertPool()}
+		for _, cert := range certs[1:] {
+			opts.Intermediates.AddCert(cert)
+		}
+		_, err := certs[0].Verify(opts)
+		return err
+	}
+}
+
+// tlsURLOptions are the connection string options handled by parseTlsURL, which are not supported by the driver.
+var tlsURLOptions = map[string]bool{
+	"tls":                         true,
+	"ssl":                         true,
+	"tlscafile":                   true,
+	"tlscertificatekeyfile":       true,
+	"tlsinsecure":                 true,
+	"tlsallowinvalidcertificates": true,
+	"tlsallowinvalidhostnames":    true,
+}
+
+// parseTlsURL removes the TLS options from the provided connection string and returns the TLS configuration defined
+// by them, or nil if TLS is not enabled. Supports the 'tls', 'ssl', 'tlsCAFile', 'tlsCertificateKeyFile',
+// 'tlsInsecure', 'tlsAllowInvalidCertificates' and 'tlsAllowInvalidHostnames' options. The certificate options enable
+// TLS, unless it is explicitly disabled with 'tls=false' or 'ssl=false', regardless of the order of the options.
+func parseTlsURL(connStr string) (string, *TlsConfig, error) {
+	i := strings.Index(connStr, "?")
+	if i < 0 {
+		return connStr, nil, nil
+	}
+
+	var (
+		cfg      = &TlsConfig{}
+		enabled  = false
+		explicit *bool
+		kept     []string
+	)
+
+	for _, opt := range strings.FieldsFunc(connStr[i+1:], func(r rune) bool { return r == '&' || r == ';' }) {
+		k, v := opt, ""
+		if j := strings.Index(opt, "="); j >= 0 {
+			k, v = opt[:j], opt[j+1:]
+		}
+		if !tlsURLOptions[strings.ToLower(k)] {
+			kept = append(kept, opt)
+			continue
+		}
+
+		value, err := url.QueryUnescape(v)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid connection URL option %s: %v", k, err)
+		}
+
+		switch strings.ToLower(k) {
+		case "tls", "ssl":
+			var b bool
+			b, err = strconv.ParseBool(value)
+			explicit = &b
+		case "tlscafile":
+			cfg.CAFile, enabled = value, true
+		case "tlscertificatekeyfile":
+			cfg.CertFile, enabled = value, true
+		case "tlsinsecure", "tlsallowinvalidcertificates":
+			cfg.InsecureSkipVerify, err = strconv.ParseBool(value)
+		case "tlsallowinvalidhostnames":
+			cfg.AllowInvalidHostnames, err = strconv.ParseBool(value)
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid connection URL option %s=%s", k, v)
+		}
+	}
+
+	connStr = connStr[:i]
+	if len(kept) > 0 {
+		connStr += "?" + strings.Join(kept, "&")
+	}
+	if explicit != nil {
+		if !*explicit && enabled {
+			log.Get().Warn("TLS is disabled in the connection URL, ignoring its TLS certificate options")
+		}
+		enabled = *explicit
+	}
+	if !enabled {
+		return connStr, nil, nil
+	}
+	return connStr, cfg, nil
+}
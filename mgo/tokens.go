@@ -0,0 +1,17 @@
+package mgo
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+)
+
+// NewToken returns a random 128 bit token encoded as hex, used to identify an owner in the conditional updates of a
+// document, eg: the holder of a Lock.
+func NewToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("unable to generate the token: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
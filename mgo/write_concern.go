@@ -0,0 +1,241 @@
+package mgo
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/mgo.v2"
+)
+
+var (
+	// WriteFireAndForget does not wait for the writes to be acknowledged, so their errors are not reported.
+	WriteFireAndForget = WriteConcern{Unacknowledged: true}
+	// WriteAcknowledged waits for the writes to be acknowledged by the primary. This is the default safety mode.
+	WriteAcknowledged = WriteConcern{}
+	// WriteMajority waits for the writes to be acknowledged by the majority of the replica set members.
+	WriteMajority = WriteConcern{WMode: "majority"}
+	// WriteJournaled waits for the writes to be committed to the journal of the primary.
+	WriteJournaled = WriteConcern{J: true}
+
+	writeConcernPresets = map[string]WriteConcern{
+		"fireandforget": WriteFireAndForget,
+		"acknowledged":  WriteAcknowledged,
+		"majority":      WriteMajority,
+		"journaled":     WriteJournaled,
+	}
+)
+
+// WriteConcern is the level of acknowledgement requested for the write operations. See the Safe documentation in
+// `gopkg.in/mgo.v2` for more information.
+type WriteConcern struct {
+	// Unacknowledged disables the acknowledgement of the writes (fire and forget). The rest of the fields are ignored.
+	Unacknowledged bool
+	// W is the amount of servers that must acknowledge the writes.
+	W int
+	// WMode is the write mode, such as "majority" or a tag set name. Takes precedence over W.
+	WMode string
+	// WTimeout is the time to wait for the acknowledgements, zero waits forever.
+	WTimeout time.Duration
+	// J waits for the writes to be committed to the journal.
+	J bool
+	// FSync waits for the writes to be flushed to disk.
+	FSync bool
+}
+
+// WriteConcernPreset returns the write concern with the given name: "fireAndForget", "acknowledged", "majority" or
+// "journaled". The names are case insensitive.
+func WriteConcernPreset(name string) (WriteConcern, error) {
+	wc, ok := writeConcernPresets[strings.ToLower(name)]
+	if !ok {
+		return WriteConcern{}, fmt.Errorf("unknown write concern preset '%s', expected fireAndForget, acknowledged, majority or journaled", name)
+	}
+	return wc, nil
+}
+
+// Safe returns the safety mode to use with SetSafe, nil if the writes are unacknowledged.
+func (w WriteConcern) Safe() *mgo.Safe {
+	if w.Unacknowledged {
+		return nil
+	}
+	return &mgo.Safe{
+		W:        w.W,
+		WMode:    w.WMode,
+		WTimeout: int(w.WTimeout / time.Millisecond),
+		J:        w.J,
+		FSync:    w.FSync,
+	}
+}
+
+// NewWriteConcernCollection decorates the provided collection so its write operations use the given write concern,
+// regardless of the safety mode of the session. Every write runs in a clone of the session of the collection with the
+// write concern applied, which is closed once the operation completes. It can be kept to set the write concern of a
+// collection, or used for a single call:
+//
+//   Example:
+//
+//      events := mgo.NewWriteConcernCollection(db.C("events"), mgo.WriteFireAndForget)
+//      events.Insert(event)
+//
+//      err := mgo.NewWriteConcernCollection(db.C("payments"), mgo.WriteMajority).Insert(payment)
+//
+// Insert, Update, UpdateId, UpdateAll, Upsert, UpsertId, Remove, RemoveId, RemoveAll and Bulk().Run are affected.
+// Queries, including Apply, use the session of the collection.
+//
+//   {col} - The collection to decorate
+//   {wc}  - The write concern of the write operations
+//
+func NewWriteConcernCollection(col ICollection, wc WriteConcern) ICollection {
+	return &writeConcernCollection{ICollection: col, wc: wc}
+}
+
+type writeConcernCollection struct {
+	ICollection
+	wc WriteConcern
+}
+
+func (c *writeConcernCollection) With(s ISession) ICollection {
+	return &writeConcernCollection{ICollection: c.ICollection.With(s), wc: c.wc}
+}
+
+func (c *writeConcernCollection) Insert(docs ...interface{}) error {
+	return c.run(func(col ICollection) error {
+		return col.Insert(docs...)
+	})
+}
+
+func (c *writeConcernCollection) Update(selector interface{}, update interface{}) error {
+	return c.run(func(col ICollection) error {
+		return col.Update(selector, update)
+	})
+}
+
+func (c *writeConcernCollection) UpdateId(id interface{}, update interface{}) error {
+	return c.run(func(col ICollection) error {
+		return col.UpdateId(id, update)
+	})
+}
+
+func (c *writeConcernCollection) UpdateAll(selector interface{}, update interface{}) (info *ChangeInfo, err error) {
+	err = c.run(func(col ICollection) error {
+		info, err = col.UpdateAll(selector, update)
+		return err
+	})
+	return
+}
+
+func (c *writeConcernCollection) Upsert(selector interface{}, update interface{}) (info *ChangeInfo, err error) {
+	err = c.run(func(col ICollection) error {
+		info, err = col.Upsert(selector, update)
+		return err
+	})
+	return
+}
+
+func (c *writeConcernCollection) UpsertId(id interface{}, update interface{}) (info *ChangeInfo, err error) {
+	err = c.run(func(col ICollection) error {
+		info, err = col.UpsertId(id, update)
+		return err
+	})
+	return
+}
+
+func (c *writeConcernCollection) Remove(selector interface{}) error {
+	return c.run(func(col ICollection) error {
+		return col.Remove(selector)
+	})
+}
+
+func (c *writeConcernCollection) RemoveId(id interface{}) error {
+	return c.run(func(col ICollection) error {
+		return col.RemoveId(id)
+	})
+}
+
+func (c *writeConcernCollection) RemoveAll(selector interface{}) (info *ChangeInfo, err error) {
+	err = c.run(func(col ICollection) error {
+		info, err = col.RemoveAll(selector)
+		return err
+	})
+	return
+}
+
+func (c *writeConcernCollection) Bulk() IBulk {
+	return &writeConcernBulk{col: c}
+}
+
+func (c *writeConcernCollection) BulkUpsert(pairs ...interface{}) (*BulkResult, error) {
+	return c.Bulk().Upsert(pairs...).Run()
+}
+
+func (c *writeConcernCollection) UpdateIfVersion(selector interface{}, version int64, update interface{}) error {
+	return c.run(func(col ICollection) error {
+		return col.UpdateIfVersion(selector, version, update)
+	})
+}
+
+func (c *writeConcernCollection) ReplaceIfVersion(selector interface{}, version int64, doc interface{}) error {
+	return c.run(func(col ICollection) error {
+		return col.ReplaceIfVersion(selector, version, doc)
+	})
+}
+
+// run invokes f with the collection bound to a clone of its session which uses the write concern.
+func (c *writeConcernCollection) run(f func(col ICollection) error) error {
+	s := c.ICollection.Database().Session().Clone()
+	defer s.Close()
+	s.SetSafe(c.wc.Safe())
+	return f(c.ICollection.With(s))
+}
+
+// writeConcernBulk queues the operations and replays them on Run in a bulk of the collection bound to the cloned
+// session, since the bulks are bound to the session of the collection that created them.
+type writeConcernBulk struct {
+	col *writeConcernCollection
+	ops []func(IBulk) IBulk
+}
+
+func (b *writeConcernBulk) Unordered() IBulk {
+	return b.add(func(bulk IBulk) IBulk { return bulk.Unordered() })
+}
+
+func (b *writeConcernBulk) Insert(docs ...interface{}) IBulk {
+	return b.add(func(bulk IBulk) IBulk { return bulk.Insert(docs...) })
+}
+
+func (b *writeConcernBulk) Remove(selectors ...interface{}) IBulk {
+	return b.add(func(bulk IBulk) IBulk { return bulk.Remove(selectors...) })
+}
+
+func (b *writeConcernBulk) RemoveAll(selectors ...interface{}) IBulk {
+	return b.add(func(bulk IBulk) IBulk { return bulk.RemoveAll(selectors...) })
+}
+
+func (b *writeConcernBulk) Update(pairs ...interface{}) IBulk {
+	return b.add(func(bulk IBulk) IBulk { return bulk.Update(pairs...) })
+}
+
+func (b *writeConcernBulk) UpdateAll(pairs ...interface{}) IBulk {
+	return b.add(func(bulk IBulk) IBulk { return bulk.UpdateAll(pairs...) })
+}
+
+func (b *writeConcernBulk) Upsert(pairs ...interface{}) IBulk {
+	return b.add(func(bulk IBulk) IBulk { return bulk.Upsert(pairs...) })
+}
+
+func (b *writeConcernBulk) Run() (result *BulkResult, err error) {
+	err = b.col.run(func(col ICollection) error {
+		bulk := col.Bulk()
+		for _, op := range b.ops {
+			bulk = op(bulk)
+		}
+		result, err = bulk.Run()
+		return err
+	})
+	return
+}
+
+func (b *writeConcernBulk) add(op func(IBulk) IBulk) IBulk {
+	b.ops = append(b.ops, op)
+	return b
+}
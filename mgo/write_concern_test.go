@@ -0,0 +1,143 @@
+package mgo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type safeSession struct {
+	ISession
+	safe   *mgo.Safe
+	clones []*safeSession
+	closed bool
+}
+
+func (s *safeSession) Clone() ISession {
+	c := &safeSession{safe: s.safe}
+	s.clones = append(s.clones, c)
+	return c
+}
+
+func (s *safeSession) SetSafe(safe *mgo.Safe) {
+	s.safe = safe
+}
+
+func (s *safeSession) Close() {
+	s.closed = true
+}
+
+type safeDatabase struct {
+	IDatabase
+	session *safeSession
+}
+
+func (d *safeDatabase) Session() ISession {
+	return d.session
+}
+
+type safeCollection struct {
+	ICollection
+	session *safeSession
+	writes  *[]*mgo.Safe
+}
+
+func (c *safeCollection) Database() IDatabase {
+	return &safeDatabase{session: c.session}
+}
+
+func (c *safeCollection) With(s ISession) ICollection {
+	return &safeCollection{session: s.(*safeSession), writes: c.writes}
+}
+
+func (c *safeCollection) record() {
+	*c.writes = append(*c.writes, c.session.safe)
+}
+
+func (c *safeCollection) Insert(...interface{}) error {
+	c.record()
+	return nil
+}
+
+func (c *safeCollection) UpdateAll(interface{}, interface{}) (*ChangeInfo, error) {
+	c.record()
+	return &ChangeInfo{Updated: 1}, nil
+}
+
+func (c *safeCollection) Bulk() IBulk {
+	return &safeBulk{col: c}
+}
+
+type safeBulk struct {
+	IBulk
+	col       *safeCollection
+	unordered bool
+	upserts   int
+}
+
+func (b *safeBulk) Unordered() IBulk {
+	b.unordered = true
+	return b
+}
+
+func (b *safeBulk) Upsert(pairs ...interface{}) IBulk {
+	b.upserts += len(pairs) / 2
+	return b
+}
+
+func (b *safeBulk) Run() (*BulkResult, error) {
+	b.col.record()
+	return &BulkResult{Matched: b.upserts}, nil
+}
+
+func TestWriteConcernCollection(t *testing.T) {
+	s := &safeSession{safe: &mgo.Safe{}}
+	writes := []*mgo.Safe{}
+	col := &safeCollection{session: s, writes: &writes}
+
+	assert.NoError(t, NewWriteConcernCollection(col, WriteFireAndForget).Insert(bson.M{}))
+	info, err := NewWriteConcernCollection(col, WriteConcern{W: 2, WTimeout: time.Second, J: true}).UpdateAll(nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, info.Updated)
+	assert.NoError(t, col.Insert(bson.M{}))
+
+	assert.Equal(t, []*mgo.Safe{nil, {W: 2, WTimeout: 1000, J: true}, {}}, writes)
+	assert.Len(t, s.clones, 2)
+	for _, c := range s.clones {
+		assert.True(t, c.closed)
+	}
+	assert.Equal(t, &mgo.Safe{}, s.safe)
+}
+
+func TestWriteConcernCollection_Bulk(t *testing.T) {
+	s := &safeSession{safe: &mgo.Safe{}}
+	writes := []*mgo.Safe{}
+	col := NewWriteConcernCollection(&safeCollection{session: s, writes: &writes}, WriteMajority)
+
+	result, err := col.Bulk().Unordered().Upsert(bson.M{"_id": 1}, bson.M{}, bson.M{"_id": 2}, bson.M{}).Run()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Matched)
+	assert.Equal(t, []*mgo.Safe{{WMode: "majority"}}, writes)
+	assert.True(t, s.clones[0].closed)
+}
+
+func TestWriteConcernPreset(t *testing.T) {
+	wc, err := WriteConcernPreset("fireAndForget")
+	assert.NoError(t, err)
+	assert.Nil(t, wc.Safe())
+
+	wc, err = WriteConcernPreset("Journaled")
+	assert.NoError(t, err)
+	assert.Equal(t, &mgo.Safe{J: true}, wc.Safe())
+
+	_, err = WriteConcernPreset("eventually")
+	assert.Error(t, err)
+
+	cfg := &Config{URL: "mongodb://localhost", WriteConcern: &WriteConcernConfig{Preset: "majority", Timeout: Duration(time.Second)}}
+	assert.Equal(t, &mgo.Safe{WMode: "majority", WTimeout: 1000}, cfg.Safe())
+	cfg.WriteConcern.Preset = "sometimes"
+	assert.EqualError(t, cfg.Validate(), "invalid mongo configuration: unknown write concern preset 'sometimes', expected fireAndForget, acknowledged, majority or journaled")
+}
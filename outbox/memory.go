@@ -0,0 +1,43 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryPublisher is a Publisher that keeps the published events in memory, intended for tests.
+type MemoryPublisher struct {
+	mux    sync.Mutex
+	events []*Event
+	err    error
+}
+
+// NewMemoryPublisher creates a new empty MemoryPublisher.
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{}
+}
+
+// Publish implements Publisher, failing with the error set by FailWith, if any.
+func (p *MemoryPublisher) Publish(_ context.Context, event *Event) error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	p.events = append(p.events, event)
+	return nil
+}
+
+// FailWith makes Publish fail with the provided error, or succeed again if nil.
+func (p *MemoryPublisher) FailWith(err error) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.err = err
+}
+
+// Events returns the events published so far, in publish order.
+func (p *MemoryPublisher) Events() []*Event {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	return append([]*Event{}, p.events...)
+}
@@ -0,0 +1,417 @@
+// Package outbox implements the transactional outbox pattern: the domain events are stored in an outbox collection
+// along with the business writes, and a relay publishes them in order with at-least-once semantics.
+//
+// Write stages the events in the outbox before the business write and commits them after it succeeds, so they are never
+// lost nor published for writes that failed. See Outbox.
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jucardi/go-mongodb-lib/log"
+	"github.com/jucardi/go-mongodb-lib/mgo"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// DefaultCollection is the default name of the outbox collection.
+const DefaultCollection = "_outbox"
+
+const (
+	// DefaultBatchSize is the default maximum amount of events dispatched per poll.
+	DefaultBatchSize = 100
+	// DefaultPollInterval is the default time the relay waits before polling again when there are no pending events.
+	DefaultPollInterval = time.Second
+	// DefaultRetention is the default time the dispatched events are kept before being purged.
+	DefaultRetention = 7 * 24 * time.Hour
+	// DefaultPurgeInterval is the default time between the purges performed by the relay.
+	DefaultPurgeInterval = time.Hour
+	// DefaultMaxAttempts is the default amount of failed publish attempts after which an event is parked.
+	DefaultMaxAttempts = 10
+	// DefaultStageTimeout is the default time a staged event waits for its write to be committed before being parked.
+	DefaultStageTimeout = time.Minute
+)
+
+// ErrNotCommitted is the error the events are parked with when their write was not committed within the stage timeout,
+// see Outbox.
+var ErrNotCommitted = errors.New("the write of the event was not committed within the stage timeout")
+
+// Message is a domain event to store in the outbox.
+type Message struct {
+	// Topic is where the event is published, eg: "orders.created".
+	Topic string
+	// Key identifies the entity the event refers to, eg: the order ID, so publishers can partition by it.
+	Key string
+	// Payload is the content of the event, any value that can be marshalled with bson.
+	Payload interface{}
+}
+
+// Event is a message stored in the outbox.
+type Event struct {
+	Id           bson.ObjectId `bson:"_id"`
+	Seq          int64         `bson:"seq,omitempty"`
+	Topic        string        `bson:"topic"`
+	Key          string        `bson:"key,omitempty"`
+	Payload      bson.Raw      `bson:"payload"`
+	CreatedAt    time.Time     `bson:"created_at"`
+	StagedAt     *time.Time    `bson:"staged_at,omitempty"`
+	DispatchedAt *time.Time    `bson:"dispatched_at,omitempty"`
+	ParkedAt     *time.Time    `bson:"parked_at,omitempty"`
+	Attempts     int           `bson:"attempts"`
+	LastError    string        `bson:"last_error,omitempty"`
+}
+
+// Decode unmarshals the payload of the event into the provided value.
+func (e *Event) Decode(out interface{}) error {
+	return e.Payload.Unmarshal(out)
+}
+
+// Publisher publishes the events of the outbox, eg: to a message broker. An event is marked as dispatched only after
+// Publish succeeds, so it may be published more than once if the relay stops in between.
+type Publisher interface {
+	Publish(ctx context.Context, event *Event) error
+}
+
+// PublisherFunc is an adapter to use a function as a Publisher.
+type PublisherFunc func(ctx context.Context, event *Event) error
+
+// Publish implements Publisher
+func (f PublisherFunc) Publish(ctx context.Context, event *Event) error {
+	return f(ctx, event)
+}
+
+// Options defines the behavior of the outbox and its relay.
+type Options struct {
+	// BatchSize is the maximum amount of events dispatched per poll. Defaults to DefaultBatchSize.
+	BatchSize int
+	// PollInterval is the time the relay waits before polling again when there are no pending events or publishing
+	// fails. Defaults to DefaultPollInterval.
+	PollInterval time.Duration
+	// Retention is the time the dispatched events are kept before being purged. Defaults to DefaultRetention.
+	Retention time.Duration
+	// PurgeInterval is the time between the purges performed by the relay. Defaults to DefaultPurgeInterval.
+	PurgeInterval time.Duration
+	// MaxAttempts is the amount of failed publish attempts after which an event is parked: it is no longer dispatched,
+	// so it does not block the following events, until it is requeued with Requeue. Defaults to DefaultMaxAttempts, a
+	// negative value never parks the events.
+	MaxAttempts int
+	// StageTimeout is the time a staged event waits for its write to be committed before being parked with
+	// ErrNotCommitted, see Outbox. Defaults to DefaultStageTimeout.
+	StageTimeout time.Duration
+	// OnParked is invoked every time an event is parked, with the error of its last attempt.
+	OnParked func(event *Event, err error)
+	// Sequences allocates the sequence value of each event, named after the full name of the outbox collection, which
+	// the relay uses to dispatch the events of all the producers in a single order. A block size of 1 must be used so the
+	// values are allocated in the order the events are written. If not set, the events are dispatched by _id.
+	Sequences *mgo.Sequences
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// Outbox stores the domain events in a collection and relays them to a publisher.
+//
+// Since the mgo driver does not support multi-document transactions, Write inserts the events in a staged state before
+// the business write, and commits them once it succeeds or removes them if it fails. The relay does not dispatch the
+// staged events, nor the ones that follow them so the order is preserved, until they are committed. If the process
+// stops before committing, the events are parked with ErrNotCommitted once the stage timeout expires, so they are not
+// lost and can be requeued with Requeue once the business write is confirmed. Only one relay should run per outbox
+// collection, otherwise the events may be published out of order or more than once; use a mgo.LockManager to elect it
+// when running several instances.
+//
+// The events are dispatched by _id, and the ObjectIds generated by different processes are not ordered by their insertion
+// time, so the order is only guaranteed among the events written by the same producer. Set the Sequences option to
+// order the events of all the producers by a shared counter instead. Even then, an event which insert is delayed after
+// its sequence value was allocated may be dispatched after events with greater values.
+//
+//   Example:
+//
+//      ob, err := outbox.New(db.C(outbox.DefaultCollection))
+//      if err != nil {
+//          return err
+//      }
+//      err = ob.Write(func() error {
+//          return db.C("orders").Insert(order)
+//      }, outbox.Message{Topic: "orders.created", Key: order.Id, Payload: order})
+//
+//      go ob.Relay(ctx, publisher)
+//
+type Outbox struct {
+	col       mgo.ICollection
+	opts      Options
+	sequence  string
+	lastPurge time.Time
+}
+
+// New creates a new outbox stored in the provided collection, ensuring the index used to poll the pending events.
+// Returns an error if the index cannot be created.
+//
+//   {col}   - The outbox collection, see DefaultCollection
+//   {opts}  - (Optional) The behavior of the outbox and its relay
+//
+func New(col mgo.ICollection, opts ...Options) (*Outbox, error) {
+	cfg := Options{}
+	if len(opts) > 0 {
+		cfg = opts[0]
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = DefaultPollInterval
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = DefaultRetention
+	}
+	if cfg.PurgeInterval <= 0 {
+		cfg.PurgeInterval = DefaultPurgeInterval
+	}
+	if cfg.MaxAttempts == 0 {
+		cfg.MaxAttempts = DefaultMaxAttempts
+	}
+	if cfg.StageTimeout <= 0 {
+		cfg.StageTimeout = DefaultStageTimeout
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+
+	err := col.EnsureIndex(mgo.Index{
+		Key:        []string{"dispatched_at", "parked_at", "seq", "_id"},
+		Background: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create the index of the outbox collection: %v", err)
+	}
+	ret := &Outbox{col: col, opts: cfg}
+	if cfg.Sequences != nil {
+		ret.sequence = col.FullName()
+	}
+	return ret, nil
+}
+
+// Add stores the provided messages in the outbox.
+func (o *Outbox) Add(messages ...Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	docs, _, err := o.newEvents(messages, nil)
+	if err != nil {
+		return err
+	}
+	return o.col.Insert(docs...)
+}
+
+// Write stages the provided messages in the outbox and runs the business write, committing the events if it succeeds or
+// removing them otherwise, see Outbox.
+func (o *Outbox) Write(write func() error, messages ...Message) error {
+	if len(messages) == 0 {
+		return write()
+	}
+
+	now := o.opts.Now()
+	docs, ids, err := o.newEvents(messages, &now)
+	if err != nil {
+		return err
+	}
+	if err := o.col.Insert(docs...); err != nil {
+		return fmt.Errorf("unable to stage the events in the outbox: %v", err)
+	}
+	selector := bson.M{"_id": bson.M{"$in": ids}}
+
+	if err := write(); err != nil {
+		if _, rerr := o.col.RemoveAll(selector); rerr != nil {
+			log.Get().Error("Unable to remove the staged outbox events of a failed write, ", rerr)
+		}
+		return err
+	}
+	// The events parked by the relay because the stage timeout expired are committed as well.
+	if _, err := o.col.UpdateAll(selector, bson.M{"$unset": bson.M{"staged_at": "", "parked_at": ""}}); err != nil {
+		return fmt.Errorf("the write succeeded but its events could not be committed in the outbox, they will be parked once the stage timeout expires: %v", err)
+	}
+	return nil
+}
+
+// newEvents creates the events of the provided messages, staged at the provided time if not nil. Returns the events
+// and their IDs.
+func (o *Outbox) newEvents(messages []Message, stagedAt *time.Time) ([]interface{}, []bson.ObjectId, error) {
+	now := o.opts.Now()
+	docs := make([]interface{}, len(messages))
+	ids := make([]bson.ObjectId, len(messages))
+	for i, m := range messages {
+		raw, err := mgo.MarshalRaw(m.Payload)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to marshal the event payload: %v", err)
+		}
+		event := &Event{
+			Id:        bson.NewObjectId(),
+			Topic:     m.Topic,
+			Key:       m.Key,
+			Payload:   raw,
+			CreatedAt: now,
+			StagedAt:  stagedAt,
+		}
+		if o.opts.Sequences != nil {
+			if event.Seq, err = o.opts.Sequences.Next(o.sequence); err != nil {
+				return nil, nil, err
+			}
+		}
+		docs[i], ids[i] = event, event.Id
+	}
+	return docs, ids, nil
+}
+
+// Dispatch publishes the next batch of pending events in order, see Outbox, marking each one as dispatched once
+// published. Stops at the first event that fails to publish, so the order is preserved, and records the error in it,
+// unless the event exhausted its attempts, in which case it is parked and the following events are dispatched. Stops as
+// well at the first staged event, unless its stage timeout expired, in which case it is parked with ErrNotCommitted.
+// Returns the amount of events dispatched or parked.
+func (o *Outbox) Dispatch(ctx context.Context, publisher Publisher) (int, error) {
+	var events []*Event
+	if err := o.col.Find(bson.M{"dispatched_at": nil, "parked_at": nil}).Sort("seq", "_id").Limit(o.opts.BatchSize).All(&events); err != nil {
+		return 0, err
+	}
+
+	for i, event := range events {
+		if err := ctx.Err(); err != nil {
+			return i, err
+		}
+
+		if event.StagedAt != nil {
+			if parked, err := o.parkStaged(event); err != nil || !parked {
+				return i, err
+			}
+			continue
+		}
+
+		if err := publisher.Publish(ctx, event); err != nil {
+			if parked, perr := o.failed(event, err); perr != nil || !parked {
+				return i, fmt.Errorf("unable to publish outbox event %s: %w", event.Id.Hex(), err)
+			}
+			continue
+		}
+
+		now := o.opts.Now()
+		if err := o.col.UpdateId(event.Id, bson.M{"$set": bson.M{"dispatched_at": now}}); err != nil {
+			return i, err
+		}
+		event.DispatchedAt = &now
+	}
+	return len(events), nil
+}
+
+// failed records the publish error in the event, parking it if it exhausted its attempts. Returns whether the event was
+// parked.
+func (o *Outbox) failed(event *Event, err error) (bool, error) {
+	set := bson.M{"last_error": err.Error()}
+	parked := o.opts.MaxAttempts > 0 && event.Attempts+1 >= o.opts.MaxAttempts
+	now := o.opts.Now()
+	if parked {
+		set["parked_at"] = now
+	}
+	if uerr := o.col.UpdateId(event.Id, bson.M{"$inc": bson.M{"attempts": 1}, "$set": set}); uerr != nil {
+		log.Get().Error("Unable to record the publish error of outbox event ", event.Id.Hex(), ", ", uerr)
+		return false, uerr
+	}
+
+	event.Attempts++
+	event.LastError = err.Error()
+	if !parked {
+		return false, nil
+	}
+	event.ParkedAt = &now
+	log.Get().Error("Outbox event ", event.Id.Hex(), " parked after ", event.Attempts, " failed attempts, ", err)
+	if o.opts.OnParked != nil {
+		o.opts.OnParked(event, err)
+	}
+	return true, nil
+}
+
+// parkStaged parks the staged event if its stage timeout expired. Returns whether the event was parked, which is not the
+// case either if it was committed since it was polled.
+func (o *Outbox) parkStaged(event *Event) (bool, error) {
+	now := o.opts.Now()
+	if now.Sub(*event.StagedAt) < o.opts.StageTimeout {
+		return false, nil
+	}
+	err := o.col.Update(
+		bson.M{"_id": event.Id, "staged_at": bson.M{"$ne": nil}},
+		bson.M{"$set": bson.M{"last_error": ErrNotCommitted.Error(), "parked_at": now}},
+	)
+	if err == mgo.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	event.LastError = ErrNotCommitted.Error()
+	event.ParkedAt = &now
+	log.Get().Error("Outbox event ", event.Id.Hex(), " parked, ", ErrNotCommitted)
+	if o.opts.OnParked != nil {
+		o.opts.OnParked(event, ErrNotCommitted)
+	}
+	return true, nil
+}
+
+// Parked returns up to the provided amount of parked events, in order.
+func (o *Outbox) Parked(limit int) ([]*Event, error) {
+	var events []*Event
+	if err := o.col.Find(bson.M{"dispatched_at": nil, "parked_at": bson.M{"$ne": nil}}).Sort("seq", "_id").Limit(limit).All(&events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// Requeue makes a parked event pending again, resetting its attempts, so it is dispatched by the relay in its original
+// position among the pending events. The events parked with ErrNotCommitted are committed as well, so they must only be
+// requeued once their business write is confirmed.
+func (o *Outbox) Requeue(id bson.ObjectId) error {
+	return o.col.Update(
+		bson.M{"_id": id, "parked_at": bson.M{"$ne": nil}},
+		bson.M{"$set": bson.M{"attempts": 0}, "$unset": bson.M{"parked_at": "", "staged_at": ""}},
+	)
+}
+
+// Purge removes the events dispatched before the retention period. Returns the amount of events removed.
+func (o *Outbox) Purge() (int, error) {
+	info, err := o.col.RemoveAll(bson.M{"dispatched_at": bson.M{"$lte": o.opts.Now().Add(-o.opts.Retention)}})
+	if err != nil {
+		return 0, err
+	}
+	return info.Removed, nil
+}
+
+// Relay dispatches the pending events until the context is done, waiting for the poll interval when there are none or
+// publishing fails, and purges the old events every purge interval.
+func (o *Outbox) Relay(ctx context.Context, publisher Publisher) error {
+	for {
+		if now := o.opts.Now(); now.Sub(o.lastPurge) >= o.opts.PurgeInterval {
+			if _, err := o.Purge(); err != nil {
+				log.Get().Error("Unable to purge the outbox, ", err)
+			}
+			o.lastPurge = now
+		}
+
+		n, err := o.Dispatch(ctx, publisher)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			log.Get().Error("Unable to dispatch the outbox events, ", err)
+		}
+		if err == nil && n == o.opts.BatchSize {
+			// There may be more pending events
+			continue
+		}
+
+		timer := time.NewTimer(o.opts.PollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
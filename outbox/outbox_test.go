@@ -0,0 +1,410 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jucardi/go-mongodb-lib/mgo"
+	"github.com/jucardi/go-mongodb-lib/testutils"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// eventsMock is an outbox collection which poll queries return the pending batches in order, and then no events, and
+// which writes respond with result. It records the queries and writes received.
+type eventsMock struct {
+	*mgo.CollectionMock
+	testutils.Recorder
+	mux     sync.Mutex
+	batches [][]*Event
+	result  error
+}
+
+func mockEvents(batches ...[]*Event) *eventsMock {
+	m := &eventsMock{CollectionMock: mgo.MockCollection(), batches: batches}
+	m.WhenReturn("EnsureIndex", nil)
+	m.When("Insert", func(args ...interface{}) []interface{} {
+		m.Record("Insert", args...)
+		return []interface{}{m.result}
+	})
+	m.When("Find", func(args ...interface{}) []interface{} {
+		m.Record("Find", args...)
+		q := mgo.MockQuery()
+		q.When("Sort", func(args ...interface{}) []interface{} {
+			m.Record("Sort", args...)
+			return []interface{}{q}
+		})
+		q.When("Limit", func(args ...interface{}) []interface{} {
+			m.Record("Limit", args...)
+			return []interface{}{q}
+		})
+		q.When("All", func(args ...interface{}) []interface{} {
+			m.mux.Lock()
+			defer m.mux.Unlock()
+			if len(m.batches) > 0 {
+				*args[0].(*[]*Event) = m.batches[0]
+				m.batches = m.batches[1:]
+			}
+			return []interface{}{nil}
+		})
+		return []interface{}{q}
+	})
+	m.When("UpdateId", func(args ...interface{}) []interface{} {
+		m.Record("UpdateId", args...)
+		return []interface{}{nil}
+	})
+	m.When("Update", func(args ...interface{}) []interface{} {
+		m.Record("Update", args...)
+		return []interface{}{m.result}
+	})
+	m.When("UpdateAll", func(args ...interface{}) []interface{} {
+		m.Record("UpdateAll", args...)
+		return []interface{}{&mgo.ChangeInfo{}, m.result}
+	})
+	m.When("RemoveAll", func(args ...interface{}) []interface{} {
+		m.Record("RemoveAll", args...)
+		return []interface{}{&mgo.ChangeInfo{Removed: 2}, nil}
+	})
+	return m
+}
+
+// inserted returns the events inserted, in order.
+func (m *eventsMock) inserted() []*Event {
+	var ret []*Event
+	for _, args := range m.ArgsOf("Insert") {
+		for _, e := range args {
+			ret = append(ret, e.(*Event))
+		}
+	}
+	return ret
+}
+
+// updates returns the arguments of the Update, UpdateId and UpdateAll calls, in order.
+func (m *eventsMock) updates() [][]interface{} {
+	var ret [][]interface{}
+	args := m.Args()
+	for i, name := range m.Names() {
+		if strings.HasPrefix(name, "Update") {
+			ret = append(ret, args[i])
+		}
+	}
+	return ret
+}
+
+func (m *eventsMock) FullName() string {
+	return "test._outbox"
+}
+
+// add queues a batch of pending events, to be returned by the next poll.
+func (m *eventsMock) add(events ...*Event) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.batches = append(m.batches, events)
+}
+
+type orderCreated struct {
+	Order string `bson:"order"`
+}
+
+func newTestOutbox(opts Options, batches ...[]*Event) (*Outbox, *eventsMock, *testutils.Clock) {
+	c := testutils.NewClock(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))
+	col := mockEvents(batches...)
+	opts.Now = c.Now
+	ob, _ := New(col, opts)
+	return ob, col, c
+}
+
+func events(topics ...string) []*Event {
+	var ret []*Event
+	for _, t := range topics {
+		ret = append(ret, &Event{Id: bson.NewObjectId(), Topic: t})
+	}
+	return ret
+}
+
+func topics(events []*Event) []string {
+	var ret []string
+	for _, e := range events {
+		ret = append(ret, e.Topic)
+	}
+	return ret
+}
+
+func TestNew(t *testing.T) {
+	col := mgo.MockCollection()
+	var index mgo.Index
+	col.When("EnsureIndex", func(args ...interface{}) []interface{} {
+		index = args[0].(mgo.Index)
+		return []interface{}{nil}
+	})
+	_, err := New(col)
+	assert.NoError(t, err)
+	assert.Equal(t, mgo.Index{Key: []string{"dispatched_at", "parked_at", "seq", "_id"}, Background: true}, index)
+
+	col.WhenReturn("EnsureIndex", errors.New("not authorized"))
+	_, err = New(col)
+	assert.EqualError(t, err, "unable to create the index of the outbox collection: not authorized")
+}
+
+func TestOutbox_Write(t *testing.T) {
+	ob, col, c := newTestOutbox(Options{})
+
+	// The events are staged before the write, and removed if it fails
+	err := ob.Write(func() error {
+		assert.Len(t, col.inserted(), 1)
+		return errors.New("duplicate order")
+	}, Message{Topic: "orders.created"})
+	assert.EqualError(t, err, "duplicate order")
+	staged := col.inserted()[0]
+	assert.Equal(t, [][]interface{}{{bson.M{"_id": bson.M{"$in": []bson.ObjectId{staged.Id}}}}}, col.ArgsOf("RemoveAll"))
+	assert.Empty(t, col.updates())
+
+	written := false
+	err = ob.Write(func() error {
+		written = true
+		return nil
+	}, Message{Topic: "orders.created", Key: "1", Payload: orderCreated{Order: "1"}})
+	assert.NoError(t, err)
+	assert.True(t, written)
+
+	assert.Len(t, col.inserted(), 2)
+	event := col.inserted()[1]
+	assert.Equal(t, "orders.created", event.Topic)
+	assert.Equal(t, "1", event.Key)
+	assert.Equal(t, c.Now(), event.CreatedAt)
+	assert.Equal(t, c.Now(), *event.StagedAt)
+	assert.Nil(t, event.DispatchedAt)
+	var payload orderCreated
+	assert.NoError(t, event.Decode(&payload))
+	assert.Equal(t, "1", payload.Order)
+	assert.Equal(t, [][]interface{}{{
+		bson.M{"_id": bson.M{"$in": []bson.ObjectId{event.Id}}},
+		bson.M{"$unset": bson.M{"staged_at": "", "parked_at": ""}},
+	}}, col.updates())
+
+	assert.NoError(t, ob.Add(Message{Topic: "orders.created"}))
+	assert.Nil(t, col.inserted()[2].StagedAt)
+
+	err = ob.Add(Message{Topic: "orders.created", Payload: func() {}})
+	assert.Error(t, err)
+
+	// The write is not run if the events cannot be staged
+	col.result = errors.New("connection reset")
+	written = false
+	err = ob.Write(func() error {
+		written = true
+		return nil
+	}, Message{Topic: "orders.created"})
+	assert.EqualError(t, err, "unable to stage the events in the outbox: connection reset")
+	assert.False(t, written)
+}
+
+func TestOutbox_WriteCommitFailure(t *testing.T) {
+	ob, col, _ := newTestOutbox(Options{})
+	col.When("UpdateAll", func(args ...interface{}) []interface{} {
+		return []interface{}{nil, errors.New("connection reset")}
+	})
+
+	err := ob.Write(func() error { return nil }, Message{Topic: "orders.created"})
+	assert.EqualError(t, err, "the write succeeded but its events could not be committed in the outbox, they will be parked once the stage timeout expires: connection reset")
+	assert.Len(t, col.inserted(), 1)
+	assert.Empty(t, col.ArgsOf("RemoveAll"))
+}
+
+func TestOutbox_Sequences(t *testing.T) {
+	counters := mgo.MockCollection()
+	var names []interface{}
+	var last int64
+	counters.When("FindId", func(args ...interface{}) []interface{} {
+		names = append(names, args[0])
+		q := mgo.MockQuery()
+		q.When("Apply", func(args ...interface{}) []interface{} {
+			last++
+			*args[1].(*bson.M) = bson.M{"_id": names[0], "seq": last}
+			return []interface{}{&mgo.ChangeInfo{Updated: 1}, nil}
+		})
+		return []interface{}{q}
+	})
+	ob, col, _ := newTestOutbox(Options{Sequences: mgo.NewSequences(counters)})
+
+	assert.NoError(t, ob.Add(Message{Topic: "a"}, Message{Topic: "b"}))
+	assert.Equal(t, []interface{}{"test._outbox", "test._outbox"}, names)
+	assert.Equal(t, int64(1), col.inserted()[0].Seq)
+	assert.Equal(t, int64(2), col.inserted()[1].Seq)
+
+	counters.When("FindId", func(args ...interface{}) []interface{} {
+		q := mgo.MockQuery()
+		q.WhenReturn("Apply", nil, errors.New("connection reset"))
+		return []interface{}{q}
+	})
+	err := ob.Add(Message{Topic: "c"})
+	assert.EqualError(t, err, "unable to allocate values from sequence 'test._outbox': connection reset")
+	assert.Len(t, col.inserted(), 2)
+}
+
+func TestOutbox_Dispatch(t *testing.T) {
+	pending := events("a", "b")
+	ob, col, c := newTestOutbox(Options{BatchSize: 2}, pending)
+	publisher := NewMemoryPublisher()
+
+	n, err := ob.Dispatch(context.Background(), publisher)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{"a", "b"}, topics(publisher.Events()))
+	assert.Equal(t, bson.M{"dispatched_at": nil, "parked_at": nil}, col.ArgsOf("Find")[0][0])
+	assert.Equal(t, []interface{}{"seq", "_id"}, col.ArgsOf("Sort")[0])
+	assert.Equal(t, [][]interface{}{{2}}, col.ArgsOf("Limit"))
+	assert.Equal(t, [][]interface{}{
+		{pending[0].Id, bson.M{"$set": bson.M{"dispatched_at": c.Now()}}},
+		{pending[1].Id, bson.M{"$set": bson.M{"dispatched_at": c.Now()}}},
+	}, col.updates())
+	assert.Equal(t, c.Now(), *pending[1].DispatchedAt)
+
+	n, err = ob.Dispatch(context.Background(), publisher)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+}
+
+func TestOutbox_DispatchFailure(t *testing.T) {
+	pending := events("a", "b")
+	ob, col, _ := newTestOutbox(Options{}, pending)
+	publisher := NewMemoryPublisher()
+	publisher.FailWith(errors.New("broker unavailable"))
+
+	n, err := ob.Dispatch(context.Background(), publisher)
+	assert.EqualError(t, err, "unable to publish outbox event "+pending[0].Id.Hex()+": broker unavailable")
+	assert.Equal(t, 0, n)
+
+	// The following events are not published, so the order is preserved.
+	assert.Equal(t, [][]interface{}{
+		{pending[0].Id, bson.M{"$inc": bson.M{"attempts": 1}, "$set": bson.M{"last_error": "broker unavailable"}}},
+	}, col.updates())
+	assert.Nil(t, pending[0].DispatchedAt)
+}
+
+func TestOutbox_Parking(t *testing.T) {
+	pending := events("a", "b")
+	pending[0].Attempts = 1
+	var parked []string
+	ob, col, c := newTestOutbox(Options{MaxAttempts: 2, OnParked: func(event *Event, err error) {
+		parked = append(parked, event.Topic+": "+err.Error())
+	}}, pending, events("c"))
+	publisher := PublisherFunc(func(_ context.Context, event *Event) error {
+		if event.Topic != "b" {
+			return errors.New("invalid payload")
+		}
+		return nil
+	})
+
+	// The event exhausted its attempts, it is parked and the following events are dispatched.
+	n, err := ob.Dispatch(context.Background(), publisher)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{"a: invalid payload"}, parked)
+	assert.Equal(t, []interface{}{
+		pending[0].Id,
+		bson.M{"$inc": bson.M{"attempts": 1}, "$set": bson.M{"last_error": "invalid payload", "parked_at": c.Now()}},
+	}, col.updates()[0])
+	assert.Equal(t, 2, pending[0].Attempts)
+	assert.Equal(t, c.Now(), *pending[0].ParkedAt)
+	assert.NotNil(t, pending[1].DispatchedAt)
+
+	// The first failed attempt is retried.
+	_, err = ob.Dispatch(context.Background(), publisher)
+	assert.Error(t, err)
+	assert.Len(t, parked, 1)
+
+	_, err = ob.Parked(10)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"dispatched_at": nil, "parked_at": bson.M{"$ne": nil}}, col.ArgsOf("Find")[2][0])
+	assert.Equal(t, []interface{}{"seq", "_id"}, col.ArgsOf("Sort")[2])
+
+	assert.NoError(t, ob.Requeue(pending[0].Id))
+	assert.Equal(t, []interface{}{
+		bson.M{"_id": pending[0].Id, "parked_at": bson.M{"$ne": nil}},
+		bson.M{"$set": bson.M{"attempts": 0}, "$unset": bson.M{"parked_at": "", "staged_at": ""}},
+	}, col.Last())
+}
+
+func TestOutbox_Staged(t *testing.T) {
+	pending := events("a", "b", "c")
+	stagedAt := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	pending[1].StagedAt = &stagedAt
+	var parked []string
+	ob, col, c := newTestOutbox(Options{StageTimeout: time.Minute, OnParked: func(event *Event, err error) {
+		parked = append(parked, event.Topic+": "+err.Error())
+	}}, pending, pending[1:])
+	publisher := NewMemoryPublisher()
+
+	// The write of the event may still be running, the following events wait for it
+	n, err := ob.Dispatch(context.Background(), publisher)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{"a"}, topics(publisher.Events()))
+
+	// The stage timeout expired, the event is parked and the following events are dispatched
+	c.Add(time.Minute)
+	n, err = ob.Dispatch(context.Background(), publisher)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{"a", "c"}, topics(publisher.Events()))
+	assert.Equal(t, []string{"b: " + ErrNotCommitted.Error()}, parked)
+	assert.Equal(t, []interface{}{
+		bson.M{"_id": pending[1].Id, "staged_at": bson.M{"$ne": nil}},
+		bson.M{"$set": bson.M{"last_error": ErrNotCommitted.Error(), "parked_at": c.Now()}},
+	}, col.updates()[1])
+	assert.Equal(t, c.Now(), *pending[1].ParkedAt)
+}
+
+func TestOutbox_StagedCommitted(t *testing.T) {
+	// The event was committed since it was polled, it is dispatched by the next poll
+	pending := events("a", "b")
+	stagedAt := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	pending[0].StagedAt = &stagedAt
+	ob, col, c := newTestOutbox(Options{StageTimeout: time.Minute}, pending)
+	col.result = mgo.ErrNotFound
+	c.Add(time.Hour)
+
+	n, err := ob.Dispatch(context.Background(), NewMemoryPublisher())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+	assert.Nil(t, pending[0].ParkedAt)
+	assert.Len(t, col.updates(), 1)
+}
+
+func TestOutbox_Purge(t *testing.T) {
+	ob, col, c := newTestOutbox(Options{Retention: time.Hour})
+
+	n, err := ob.Purge()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, [][]interface{}{{bson.M{"dispatched_at": bson.M{"$lte": c.Now().Add(-time.Hour)}}}}, col.ArgsOf("RemoveAll"))
+}
+
+func TestOutbox_Relay(t *testing.T) {
+	ob, col, _ := newTestOutbox(Options{PollInterval: time.Millisecond}, events("a", "b"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	published := make(chan string, 3)
+	done := make(chan error)
+	go func() {
+		done <- ob.Relay(ctx, PublisherFunc(func(_ context.Context, event *Event) error {
+			published <- event.Topic
+			return nil
+		}))
+	}()
+
+	assert.Equal(t, "a", <-published)
+	assert.Equal(t, "b", <-published)
+	col.add(events("c")...)
+	assert.Equal(t, "c", <-published)
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+
+	assert.Len(t, col.ArgsOf("RemoveAll"), 1)
+	assert.Len(t, col.updates(), 3)
+}
@@ -0,0 +1,372 @@
+// Package queue implements a durable job queue on a MongoDB collection, for low volume background work that does not
+// justify a separate queue service.
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jucardi/go-mongodb-lib/log"
+	"github.com/jucardi/go-mongodb-lib/mgo"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// DefaultName is the name of the queue if none is provided.
+const DefaultName = "default"
+
+const (
+	// DefaultVisibilityTimeout is the default time a claimed job stays invisible to other consumers.
+	DefaultVisibilityTimeout = 30 * time.Second
+	// DefaultMaxAttempts is the default amount of attempts after which a failed job is dead-lettered.
+	DefaultMaxAttempts = 5
+	// DefaultPollInterval is the default time Process waits before claiming again when the queue is empty.
+	DefaultPollInterval = time.Second
+)
+
+// Job statuses.
+const (
+	// StatusPending is the status of the jobs waiting to be claimed, including the failed ones waiting for a retry.
+	StatusPending = "pending"
+	// StatusRunning is the status of the claimed jobs. They are claimed again if not acknowledged before the
+	// visibility timeout expires.
+	StatusRunning = "running"
+	// StatusDead is the status of the jobs that exhausted their attempts, when no dead letter collection is configured.
+	StatusDead = "dead"
+)
+
+var (
+	// ErrEmpty is the error returned by Claim when there are no jobs available.
+	ErrEmpty = errors.New("there are no jobs available")
+	// ErrJobLost is the error returned by Ack, Nack and Extend when the visibility timeout of the job expired and it was
+	// claimed by another consumer.
+	ErrJobLost = errors.New("the job is no longer claimed by this consumer")
+)
+
+// DefaultRetryBackoff is the default time a failed job waits before it can be claimed again, depending on its attempts.
+var DefaultRetryBackoff = mgo.ExponentialBackoff(time.Second, 5*time.Minute, 2)
+
+// Job is a job of the queue.
+type Job struct {
+	Id          bson.ObjectId `bson:"_id"`
+	Queue       string        `bson:"queue"`
+	Payload     bson.Raw      `bson:"payload"`
+	Priority    int           `bson:"priority"`
+	Status      string        `bson:"status"`
+	Attempts    int           `bson:"attempts"`
+	MaxAttempts int           `bson:"max_attempts"`
+	// AvailableAt is the time the job can be claimed: the enqueue time plus the delay for pending jobs, the time the
+	// visibility timeout expires for running jobs.
+	AvailableAt time.Time `bson:"available_at"`
+	LastError   string    `bson:"last_error,omitempty"`
+	CreatedAt   time.Time `bson:"created_at"`
+	UpdatedAt   time.Time `bson:"updated_at"`
+	// Token identifies the claim of the job, it is checked by Ack, Nack and Extend.
+	Token string `bson:"token,omitempty"`
+}
+
+// Decode unmarshals the payload of the job into the provided value.
+func (j *Job) Decode(out interface{}) error {
+	return j.Payload.Unmarshal(out)
+}
+
+// Options defines the behavior of a queue.
+type Options struct {
+	// Name of the queue, so several queues can share a collection. Defaults to DefaultName.
+	Name string
+	// VisibilityTimeout is the time a claimed job stays invisible to other consumers. If the job is not acknowledged
+	// within it, it is claimed again. Defaults to DefaultVisibilityTimeout.
+	VisibilityTimeout time.Duration
+	// MaxAttempts is the default amount of attempts after which a failed job is dead-lettered. Defaults to
+	// DefaultMaxAttempts.
+	MaxAttempts int
+	// RetryBackoff returns the time a failed job waits before it can be claimed again, by attempt. Defaults to
+	// DefaultRetryBackoff.
+	RetryBackoff mgo.Backoff
+	// DeadLetter is the collection the dead jobs are moved to. If not set, they are kept in the queue collection with
+	// the StatusDead status.
+	DeadLetter mgo.ICollection
+	// PollInterval is the time Process waits before claiming again when the queue is empty. Defaults to
+	// DefaultPollInterval.
+	PollInterval time.Duration
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// EnqueueOptions defines how a job is enqueued.
+type EnqueueOptions struct {
+	// Priority of the job, the jobs with higher priority are claimed first.
+	Priority int
+	// Delay is the time to wait before the job can be claimed.
+	Delay time.Duration
+	// MaxAttempts overrides the max attempts of the queue for the job.
+	MaxAttempts int
+}
+
+// Queue is a durable job queue stored in a collection. Jobs are claimed atomically with findAndModify, ordered by
+// priority and availability, and become invisible to other consumers for the visibility timeout. A consumer either
+// acknowledges a job, which removes it, or rejects it, which makes it available again after the retry backoff, or
+// dead-letters it once it exhausts its attempts. Jobs claimed by consumers that crash are claimed again once their
+// visibility timeout expires, so the handlers must be idempotent.
+//
+//   Example:
+//
+//      q, err := queue.New(db.C("jobs"), queue.Options{Name: "emails"})
+//      if err != nil {
+//          return err
+//      }
+//      _, err = q.Enqueue(email, queue.EnqueueOptions{Delay: time.Minute})
+//
+//      go q.Process(ctx, func(job *queue.Job) error {
+//          var email Email
+//          if err := job.Decode(&email); err != nil {
+//              return err
+//          }
+//          return send(email)
+//      })
+//
+type Queue struct {
+	col  mgo.ICollection
+	opts Options
+}
+
+// New creates a new queue stored in the provided collection, ensuring the index used to claim the jobs. Returns an
+// error if the index cannot be created.
+//
+//   {col}   - The jobs collection
+//   {opts}  - (Optional) The behavior of the queue
+//
+func New(col mgo.ICollection, opts ...Options) (*Queue, error) {
+	cfg := Options{}
+	if len(opts) > 0 {
+		cfg = opts[0]
+	}
+	if cfg.Name == "" {
+		cfg.Name = DefaultName
+	}
+	if cfg.VisibilityTimeout <= 0 {
+		cfg.VisibilityTimeout = DefaultVisibilityTimeout
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = DefaultMaxAttempts
+	}
+	if cfg.RetryBackoff == nil {
+		cfg.RetryBackoff = DefaultRetryBackoff
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = DefaultPollInterval
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+
+	err := col.EnsureIndex(mgo.Index{
+		Key:        []string{"queue", "status", "-priority", "available_at"},
+		Background: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create the index of the jobs collection: %v", err)
+	}
+	return &Queue{col: col, opts: cfg}, nil
+}
+
+// Enqueue adds a job with the provided payload, which can be any value that can be marshalled with bson.
+func (q *Queue) Enqueue(payload interface{}, opts ...EnqueueOptions) (*Job, error) {
+	cfg := EnqueueOptions{}
+	if len(opts) > 0 {
+		cfg = opts[0]
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = q.opts.MaxAttempts
+	}
+
+	raw, err := mgo.MarshalRaw(payload)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal the job payload: %v", err)
+	}
+
+	now := q.opts.Now()
+	job := &Job{
+		Id:          bson.NewObjectId(),
+		Queue:       q.opts.Name,
+		Payload:     raw,
+		Priority:    cfg.Priority,
+		Status:      StatusPending,
+		MaxAttempts: cfg.MaxAttempts,
+		AvailableAt: now.Add(cfg.Delay),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := q.col.Insert(job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Claim claims the available job with the highest priority, or returns ErrEmpty if there are none. Jobs claimed again
+// after their visibility timeout expired that already exhausted their attempts are dead-lettered instead of returned.
+func (q *Queue) Claim() (*Job, error) {
+	for {
+		token, err := mgo.NewToken()
+		if err != nil {
+			return nil, err
+		}
+
+		now := q.opts.Now()
+		job := &Job{}
+		_, err = q.col.Find(bson.M{
+			"queue":        q.opts.Name,
+			"status":       bson.M{"$in": []string{StatusPending, StatusRunning}},
+			"available_at": bson.M{"$lte": now},
+		}).Sort("-priority", "available_at").Apply(mgo.Change{
+			Update: bson.M{
+				"$set": bson.M{
+					"status":       StatusRunning,
+					"token":        token,
+					"available_at": now.Add(q.opts.VisibilityTimeout),
+					"updated_at":   now,
+				},
+				"$inc": bson.M{"attempts": 1},
+			},
+			ReturnNew: true,
+		}, job)
+
+		if err == mgo.ErrNotFound {
+			return nil, ErrEmpty
+		}
+		if err != nil {
+			return nil, err
+		}
+		if job.Attempts <= job.MaxAttempts {
+			return job, nil
+		}
+		if err := q.deadLetter(job, "the visibility timeout expired in the last attempt"); err != nil && err != ErrJobLost {
+			return nil, err
+		}
+	}
+}
+
+// Ack acknowledges a claimed job, removing it from the queue.
+func (q *Queue) Ack(job *Job) error {
+	return q.jobErr(q.col.Remove(bson.M{"_id": job.Id, "token": job.Token}))
+}
+
+// Nack rejects a claimed job with the provided cause. The job is made available again after the retry backoff, or
+// dead-lettered if it exhausted its attempts.
+func (q *Queue) Nack(job *Job, cause error) error {
+	msg := ""
+	if cause != nil {
+		msg = cause.Error()
+	}
+	if job.Attempts >= job.MaxAttempts {
+		return q.deadLetter(job, msg)
+	}
+
+	now := q.opts.Now()
+	availableAt := now.Add(q.opts.RetryBackoff(job.Attempts))
+	err := q.col.Update(bson.M{"_id": job.Id, "token": job.Token}, bson.M{
+		"$set": bson.M{
+			"status":       StatusPending,
+			"available_at": availableAt,
+			"last_error":   msg,
+			"updated_at":   now,
+		},
+		"$unset": bson.M{"token": ""},
+	})
+	if err != nil {
+		return q.jobErr(err)
+	}
+	job.Status, job.AvailableAt, job.LastError, job.UpdatedAt, job.Token = StatusPending, availableAt, msg, now, ""
+	return nil
+}
+
+// Extend extends the visibility timeout of a claimed job to the provided time from now, for jobs that take longer than
+// the visibility timeout of the queue.
+func (q *Queue) Extend(job *Job, timeout time.Duration) error {
+	now := q.opts.Now()
+	availableAt := now.Add(timeout)
+	err := q.col.Update(bson.M{"_id": job.Id, "token": job.Token}, bson.M{
+		"$set": bson.M{"available_at": availableAt, "updated_at": now},
+	})
+	if err != nil {
+		return q.jobErr(err)
+	}
+	job.AvailableAt, job.UpdatedAt = availableAt, now
+	return nil
+}
+
+// Process claims and handles jobs until the context is done, acknowledging the jobs handled successfully and rejecting
+// the ones that fail. Waits for the poll interval when the queue is empty or claiming fails.
+func (q *Queue) Process(ctx context.Context, handler func(job *Job) error) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		job, err := q.Claim()
+		if err != nil {
+			if err != ErrEmpty {
+				log.Get().Error("Unable to claim a job from queue ", q.opts.Name, ", ", err)
+			}
+			timer := time.NewTimer(q.opts.PollInterval)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+			continue
+		}
+
+		if err := handler(job); err != nil {
+			err = q.Nack(job, err)
+		} else {
+			err = q.Ack(job)
+		}
+		if err != nil {
+			log.Get().Error("Unable to complete job ", job.Id.Hex(), " of queue ", q.opts.Name, ", ", err)
+		}
+	}
+}
+
+// deadLetter marks the job as dead, or moves it to the dead letter collection if configured.
+func (q *Queue) deadLetter(job *Job, msg string) error {
+	now := q.opts.Now()
+	selector := bson.M{"_id": job.Id, "token": job.Token}
+
+	if q.opts.DeadLetter == nil {
+		err := q.col.Update(selector, bson.M{
+			"$set":   bson.M{"status": StatusDead, "last_error": msg, "updated_at": now},
+			"$unset": bson.M{"token": ""},
+		})
+		if err != nil {
+			return q.jobErr(err)
+		}
+		job.Status, job.LastError, job.UpdatedAt, job.Token = StatusDead, msg, now, ""
+		return nil
+	}
+
+	// The job is removed first so it is only dead-lettered by the consumer holding its token. If it cannot be written
+	// to the dead letter collection, it is kept in the queue collection with the StatusDead status instead.
+	if _, err := q.col.Find(selector).Apply(mgo.Change{Remove: true}, nil); err != nil {
+		return q.jobErr(err)
+	}
+	dead := *job
+	dead.Status, dead.LastError, dead.UpdatedAt, dead.Token = StatusDead, msg, now, ""
+	if _, err := q.opts.DeadLetter.UpsertId(dead.Id, &dead); err != nil {
+		if restoreErr := q.col.Insert(&dead); restoreErr != nil {
+			log.Get().Error("Unable to restore dead job ", dead.Id.Hex(), " of queue ", q.opts.Name, ", ", restoreErr)
+		}
+		return err
+	}
+	*job = dead
+	return nil
+}
+
+func (q *Queue) jobErr(err error) error {
+	if err == mgo.ErrNotFound {
+		return ErrJobLost
+	}
+	return err
+}
@@ -0,0 +1,292 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jucardi/go-mongodb-lib/mgo"
+	"github.com/jucardi/go-mongodb-lib/testutils"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// jobsMock is a jobs collection which findAndModify returns the claimable jobs in order, and then mgo.ErrNotFound, and
+// which removals respond with result. It records the queries and writes received.
+type jobsMock struct {
+	*mgo.CollectionMock
+	testutils.Recorder
+	claimable []*Job
+	result    error
+}
+
+func mockJobs(claimable ...*Job) *jobsMock {
+	m := &jobsMock{CollectionMock: mgo.MockCollection(), claimable: claimable}
+	m.WhenReturn("EnsureIndex", nil)
+	m.When("Insert", func(args ...interface{}) []interface{} {
+		m.Record("Insert", args...)
+		return []interface{}{m.result}
+	})
+	m.When("Find", func(args ...interface{}) []interface{} {
+		m.Record("Find", args...)
+		q := mgo.MockQuery()
+		q.When("Sort", func(args ...interface{}) []interface{} {
+			m.Record("Sort", args...)
+			return []interface{}{q}
+		})
+		q.When("Apply", func(args ...interface{}) []interface{} {
+			m.Record("Apply", args...)
+			if args[0].(mgo.Change).Remove {
+				return []interface{}{&mgo.ChangeInfo{Removed: 1}, m.result}
+			}
+			if len(m.claimable) == 0 {
+				return []interface{}{nil, mgo.ErrNotFound}
+			}
+			*args[1].(*Job) = *m.claimable[0]
+			m.claimable = m.claimable[1:]
+			return []interface{}{&mgo.ChangeInfo{Updated: 1}, nil}
+		})
+		return []interface{}{q}
+	})
+	m.When("Update", func(args ...interface{}) []interface{} {
+		m.Record("Update", args...)
+		return []interface{}{m.result}
+	})
+	m.When("Remove", func(args ...interface{}) []interface{} {
+		m.Record("Remove", args...)
+		return []interface{}{m.result}
+	})
+	return m
+}
+
+// change returns the change of the findAndModify call at the provided index.
+func (m *jobsMock) change(i int) mgo.Change {
+	return m.ArgsOf("Apply")[i][0].(mgo.Change)
+}
+
+type email struct {
+	To string `bson:"to"`
+}
+
+func newTestQueue(opts Options, claimable ...*Job) (*Queue, *jobsMock, *testutils.Clock) {
+	c := testutils.NewClock(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))
+	col := mockJobs(claimable...)
+	opts.Now = c.Now
+	opts.RetryBackoff = mgo.ConstantBackoff(time.Minute)
+	q, _ := New(col, opts)
+	return q, col, c
+}
+
+func TestNew(t *testing.T) {
+	col := mgo.MockCollection()
+	var index mgo.Index
+	col.When("EnsureIndex", func(args ...interface{}) []interface{} {
+		index = args[0].(mgo.Index)
+		return []interface{}{nil}
+	})
+	_, err := New(col)
+	assert.NoError(t, err)
+	assert.Equal(t, mgo.Index{Key: []string{"queue", "status", "-priority", "available_at"}, Background: true}, index)
+
+	col.WhenReturn("EnsureIndex", errors.New("not authorized"))
+	_, err = New(col)
+	assert.EqualError(t, err, "unable to create the index of the jobs collection: not authorized")
+}
+
+func TestQueue_Enqueue(t *testing.T) {
+	q, col, c := newTestQueue(Options{Name: "emails"})
+
+	job, err := q.Enqueue(email{To: "a"}, EnqueueOptions{Priority: 10, Delay: time.Minute})
+	assert.NoError(t, err)
+	assert.Equal(t, [][]interface{}{{job}}, col.ArgsOf("Insert"))
+	assert.Equal(t, "emails", job.Queue)
+	assert.Equal(t, StatusPending, job.Status)
+	assert.Equal(t, 10, job.Priority)
+	assert.Equal(t, DefaultMaxAttempts, job.MaxAttempts)
+	assert.Equal(t, c.Now().Add(time.Minute), job.AvailableAt)
+	assert.Equal(t, c.Now(), job.CreatedAt)
+	var e email
+	assert.NoError(t, job.Decode(&e))
+	assert.Equal(t, "a", e.To)
+
+	job, err = q.Enqueue("payload", EnqueueOptions{MaxAttempts: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, job.MaxAttempts)
+	assert.Equal(t, c.Now(), job.AvailableAt)
+
+	_, err = q.Enqueue(func() {})
+	assert.Error(t, err)
+
+	col.result = errors.New("connection reset")
+	_, err = q.Enqueue("payload")
+	assert.EqualError(t, err, "connection reset")
+}
+
+func TestQueue_Claim(t *testing.T) {
+	pending := &Job{Id: bson.NewObjectId(), Attempts: 1, MaxAttempts: 5}
+	q, col, c := newTestQueue(Options{Name: "emails", VisibilityTimeout: time.Minute}, pending)
+
+	job, err := q.Claim()
+	assert.NoError(t, err)
+	assert.Equal(t, pending.Id, job.Id)
+	assert.Equal(t, bson.M{
+		"queue":        "emails",
+		"status":       bson.M{"$in": []string{StatusPending, StatusRunning}},
+		"available_at": bson.M{"$lte": c.Now()},
+	}, col.ArgsOf("Find")[0][0])
+	assert.Equal(t, []interface{}{"-priority", "available_at"}, col.ArgsOf("Sort")[0])
+
+	token := col.change(0).Update.(bson.M)["$set"].(bson.M)["token"]
+	assert.Len(t, token, 32)
+	assert.Equal(t, mgo.Change{
+		Update: bson.M{
+			"$set": bson.M{
+				"status":       StatusRunning,
+				"token":        token,
+				"available_at": c.Now().Add(time.Minute),
+				"updated_at":   c.Now(),
+			},
+			"$inc": bson.M{"attempts": 1},
+		},
+		ReturnNew: true,
+	}, col.change(0))
+
+	_, err = q.Claim()
+	assert.Equal(t, ErrEmpty, err)
+	assert.NotEqual(t, token, col.change(1).Update.(bson.M)["$set"].(bson.M)["token"])
+}
+
+func TestQueue_ClaimExhausted(t *testing.T) {
+	// The consumer crashed in the last attempt, the job is dead-lettered when claimed again.
+	exhausted := &Job{Id: bson.NewObjectId(), Attempts: 3, MaxAttempts: 2, Token: "t"}
+	next := &Job{Id: bson.NewObjectId(), Attempts: 1, MaxAttempts: 2}
+	q, col, c := newTestQueue(Options{}, exhausted, next)
+
+	job, err := q.Claim()
+	assert.NoError(t, err)
+	assert.Equal(t, next.Id, job.Id)
+	assert.Equal(t, [][]interface{}{{
+		bson.M{"_id": exhausted.Id, "token": "t"},
+		bson.M{
+			"$set":   bson.M{"status": StatusDead, "last_error": "the visibility timeout expired in the last attempt", "updated_at": c.Now()},
+			"$unset": bson.M{"token": ""},
+		},
+	}}, col.ArgsOf("Update"))
+}
+
+func TestQueue_AckNackExtend(t *testing.T) {
+	q, col, c := newTestQueue(Options{})
+	job := &Job{Id: bson.NewObjectId(), Attempts: 1, MaxAttempts: 2, Token: "t"}
+
+	assert.NoError(t, q.Nack(job, errors.New("smtp down")))
+	assert.Equal(t, []interface{}{
+		bson.M{"_id": job.Id, "token": "t"},
+		bson.M{
+			"$set": bson.M{
+				"status":       StatusPending,
+				"available_at": c.Now().Add(time.Minute),
+				"last_error":   "smtp down",
+				"updated_at":   c.Now(),
+			},
+			"$unset": bson.M{"token": ""},
+		},
+	}, col.ArgsOf("Update")[0])
+	assert.Equal(t, StatusPending, job.Status)
+	assert.Equal(t, "", job.Token)
+
+	job.Attempts, job.Token = 2, "t2"
+	assert.NoError(t, q.Extend(job, 2*time.Minute))
+	assert.Equal(t, []interface{}{
+		bson.M{"_id": job.Id, "token": "t2"},
+		bson.M{"$set": bson.M{"available_at": c.Now().Add(2 * time.Minute), "updated_at": c.Now()}},
+	}, col.ArgsOf("Update")[1])
+
+	assert.NoError(t, q.Ack(job))
+	assert.Equal(t, [][]interface{}{{bson.M{"_id": job.Id, "token": "t2"}}}, col.ArgsOf("Remove"))
+
+	// The job exhausted its attempts
+	assert.NoError(t, q.Nack(job, errors.New("smtp still down")))
+	assert.Equal(t, bson.M{
+		"$set":   bson.M{"status": StatusDead, "last_error": "smtp still down", "updated_at": c.Now()},
+		"$unset": bson.M{"token": ""},
+	}, col.ArgsOf("Update")[2][1])
+	assert.Equal(t, StatusDead, job.Status)
+
+	// The token no longer matches once the job was claimed by another consumer
+	col.result = mgo.ErrNotFound
+	assert.Equal(t, ErrJobLost, q.Ack(job))
+	assert.Equal(t, ErrJobLost, q.Extend(job, time.Minute))
+}
+
+func TestQueue_DeadLetterCollection(t *testing.T) {
+	dead := mgo.MockCollection()
+	var upserted []interface{}
+	var upsertErr error
+	dead.When("UpsertId", func(args ...interface{}) []interface{} {
+		upserted = args
+		return []interface{}{&mgo.ChangeInfo{}, upsertErr}
+	})
+	q, col, c := newTestQueue(Options{MaxAttempts: 1, DeadLetter: dead})
+	job := &Job{Id: bson.NewObjectId(), Attempts: 1, MaxAttempts: 1, Status: StatusRunning, Token: "t"}
+
+	// The job is claimed before it is dead-lettered, and kept in the queue as dead if it cannot be moved
+	upsertErr = errors.New("connection reset")
+	assert.EqualError(t, q.Nack(job, errors.New("failed")), "connection reset")
+	assert.Equal(t, []string{"Find", "Apply", "Insert"}, col.Names())
+	assert.Equal(t, bson.M{"_id": job.Id, "token": "t"}, col.ArgsOf("Find")[0][0])
+	assert.Equal(t, mgo.Change{Remove: true}, col.change(0))
+	assert.Equal(t, StatusDead, col.ArgsOf("Insert")[0][0].(*Job).Status)
+	assert.Equal(t, StatusRunning, job.Status)
+
+	upsertErr = nil
+	assert.NoError(t, q.Nack(job, errors.New("failed")))
+	assert.Equal(t, job.Id, upserted[0])
+	stored := upserted[1].(*Job)
+	assert.Equal(t, StatusDead, stored.Status)
+	assert.Equal(t, "failed", stored.LastError)
+	assert.Equal(t, c.Now(), stored.UpdatedAt)
+	assert.Equal(t, "", stored.Token)
+	assert.Len(t, col.ArgsOf("Apply"), 2)
+	assert.Equal(t, *stored, *job)
+
+	// The token no longer matches once the job was claimed by another consumer, it is not dead-lettered
+	upserted = nil
+	col.result = mgo.ErrNotFound
+	lost := &Job{Id: bson.NewObjectId(), Attempts: 1, MaxAttempts: 1, Status: StatusRunning, Token: "t"}
+	assert.Equal(t, ErrJobLost, q.Nack(lost, errors.New("failed")))
+	assert.Nil(t, upserted)
+	assert.Equal(t, StatusRunning, lost.Status)
+}
+
+func TestQueue_Process(t *testing.T) {
+	q, col, _ := newTestQueue(Options{PollInterval: time.Millisecond})
+	ok, _ := q.Enqueue("ok")
+	failing, _ := q.Enqueue("fail")
+	col.claimable = []*Job{ok, failing}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	handled := make(chan string, 2)
+	done := make(chan error)
+	go func() {
+		done <- q.Process(ctx, func(job *Job) error {
+			var s string
+			_ = job.Decode(&s)
+			handled <- s
+			if s == "fail" {
+				return errors.New("failed")
+			}
+			return nil
+		})
+	}()
+
+	assert.Equal(t, []string{"ok", "fail"}, []string{<-handled, <-handled})
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+
+	assert.Equal(t, [][]interface{}{{bson.M{"_id": ok.Id, "token": ""}}}, col.ArgsOf("Remove"))
+	updates := col.ArgsOf("Update")
+	assert.Len(t, updates, 1)
+	assert.Equal(t, bson.M{"_id": failing.Id, "token": ""}, updates[0][0])
+	assert.Equal(t, "failed", updates[0][1].(bson.M)["$set"].(bson.M)["last_error"])
+}
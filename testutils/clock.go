@@ -0,0 +1,32 @@
+package testutils
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is a manually advanced clock, its Now function can be injected as the 'Now' option of the components that
+// depend on the current time.
+type Clock struct {
+	mux sync.Mutex
+	now time.Time
+}
+
+// NewClock creates a new clock set to the provided time.
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Now returns the current time of the clock.
+func (c *Clock) Now() time.Time {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.now
+}
+
+// Add advances the clock by the provided duration.
+func (c *Clock) Add(d time.Duration) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.now = c.now.Add(d)
+}
@@ -0,0 +1,72 @@
+package testutils
+
+import "sync"
+
+// Recorder records the calls received by a test double, by function name and in the order received, so the tests can
+// assert the arguments sent. The zero value is ready to use, and it is safe for concurrent use, eg: embedded in a mock
+// which handlers record the calls:
+//
+//   Usage:
+//
+//      type usersMock struct {
+//          *mgo.CollectionMock
+//          testutils.Recorder
+//      }
+//
+//      m.When("Insert", func(args ...interface{}) []interface{} {
+//          m.Record("Insert", args...)
+//          return []interface{}{nil}
+//      })
+//
+//      assert.Equal(t, []interface{}{user}, m.ArgsOf("Insert")[0])
+//
+type Recorder struct {
+	mux   sync.Mutex
+	names []string
+	args  [][]interface{}
+}
+
+// Record records a call to the function with the provided name and arguments.
+func (r *Recorder) Record(name string, args ...interface{}) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.names = append(r.names, name)
+	r.args = append(r.args, args)
+}
+
+// Names returns the function names of the calls recorded, in order.
+func (r *Recorder) Names() []string {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	return append([]string(nil), r.names...)
+}
+
+// Args returns the arguments of the calls recorded, in order.
+func (r *Recorder) Args() [][]interface{} {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	return append([][]interface{}(nil), r.args...)
+}
+
+// ArgsOf returns the arguments of the calls recorded to the function with the provided name, in order.
+func (r *Recorder) ArgsOf(name string) [][]interface{} {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	var ret [][]interface{}
+	for i, n := range r.names {
+		if n == name {
+			ret = append(ret, r.args[i])
+		}
+	}
+	return ret
+}
+
+// Last returns the arguments of the last call recorded, or nil if there are none.
+func (r *Recorder) Last() []interface{} {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if len(r.args) == 0 {
+		return nil
+	}
+	return r.args[len(r.args)-1]
+}
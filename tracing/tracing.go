@@ -0,0 +1,249 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/jucardi/go-mongodb-lib/mgo"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// InstrumentationName is the name of the tracer used to create the spans.
+const InstrumentationName = "github.com/jucardi/go-mongodb-lib/tracing"
+
+// sanitized is the placeholder used for the values in the sanitized statements.
+const sanitized = "?"
+
+// Options defines how the spans are created.
+type Options struct {
+	// TracerProvider is the provider used to obtain the tracer. Defaults to the global provider (otel.GetTracerProvider).
+	TracerProvider trace.TracerProvider
+	// Context is the parent context of the created spans. Defaults to context.Background(). Since the mgo operations do
+	// not receive a context, a decorated session copy may be created per request to make the spans children of the
+	// request span:
+	//
+	//     s := tracing.Session(session.Copy(), tracing.Options{Context: ctx})
+	//     defer s.Close()
+	//
+	Context context.Context
+	// DisableStatement disables the 'db.statement' attribute. Statements are sanitized, all values contained in the
+	// filters, updates, pipelines and commands are replaced by '?', see Statement.
+	DisableStatement bool
+}
+
+// Session decorates the provided session so every operation performed through it, and through the databases,
+// collections, queries, pipes and bulks obtained from it, produces a span.
+func Session(s mgo.ISession, opts ...Options) mgo.ISession {
+	return mgo.InterceptSession(s, Interceptor(opts...))
+}
+
+// Collection decorates the provided collection so every operation performed through it, and through the queries,
+// pipes and bulks obtained from it, produces a span.
+func Collection(col mgo.ICollection, opts ...Options) mgo.ICollection {
+	return mgo.InterceptCollection(col, Interceptor(opts...))
+}
+
+// Interceptor returns an mgo.Interceptor that produces a span for every operation, following the OpenTelemetry
+// semantic conventions for database clients.
+func Interceptor(opts ...Options) mgo.Interceptor {
+	cfg := Options{}
+	if len(opts) > 0 {
+		cfg = opts[0]
+	}
+	if cfg.TracerProvider == nil {
+		cfg.TracerProvider = otel.GetTracerProvider()
+	}
+	if cfg.Context == nil {
+		cfg.Context = context.Background()
+	}
+
+	tracer := cfg.TracerProvider.Tracer(InstrumentationName)
+
+	return func(op *mgo.Operation, next mgo.Invoker) error {
+		_, span := tracer.Start(cfg.Context, spanName(op), trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attributes(op, cfg)...))
+		defer span.End()
+
+		err := next(op)
+
+		if err != nil && err != mgo.ErrNotFound {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return err
+	}
+}
+
+func spanName(op *mgo.Operation) string {
+	if op.Collection == "" {
+		return op.Name
+	}
+	return op.Name + " " + op.Collection
+}
+
+func attributes(op *mgo.Operation, cfg Options) []attribute.KeyValue {
+	ret := []attribute.KeyValue{
+		semconv.DBSystemMongoDB,
+		semconv.DBOperation(op.Name),
+	}
+	if op.Database != "" {
+		ret = append(ret, semconv.DBName(op.Database))
+	}
+	if op.Collection != "" {
+		ret = append(ret, semconv.DBMongoDBCollection(op.Collection))
+	}
+	if !cfg.DisableStatement {
+		if statement := Statement(op); statement != "" {
+			ret = append(ret, semconv.DBStatement(statement))
+		}
+	}
+	return ret
+}
+
+// Statement returns the sanitized statement of the operation in JSON format, where all values contained in the
+// filter, update, pipeline and command are replaced by '?'. The field paths and variables used in the expressions of
+// pipelines and '$expr' filters (eg: "$name") are kept, any other string is replaced even if it starts with '$'. The
+// order of the fields of bson.D documents is preserved. Returns an empty string if the operation does not contain any
+// of them.
+func Statement(op *mgo.Operation) string {
+	statement := map[string]interface{}{}
+
+	if op.Filter != nil {
+		statement["filter"] = sanitize(op.Filter, false)
+	}
+	if op.Update != nil {
+		statement["update"] = sanitize(op.Update, false)
+	}
+	if op.Pipeline != nil {
+		statement["pipeline"] = sanitize(op.Pipeline, true)
+	}
+	if op.Command != nil {
+		statement["command"] = sanitize(op.Command, false)
+	}
+	if len(op.Sort) > 0 {
+		statement["sort"] = op.Sort
+	}
+
+	if len(statement) == 0 {
+		return ""
+	}
+
+	data, err := json.Marshal(statement)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// sanitize returns a copy of the provided document where all values are replaced by '?', keeping the keys (field names
+// and operators) untouched. If 'expr' is true the value is an aggregation expression, where the strings starting with
+// '$' are field paths or variables and are kept.
+func sanitize(value interface{}, expr bool) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case bson.M:
+		return sanitizeMap(v, expr)
+	case map[string]interface{}:
+		return sanitizeMap(v, expr)
+	case bson.D:
+		return sanitizeDoc(v, expr)
+	case []interface{}:
+		return sanitizeSlice(reflect.ValueOf(v), expr)
+	case string:
+		if expr && strings.HasPrefix(v, "$") {
+			// Field paths used in pipelines, eg: {$group: {_id: "$name"}}
+			return v
+		}
+		return sanitized
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return sanitized
+		}
+		return sanitizeSlice(rv, expr)
+	case reflect.Map, reflect.Struct, reflect.Ptr:
+		data, err := bson.Marshal(value)
+		if err != nil {
+			return sanitized
+		}
+		var doc bson.D
+		if err := bson.Unmarshal(data, &doc); err != nil {
+			return sanitized
+		}
+		return sanitizeDoc(doc, expr)
+	}
+
+	return sanitized
+}
+
+// fieldExpr indicates whether the value of the given key is an aggregation expression: '$expr' in filters, and every
+// stage of a pipeline but the filters of '$match'.
+func fieldExpr(key string, expr bool) bool {
+	switch key {
+	case "$expr":
+		return true
+	case "$match":
+		return false
+	}
+	return expr
+}
+
+func sanitizeMap(doc map[string]interface{}, expr bool) map[string]interface{} {
+	ret := make(map[string]interface{}, len(doc))
+	for k, v := range doc {
+		ret[k] = sanitize(v, fieldExpr(k, expr))
+	}
+	return ret
+}
+
+func sanitizeDoc(doc bson.D, expr bool) orderedDoc {
+	ret := make(orderedDoc, len(doc))
+	for i, e := range doc {
+		ret[i] = bson.DocElem{Name: e.Name, Value: sanitize(e.Value, fieldExpr(e.Name, expr))}
+	}
+	return ret
+}
+
+func sanitizeSlice(v reflect.Value, expr bool) []interface{} {
+	ret := make([]interface{}, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		ret[i] = sanitize(v.Index(i).Interface(), expr)
+	}
+	return ret
+}
+
+// orderedDoc is a sanitized bson.D, marshalled to JSON as an object which keys keep the order of the document.
+type orderedDoc []bson.DocElem
+
+func (d orderedDoc) MarshalJSON() ([]byte, error) {
+	buf := bytes.NewBufferString("{")
+	for i, e := range d {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(e.Name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(e.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,102 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jucardi/go-mongodb-lib/mgo"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	mgov2 "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func setup() (*tracetest.InMemoryExporter, *mgo.CollectionMock, mgo.ICollection) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	mock := mgo.MockCollection(mgo.NewCollection(&mgov2.Collection{Name: "users", FullName: "test.users"}))
+	return exporter, mock, Collection(mock, Options{TracerProvider: provider, Context: context.Background()})
+}
+
+func attrs(span tracetest.SpanStub) map[attribute.Key]string {
+	ret := map[attribute.Key]string{}
+	for _, kv := range span.Attributes {
+		ret[kv.Key] = kv.Value.Emit()
+	}
+	return ret
+}
+
+func TestInterceptor_Query(t *testing.T) {
+	exporter, mock, col := setup()
+	q := mgo.MockQuery()
+	mock.WhenFind(nil, q)
+
+	var result bson.M
+	assert.NoError(t, col.Find(bson.M{"email": "someone@example.com", "age": bson.M{"$gt": 30}}).Sort("-age").One(&result))
+
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "Query.One users", spans[0].Name)
+	assert.Equal(t, codes.Unset, spans[0].Status.Code)
+	assert.Equal(t, map[attribute.Key]string{
+		"db.system":             "mongodb",
+		"db.name":               "test",
+		"db.mongodb.collection": "users",
+		"db.operation":          "Query.One",
+		"db.statement":          `{"filter":{"age":{"$gt":"?"},"email":"?"},"sort":["-age"]}`,
+	}, attrs(spans[0]))
+}
+
+func TestInterceptor_Error(t *testing.T) {
+	exporter, mock, col := setup()
+	mock.WhenInsert(nil, errors.New("insert failed"))
+
+	assert.Error(t, col.Insert(bson.M{"password": "secret"}))
+
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "Insert users", spans[0].Name)
+	assert.Equal(t, codes.Error, spans[0].Status.Code)
+	assert.Equal(t, "insert failed", spans[0].Status.Description)
+	assert.NotContains(t, attrs(spans[0]), attribute.Key("db.statement"))
+}
+
+func TestStatement(t *testing.T) {
+	op := &mgo.Operation{
+		Filter:   bson.D{{Name: "tags", Value: bson.M{"$in": []string{"a", "b"}}}},
+		Pipeline: []bson.M{{"$group": bson.M{"_id": "$name", "n": bson.M{"$sum": 1}}}},
+	}
+	assert.Equal(t, `{"filter":{"tags":{"$in":["?","?"]}},"pipeline":[{"$group":{"_id":"$name","n":{"$sum":"?"}}}]}`, Statement(op))
+	assert.Equal(t, "", Statement(&mgo.Operation{}))
+}
+
+func TestStatement_DollarValues(t *testing.T) {
+	op := &mgo.Operation{
+		Filter: bson.M{"password": "$ecret", "$expr": bson.M{"$gt": []interface{}{"$spent", "$budget"}}},
+		Update: bson.M{"$set": bson.M{"token": "$2a$10$abcdefghijklmnopqrstuv"}},
+		Pipeline: []bson.M{
+			{"$match": bson.M{"name": "$admin"}},
+			{"$project": bson.M{"total": bson.M{"$add": []interface{}{"$a", 1}}}},
+		},
+	}
+	assert.Equal(t, `{"filter":{"$expr":{"$gt":["$spent","$budget"]},"password":"?"},`+
+		`"pipeline":[{"$match":{"name":"?"}},{"$project":{"total":{"$add":["$a","?"]}}}],`+
+		`"update":{"$set":{"token":"?"}}}`, Statement(op))
+}
+
+func TestStatement_Order(t *testing.T) {
+	type filter struct {
+		Zone string `bson:"zone"`
+		Age  int    `bson:"age"`
+	}
+	op := &mgo.Operation{
+		Filter:  bson.D{{Name: "status", Value: "a"}, {Name: "created", Value: bson.D{{Name: "$lt", Value: 2}, {Name: "$gt", Value: 1}}}},
+		Command: &filter{Zone: "eu", Age: 3},
+	}
+	assert.Equal(t, `{"command":{"zone":"?","age":"?"},"filter":{"status":"?","created":{"$lt":"?","$gt":"?"}}}`, Statement(op))
+}